@@ -0,0 +1,130 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+)
+
+const aip193DefaultReason = "INVALID_REQUEST"
+
+// AIP193Renderer builds errors that follow Google's API error model, as
+// described in [AIP-193]. Use its Render method with [WithErrorTransformer]:
+//
+//	renderer := validate.AIP193Renderer{Domain: "acme.com"}
+//	interceptor, err := validate.NewInterceptor(
+//		validate.WithErrorTransformer(renderer.Render),
+//	)
+//
+// Rendered errors have the code the Interceptor resolved, as reported by
+// [ErrorCode], which is [connect.CodeInvalidArgument] unless [WithCodeMapper]
+// or a [ProcedureConfig] changes it. They have an English, developer-facing
+// message that counts the distinct invalid fields, and they carry these
+// details, in order: a google.rpc.ErrorInfo identifying the failure, a
+// google.rpc.BadRequest with a FieldViolation for each violation, and, if
+// Locale returns a locale, a google.rpc.LocalizedMessage for end users.
+// Violation messages are the ones produced by [WithMessageTranslator], if
+// configured.
+//
+// [AIP-193]: https://google.aip.dev/193
+type AIP193Renderer struct {
+	// Domain is the logical grouping of the ErrorInfo reason, typically the
+	// service's DNS name. It's required.
+	Domain string
+	// Reason is the ErrorInfo reason, in UPPER_SNAKE_CASE. The default is
+	// "INVALID_REQUEST".
+	Reason string
+	// Locale returns the BCP-47 locale of the LocalizedMessage for an RPC.
+	// If it's nil or returns "", errors don't have a LocalizedMessage.
+	Locale func(context.Context) string
+}
+
+// Render builds an AIP-193 error for the violations. Its signature matches
+// [WithErrorTransformer].
+func (r AIP193Renderer) Render(ctx context.Context, spec connect.Spec, err *protovalidate.ValidationError) *connect.Error {
+	reason := r.Reason
+	if reason == "" {
+		reason = aip193DefaultReason
+	}
+	code, ok := ErrorCode(ctx)
+	if !ok {
+		code = connect.CodeInvalidArgument
+	}
+	ids := make([]string, 0, len(err.Violations))
+	fields := make(map[string]struct{}, len(err.Violations))
+	for _, violation := range err.Violations {
+		if id := violation.Proto.GetConstraintId(); id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+		fields[protovalidate.FieldPathString(violation.Proto.GetField())] = struct{}{}
+	}
+	connectErr := connect.NewError(
+		code,
+		errors.New("request has "+pluralize(len(fields), "invalid field")),
+	)
+	details := []proto.Message{
+		&errdetails.ErrorInfo{
+			Reason: reason,
+			Domain: r.Domain,
+			Metadata: map[string]string{
+				"procedure":     spec.Procedure,
+				"constraintIds": strings.Join(ids, ","),
+			},
+		},
+		toBadRequest(err),
+	}
+	if r.Locale != nil {
+		if locale := r.Locale(ctx); locale != "" {
+			details = append(details, &errdetails.LocalizedMessage{
+				Locale:  locale,
+				Message: localizedSummary(err),
+			})
+		}
+	}
+	for _, msg := range details {
+		if detail, err := connect.NewErrorDetail(msg); err == nil {
+			connectErr.AddDetail(detail)
+		}
+	}
+	return connectErr
+}
+
+// localizedSummary joins the (possibly translated) violation messages into
+// one message for end users.
+func localizedSummary(err *protovalidate.ValidationError) string {
+	messages := make([]string, 0, len(err.Violations))
+	for _, violation := range err.Violations {
+		if message := violation.Proto.GetMessage(); message != "" {
+			messages = append(messages, message)
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("1 %s", noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
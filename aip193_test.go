@@ -0,0 +1,156 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestAIP193Renderer(t *testing.T) {
+	t.Parallel()
+	renderer := validate.AIP193Renderer{
+		Domain: "example.com",
+		Locale: func(context.Context) string { return "fr-CH" },
+	}
+	interceptor, err := validate.NewInterceptor(
+		validate.WithErrorTransformer(renderer.Render),
+		validate.WithMessageTranslator(func(_ context.Context, violation *validate.Violation) string {
+			if violation.Proto.GetConstraintId() == "string.email" {
+				return "adresse e-mail invalide"
+			}
+			return ""
+		}),
+	)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(t, mux)
+
+	_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+		CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{
+				Email:     "foo",
+				BirthDate: timestamppb.New(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+			},
+		}))
+	require.Error(t, err)
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	assert.Equal(t, connect.CodeInvalidArgument, connectErr.Code())
+	assert.Equal(t, "request has 2 invalid fields", connectErr.Message())
+
+	details := connectErr.Details()
+	require.Len(t, details, 3)
+	var got []proto.Message
+	for _, detail := range details {
+		msg, err := detail.Value()
+		require.NoError(t, err)
+		got = append(got, msg)
+	}
+	want := []proto.Message{
+		&errdetails.ErrorInfo{
+			Reason: "INVALID_REQUEST",
+			Domain: "example.com",
+			Metadata: map[string]string{
+				"procedure":     userv1connect.UserServiceCreateUserProcedure,
+				"constraintIds": "user.signup_date,string.email",
+			},
+		},
+		&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "user", Description: "signup date must be on or after birth date"},
+				{Field: "user.email", Description: "adresse e-mail invalide"},
+			},
+		},
+		&errdetails.LocalizedMessage{
+			Locale:  "fr-CH",
+			Message: "signup date must be on or after birth date; adresse e-mail invalide",
+		},
+	}
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.True(t, proto.Equal(want[i], got[i]), "detail %d: got %v", i, got[i])
+	}
+}
+
+func TestAIP193RendererCode(t *testing.T) {
+	t.Parallel()
+	email := &validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("email")}
+	validator := staticValidator{err: &protovalidate.ValidationError{Violations: []*validate.Violation{
+		newViolation("string.email", email),
+		newViolation("string.max_len", email),
+	}}}
+	renderer := validate.AIP193Renderer{Domain: "example.com"}
+	tests := []struct {
+		name string
+		opts []validate.Option
+		want connect.Code
+	}{
+		{name: "default", want: connect.CodeInvalidArgument},
+		{
+			name: "code_mapper",
+			opts: []validate.Option{validate.WithCodeMapper(func(connect.Spec, *protovalidate.ValidationError) connect.Code {
+				return connect.CodeFailedPrecondition
+			})},
+			want: connect.CodeFailedPrecondition,
+		},
+		{
+			name: "procedure_config",
+			opts: []validate.Option{validate.WithProcedureConfig(userv1connect.UserServiceCreateUserProcedure, validate.ProcedureConfig{
+				Code: connect.CodeOutOfRange,
+			})},
+			want: connect.CodeOutOfRange,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(append(
+				test.opts,
+				validate.WithValidator(validator),
+				validate.WithErrorTransformer(renderer.Render),
+			)...)
+			require.NoError(t, err)
+			_, err = newUserClient(t, interceptor).CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+			require.Error(t, err)
+			assert.Equal(t, test.want, connect.CodeOf(err))
+			var connectErr *connect.Error
+			require.ErrorAs(t, err, &connectErr)
+			// Both violations are of the email field.
+			assert.Equal(t, "request has 1 invalid field", connectErr.Message())
+		})
+	}
+}
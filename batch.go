@@ -0,0 +1,107 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithBatchConcurrency configures the [Interceptor] to validate at most n
+// messages at once in [Interceptor.Batch]. Non-positive values of n use
+// [runtime.GOMAXPROCS], which is the default.
+func WithBatchConcurrency(n int) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.limits.batchConcurrency = n
+	})
+}
+
+// defaultBatchInterceptor is the Interceptor used by the package-level
+// [Batch]. It's built on first use, so programs that never call Batch don't
+// pay for it.
+var defaultBatchInterceptor = sync.OnceValues(func() (*Interceptor, error) {
+	return NewInterceptor()
+})
+
+// Batch validates messages outside of an RPC with the default configuration.
+// See [Interceptor.Batch]. The default Interceptor is built on first use and
+// shared by every call, so compiled constraints are reused across batches. To
+// configure validation, construct an Interceptor and call its Batch method.
+func Batch(ctx context.Context, msgs []proto.Message) []error {
+	interceptor, err := defaultBatchInterceptor()
+	if err != nil {
+		errs := make([]error, len(msgs))
+		for n := range errs {
+			errs[n] = err
+		}
+		return errs
+	}
+	return interceptor.Batch(ctx, msgs)
+}
+
+// Batch validates messages outside of an RPC, like the records consumed by a
+// stream-processing job, and returns an error for each message: nil if it's
+// valid, and otherwise the same [*connect.Error] an RPC would fail with.
+// Messages are validated concurrently, as limited by [WithBatchConcurrency],
+// and share the Interceptor's compiled constraints, so jobs that validate
+// many batches should reuse one Interceptor rather than construct one per
+// batch.
+//
+// Since there's no RPC, the [Policy] and other per-RPC options, like
+// sampling and concurrency limits, don't apply, and hooks that observe RPCs
+// aren't called. Hooks that shape errors, like [WithErrorTransformer], see a
+// zero [connect.Spec]. If ctx is canceled, messages that haven't been
+// validated yet get its error.
+func (i *Interceptor) Batch(ctx context.Context, msgs []proto.Message) []error {
+	errs := make([]error, len(msgs))
+	if i.nop {
+		return errs
+	}
+	workers := i.limits.batchConcurrency
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(msgs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				n := int(next.Add(1) - 1)
+				if n >= len(msgs) {
+					return
+				}
+				if err := ctx.Err(); err != nil {
+					errs[n] = err
+					continue
+				}
+				errs[n] = i.checkBatchItem(ctx, msgs[n])
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func (i *Interceptor) checkBatchItem(ctx context.Context, msg proto.Message) error {
+	_, _, err := i.check(ctx, connect.Spec{}, msg, nil)
+	return err
+}
@@ -0,0 +1,74 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+	msgs := []proto.Message{
+		&userv1.User{Email: "someone@example.com"},
+		&userv1.User{Email: "foo"},
+		&userv1.User{Email: "someone.else@example.com"},
+	}
+	interceptor, err := validate.NewInterceptor()
+	require.NoError(t, err)
+	t.Run("default", func(t *testing.T) {
+		t.Parallel()
+		errs := validate.Batch(context.Background(), msgs)
+		require.Len(t, errs, len(msgs))
+		require.NoError(t, errs[0])
+		require.Error(t, errs[1])
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(errs[1]))
+		assert.Equal(t, "string.email", requireSingleViolation(t, errs[1]).GetConstraintId())
+		require.NoError(t, errs[2])
+	})
+	t.Run("concurrency", func(t *testing.T) {
+		t.Parallel()
+		limited, err := validate.NewInterceptor(validate.WithBatchConcurrency(2))
+		require.NoError(t, err)
+		var batch []proto.Message
+		for n := 0; n < 100; n++ {
+			batch = append(batch, msgs...)
+		}
+		errs := limited.Batch(context.Background(), batch)
+		require.Len(t, errs, len(batch))
+		for n, err := range errs {
+			if n%len(msgs) == 1 {
+				assert.Error(t, err, "message %d", n)
+			} else {
+				assert.NoError(t, err, "message %d", n)
+			}
+		}
+	})
+	t.Run("canceled", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		for _, err := range interceptor.Batch(ctx, msgs) {
+			assert.ErrorIs(t, err, context.Canceled)
+		}
+	})
+}
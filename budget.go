@@ -0,0 +1,155 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const collectionBudgetID = "collection.budget"
+
+// WithCollectionBudget configures the [Interceptor] to count the repeated
+// elements and map entries in each message, including those in nested
+// messages, before validating it. Messages with more than n elements and
+// entries in total aren't validated. Instead, they're rejected with a single
+// violation of the "collection.budget" constraint, reported on the field where
+// the budget ran out. This bounds the work spent on maliciously large
+// collections. Non-positive values of n don't limit collections.
+//
+// Counting stops as soon as the budget runs out, so its cost is proportional
+// to n rather than to the size of the message.
+func WithCollectionBudget(n int) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.limits.collectionBudget = n
+	})
+}
+
+// checkCollectionBudget returns a violation if msg has more than limit
+// repeated elements and map entries, and nil otherwise.
+func checkCollectionBudget(msg protoreflect.Message, limit int) *protovalidate.Violation {
+	remaining := limit
+	var path []*validatepb.FieldPathElement
+	var violation *protovalidate.Violation
+	var walk func(protoreflect.Message) bool
+	walk = func(msg protoreflect.Message) bool {
+		msg.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+			element := newFieldPathElement(field)
+			path = append(path, element)
+			defer func() { path = path[:len(path)-1] }()
+			switch {
+			case field.IsList():
+				list := value.List()
+				if remaining -= list.Len(); remaining < 0 {
+					violation = newCollectionBudgetViolation(path, field, value, limit)
+					return false
+				}
+				if field.Message() == nil {
+					return true
+				}
+				for i := 0; i < list.Len(); i++ {
+					element.Subscript = &validatepb.FieldPathElement_Index{Index: uint64(i)}
+					if !walk(list.Get(i).Message()) {
+						return false
+					}
+				}
+			case field.IsMap():
+				entries := value.Map()
+				if remaining -= entries.Len(); remaining < 0 {
+					violation = newCollectionBudgetViolation(path, field, value, limit)
+					return false
+				}
+				if field.MapValue().Message() == nil {
+					return true
+				}
+				ok := true
+				entries.Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+					setMapKeySubscript(element, field.MapKey().Kind(), key)
+					ok = walk(value.Message())
+					return ok
+				})
+				return ok
+			case field.Message() != nil:
+				return walk(value.Message())
+			}
+			return true
+		})
+		return violation == nil
+	}
+	walk(msg)
+	return violation
+}
+
+func newCollectionBudgetViolation(path []*validatepb.FieldPathElement, field protoreflect.FieldDescriptor, value protoreflect.Value, limit int) *protovalidate.Violation {
+	// The walk reuses path elements, so copy them. Subscripts are never
+	// mutated, so they can be shared. The last element names the collection
+	// itself rather than one of its members, so it has no subscript.
+	elements := make([]*validatepb.FieldPathElement, len(path))
+	for i, element := range path {
+		elements[i] = &validatepb.FieldPathElement{
+			FieldNumber: element.FieldNumber,
+			FieldName:   element.FieldName,
+			FieldType:   element.FieldType,
+			KeyType:     element.KeyType,
+			ValueType:   element.ValueType,
+		}
+		if i < len(path)-1 {
+			elements[i].Subscript = element.Subscript
+		}
+	}
+	return &protovalidate.Violation{
+		Proto: &validatepb.Violation{
+			Field:        &validatepb.FieldPath{Elements: elements},
+			ConstraintId: proto.String(collectionBudgetID),
+			Message:      proto.String(fmt.Sprintf("message must have at most %d repeated elements and map entries", limit)),
+		},
+		FieldValue:      value,
+		FieldDescriptor: field,
+	}
+}
+
+func newFieldPathElement(field protoreflect.FieldDescriptor) *validatepb.FieldPathElement {
+	element := &validatepb.FieldPathElement{
+		FieldNumber: proto.Int32(int32(field.Number())),
+		FieldName:   proto.String(string(field.Name())),
+		FieldType:   descriptorpb.FieldDescriptorProto_Type(field.Kind()).Enum(),
+	}
+	if field.IsMap() {
+		element.KeyType = descriptorpb.FieldDescriptorProto_Type(field.MapKey().Kind()).Enum()
+		element.ValueType = descriptorpb.FieldDescriptorProto_Type(field.MapValue().Kind()).Enum()
+	}
+	return element
+}
+
+func setMapKeySubscript(element *validatepb.FieldPathElement, kind protoreflect.Kind, key protoreflect.MapKey) {
+	switch kind {
+	case protoreflect.BoolKind:
+		element.Subscript = &validatepb.FieldPathElement_BoolKey{BoolKey: key.Bool()}
+	case protoreflect.Int32Kind, protoreflect.Int64Kind,
+		protoreflect.Sint32Kind, protoreflect.Sint64Kind,
+		protoreflect.Sfixed32Kind, protoreflect.Sfixed64Kind:
+		element.Subscript = &validatepb.FieldPathElement_IntKey{IntKey: key.Int()}
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind,
+		protoreflect.Fixed32Kind, protoreflect.Fixed64Kind:
+		element.Subscript = &validatepb.FieldPathElement_UintKey{UintKey: key.Uint()}
+	default:
+		element.Subscript = &validatepb.FieldPathElement_StringKey{StringKey: key.String()}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestWithCollectionBudget(t *testing.T) {
+	t.Parallel()
+	nested, err := structpb.NewStruct(map[string]any{
+		"tags": []any{"a", "b", "c"},
+	})
+	require.NoError(t, err)
+	flat, err := structpb.NewStruct(map[string]any{
+		"a": 1,
+		"b": 2,
+	})
+	require.NoError(t, err)
+	tests := []struct {
+		name     string
+		msg      *structpb.Struct
+		budget   int
+		wantPath string // field path, from error details
+	}{
+		{
+			name:   "unlimited",
+			msg:    nested,
+			budget: 0,
+		},
+		{
+			name:   "within_budget",
+			msg:    nested,
+			budget: 4,
+		},
+		{
+			name:     "nested_list",
+			msg:      nested,
+			budget:   3,
+			wantPath: `fields["tags"].list_value.values`,
+		},
+		{
+			name:     "map",
+			msg:      flat,
+			budget:   1,
+			wantPath: "fields",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithCollectionBudget(test.budget))
+			require.NoError(t, err)
+			_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&structpb.Struct{}), nil
+			})(context.Background(), connect.NewRequest(test.msg))
+			if test.wantPath == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			violation := requireSingleViolation(t, err)
+			assert.Equal(t, test.wantPath, protovalidate.FieldPathString(violation.GetField()))
+			assert.Equal(t, "collection.budget", violation.GetConstraintId())
+		})
+	}
+}
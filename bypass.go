@@ -0,0 +1,90 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"connectrpc.com/connect"
+)
+
+type skipValidationKey struct{}
+
+// SkipValidation returns a copy of ctx that marks the RPC to skip
+// validation. Interceptors earlier in the chain, like an authorization layer
+// for internal administrators, can use it to let break-glass tooling submit
+// otherwise invalid messages. The mark only has an effect on Interceptors
+// configured with [WithContextBypass], so it can't be used to bypass
+// validation by accident.
+func SkipValidation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipValidationKey{}, true)
+}
+
+// WithContextBypass configures the [Interceptor] to skip validation for RPCs
+// whose context was marked with [SkipValidation], as if the procedure were in
+// [ModeSkip]. Only code that can change the context passed to the Interceptor
+// can mark an RPC: on handlers, that's interceptors and middleware earlier in
+// the chain, never the client.
+func WithContextBypass() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.scope.contextBypass = true
+	})
+}
+
+func skipRequested(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipValidationKey{}).(bool)
+	return skip
+}
+
+// WithHeaderBypass configures the [Interceptor] to skip validation on
+// handlers for requests that set the header to the given value, like
+// "X-Validate: off", so test harnesses can push intentionally malformed
+// messages through staging services. Any client can set a header, so allow
+// must also return true, which gates the bypass on a shared secret or the
+// peer's address:
+//
+//	validate.WithHeaderBypass("X-Validate", "off", func(header http.Header, _ connect.Peer) bool {
+//		return subtle.ConstantTimeCompare([]byte(header.Get("X-Validate-Secret")), secret) == 1
+//	})
+//
+// NewInterceptor returns an error if allow is nil. Clients are unaffected.
+func WithHeaderBypass(header, value string, allow func(http.Header, connect.Peer) bool) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.scope.headerBypass = &headerBypass{header: header, value: value, allow: allow}
+	})
+}
+
+type headerBypass struct {
+	header string
+	value  string
+	allow  func(http.Header, connect.Peer) bool
+}
+
+// bypassed reports whether a handler should skip validating a request.
+func (b *headerBypass) bypassed(spec connect.Spec, header http.Header, peer connect.Peer) bool {
+	if spec.IsClient || header.Get(b.header) != b.value {
+		return false
+	}
+	return b.allow(header, peer)
+}
+
+func (b *headerBypass) check() error {
+	if b.allow == nil {
+		return fmt.Errorf("header bypass %s: allow function is nil, so any client could bypass validation", b.header)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithValidators configures the [Interceptor] to validate messages with each
+// of validators in turn, like a protovalidate validator followed by a
+// domain-specific one, and to merge their violations into a single error. A
+// validator reports violations by returning a
+// [protovalidate.ValidationError]; any other error stops the chain and is
+// handled as if validation couldn't run (see [WithFailOpen]). Like
+// [WithValidator], it replaces the Interceptor's own validator.
+// [NewInterceptor] returns an error if validators is empty or holds a nil
+// validator.
+func WithValidators(validators ...protovalidate.Validator) Option {
+	return WithValidator(chainValidator(validators))
+}
+
+// chainValidator runs validators in order, merging their violations.
+type chainValidator []protovalidate.Validator
+
+// check reports chains that would silently validate nothing.
+func (c chainValidator) check() error {
+	if len(c) == 0 {
+		return errors.New("no validators to chain")
+	}
+	for n, validator := range c {
+		if validator == nil {
+			return fmt.Errorf("validator %d in chain is nil", n)
+		}
+	}
+	return nil
+}
+
+func (c chainValidator) Validate(msg proto.Message) error {
+	var merged *protovalidate.ValidationError
+	for _, validator := range c {
+		err := validator.Validate(msg)
+		if err == nil {
+			continue
+		}
+		validationErr := new(protovalidate.ValidationError)
+		if !errors.As(err, &validationErr) {
+			return err
+		}
+		if merged == nil {
+			merged = &protovalidate.ValidationError{}
+		}
+		merged.Violations = append(merged.Violations, validationErr.Violations...)
+	}
+	if merged == nil {
+		return nil
+	}
+	return merged
+}
@@ -0,0 +1,102 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithValidators(t *testing.T) {
+	t.Parallel()
+	standard, err := protovalidate.New()
+	require.NoError(t, err)
+	domain := staticValidator{err: &protovalidate.ValidationError{Violations: []*validate.Violation{
+		newViolation("user.unique_email"),
+	}}}
+	tests := []struct {
+		name       string
+		validators []protovalidate.Validator
+		user       *userv1.User
+		wantIDs    []string
+		wantCode   connect.Code
+	}{
+		{
+			name:       "valid",
+			validators: []protovalidate.Validator{standard, staticValidator{}},
+			user:       &userv1.User{Email: "someone@example.com"},
+		},
+		{
+			name:       "merged",
+			validators: []protovalidate.Validator{standard, domain},
+			user:       &userv1.User{Email: "foo"},
+			wantIDs:    []string{"string.email", "user.unique_email"},
+			wantCode:   connect.CodeInvalidArgument,
+		},
+		{
+			name:       "second_only",
+			validators: []protovalidate.Validator{standard, domain},
+			user:       &userv1.User{Email: "someone@example.com"},
+			wantIDs:    []string{"user.unique_email"},
+			wantCode:   connect.CodeInvalidArgument,
+		},
+		{
+			name:       "validator_error",
+			validators: []protovalidate.Validator{failingValidator{}, domain},
+			user:       &userv1.User{Email: "someone@example.com"},
+			wantCode:   connect.CodeInternal,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithValidators(test.validators...))
+			require.NoError(t, err)
+			_, err = newUserClient(t, interceptor).CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+				User: test.user,
+			}))
+			if test.wantCode == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, test.wantCode, connect.CodeOf(err))
+			if test.wantIDs == nil {
+				return
+			}
+			var ids []string
+			for _, violation := range violationsOf(t, err) {
+				ids = append(ids, violation.GetConstraintId())
+			}
+			assert.Equal(t, test.wantIDs, ids)
+		})
+	}
+}
+
+func TestWithValidatorsEmpty(t *testing.T) {
+	t.Parallel()
+	_, err := validate.NewInterceptor(validate.WithValidators())
+	require.Error(t, err)
+	_, err = validate.NewInterceptor(validate.WithValidators(staticValidator{}, nil))
+	require.Error(t, err)
+}
@@ -0,0 +1,38 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import "time"
+
+// WithClock configures the [Interceptor] to tell the time with the given
+// function, instead of [time.Now]. The clock decides when procedures governed
+// by [Policy.WarnUntil] are promoted, and which window of [WithRollups] each
+// validation is counted in, so tests can use it to produce deterministic
+// promotions and rollups. Durations, like those passed to the function
+// configured with [WithObserver], are still measured with the system clock.
+// The function may be called concurrently.
+func WithClock(now func() time.Time) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.telemetry.clock = now
+	})
+}
+
+// now returns the current time, according to the configured clock.
+func (i *Interceptor) now() time.Time {
+	if i.telemetry.clock != nil {
+		return i.telemetry.clock()
+	}
+	return time.Now()
+}
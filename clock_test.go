@@ -0,0 +1,70 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithClock(t *testing.T) {
+	t.Parallel()
+	deadline := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	var now atomic.Pointer[time.Time]
+	setNow := func(t time.Time) { now.Store(&t) }
+	setNow(deadline.Add(-90 * time.Minute))
+	rollups := make(chan validate.Rollup, 2)
+	interceptor, err := validate.NewInterceptor(
+		validate.WithClock(func() time.Time { return *now.Load() }),
+		validate.WithPolicy(validate.Policy{}.WarnUntil(deadline, userv1connect.UserServiceCreateUserProcedure)),
+		validate.WithRollups(time.Hour, -1, func(rollup validate.Rollup) {
+			rollups <- rollup
+		}),
+	)
+	require.NoError(t, err)
+	client := newUserClient(t, interceptor)
+	invalid := connect.NewRequest(&userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}})
+
+	// Before the deadline, the procedure is in ModeWarn.
+	_, err = client.CreateUser(context.Background(), invalid)
+	require.NoError(t, err)
+
+	// Once the clock passes the deadline, the procedure is enforced, and the
+	// previous window is reported.
+	setNow(deadline)
+	_, err = client.CreateUser(context.Background(), invalid)
+	require.Error(t, err)
+	require.Len(t, rollups, 1)
+	rollup := <-rollups
+	assert.Equal(t, deadline.Add(-2*time.Hour), rollup.Start)
+	assert.Equal(t, deadline.Add(-time.Hour), rollup.End)
+	require.Len(t, rollup.Procedures, 1)
+	assert.Equal(t, 1, rollup.Procedures[0].Warned)
+
+	interceptor.FlushRollups()
+	rollup = <-rollups
+	assert.Equal(t, deadline, rollup.Start)
+	require.Len(t, rollup.Procedures, 1)
+	assert.Equal(t, 1, rollup.Procedures[0].Rejected)
+}
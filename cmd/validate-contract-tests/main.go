@@ -0,0 +1,91 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command validate-contract-tests generates table-driven Go tests that check
+// a client's request builder against the constraints of a message type, with
+// one case per constraint. It reads the message's descriptor from a
+// FileDescriptorSet, like the output of "buf build -o image.binpb":
+//
+//	validate-contract-tests -descriptors image.binpb \
+//		-message acme.user.v1.CreateUserRequest \
+//		-package userclient -builder NewCreateUserRequest \
+//		-o create_user_contract_test.go
+//
+// The generated cases are skeletons to fill in. See
+// [validatetest.WriteContractTests].
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	_ "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate" // registers the constraint extensions
+	"connectrpc.com/validate/validatetest"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func main() {
+	descriptors := flag.String("descriptors", "", "path to a FileDescriptorSet with the message and its dependencies")
+	message := flag.String("message", "", "fully-qualified name of the message")
+	pkg := flag.String("package", "", "package of the generated file")
+	builder := flag.String("builder", "", "request builder to call in each case (default: an empty message)")
+	out := flag.String("o", "", "output file (default: stdout)")
+	flag.Parse()
+	if err := run(*descriptors, *message, *out, validatetest.ContractTestOptions{Package: *pkg, Builder: *builder}); err != nil {
+		fmt.Fprintf(os.Stderr, "validate-contract-tests: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(descriptors, message, out string, opts validatetest.ContractTestOptions) error {
+	if descriptors == "" || message == "" {
+		return errors.New("-descriptors and -message are required")
+	}
+	data, err := os.ReadFile(descriptors)
+	if err != nil {
+		return err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("parse %s: %w", descriptors, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return fmt.Errorf("load %s: %w", descriptors, err)
+	}
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(message))
+	if err != nil {
+		return err
+	}
+	msg, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return fmt.Errorf("%s isn't a message", message)
+	}
+	var w io.Writer = os.Stdout
+	if out != "" {
+		file, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	return validatetest.WriteContractTests(w, msg, opts)
+}
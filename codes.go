@@ -0,0 +1,74 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"strings"
+
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+)
+
+// RuleCategoryCode is a code mapper for [WithCodeMapper] that chooses the
+// [connect.Code] of errors by the category of the violated constraints,
+// following gRPC's guidance on status codes:
+//
+//   - Fixed ranges of numbers, durations, and timestamps, like int32.gt,
+//     duration.lte_gte, or timestamp.lt, produce [connect.CodeOutOfRange],
+//     since the value may become valid as the system's limits change.
+//   - Timestamps relative to the current time, which are checked by
+//     timestamp.lt_now, timestamp.gt_now, and timestamp.within, produce
+//     [connect.CodeFailedPrecondition], since they depend on the state of
+//     the system rather than on the message alone.
+//   - Every other constraint, including required, produces
+//     [connect.CodeInvalidArgument].
+//
+// If the violations span several categories, the error uses
+// [connect.CodeInvalidArgument], which fits them all.
+//
+//	validate.NewInterceptor(validate.WithCodeMapper(validate.RuleCategoryCode))
+func RuleCategoryCode(_ connect.Spec, err *protovalidate.ValidationError) connect.Code {
+	var code connect.Code
+	for _, violation := range err.Violations {
+		category := ruleCategoryCode(violation.Proto.GetConstraintId())
+		if code != 0 && category != code {
+			return connect.CodeInvalidArgument
+		}
+		code = category
+	}
+	if code == 0 {
+		return connect.CodeInvalidArgument
+	}
+	return code
+}
+
+func ruleCategoryCode(constraintID string) connect.Code {
+	kind, rule, ok := strings.Cut(constraintID, ".")
+	if !ok {
+		return connect.CodeInvalidArgument
+	}
+	switch constraintID {
+	case "timestamp.lt_now", "timestamp.gt_now", "timestamp.within":
+		return connect.CodeFailedPrecondition
+	}
+	switch kind {
+	case "int32", "int64", "uint32", "uint64", "sint32", "sint64",
+		"fixed32", "fixed64", "sfixed32", "sfixed64", "float", "double", "duration", "timestamp":
+		if strings.HasPrefix(rule, "gt") || strings.HasPrefix(rule, "lt") {
+			return connect.CodeOutOfRange
+		}
+	}
+	return connect.CodeInvalidArgument
+}
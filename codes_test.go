@@ -0,0 +1,64 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleCategoryCode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		constraintIDs []string
+		want          connect.Code
+	}{
+		{name: "range", constraintIDs: []string{"int32.gt", "double.gte_lte"}, want: connect.CodeOutOfRange},
+		{name: "duration", constraintIDs: []string{"duration.lt"}, want: connect.CodeOutOfRange},
+		{name: "timestamp", constraintIDs: []string{"timestamp.gt", "timestamp.gte_lt"}, want: connect.CodeOutOfRange},
+		{name: "timestamp_now", constraintIDs: []string{"timestamp.lt_now", "timestamp.gt_now", "timestamp.within"}, want: connect.CodeFailedPrecondition},
+		{name: "required", constraintIDs: []string{"required"}, want: connect.CodeInvalidArgument},
+		{name: "other", constraintIDs: []string{"int32.const", "string.email"}, want: connect.CodeInvalidArgument},
+		{name: "mixed", constraintIDs: []string{"int32.gt", "timestamp.lt_now"}, want: connect.CodeInvalidArgument},
+		{name: "cel", constraintIDs: []string{"user.signup_date"}, want: connect.CodeInvalidArgument},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			violations := make([]*validate.Violation, len(test.constraintIDs))
+			for n, id := range test.constraintIDs {
+				violations[n] = newViolation(id)
+			}
+			interceptor, err := validate.NewInterceptor(
+				validate.WithValidator(staticValidator{err: &protovalidate.ValidationError{Violations: violations}}),
+				validate.WithCodeMapper(validate.RuleCategoryCode),
+			)
+			require.NoError(t, err)
+			_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+			})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+			assert.Equal(t, test.want, connect.CodeOf(err))
+		})
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+// Config is a snapshot of an [Interceptor]'s effective configuration, as
+// returned by [Interceptor.EffectiveConfig]. It's meant for operators
+// verifying what a running process enforces, so it marshals to readable
+// JSON. Related options are grouped, and options configured with functions
+// are listed by name in Hooks. Nop is set for the Interceptor returned by
+// [NopInterceptor]. Secrets are left out: Scope.HeaderBypass names the bypass
+// header, but its value is redacted, since snapshots end up in support
+// bundles and bug reports.
+//
+// Errors.ErrorDetails lists the types of the details attached to errors for
+// invalid messages, in order. The google.rpc.ErrorInfo details are named with
+// their reason, since they're only attached to errors that are summarized or
+// truncated.
+type Config struct {
+	Nop                bool                  `json:"nop"`
+	Direction          string                `json:"direction"`
+	StreamingResponses bool                  `json:"streaming_responses"`
+	Policy             []PolicyRule          `json:"policy"`
+	ProcedureConfigs   []ProcedureConfigRule `json:"procedure_configs"`
+	Validators         ValidatorConfig       `json:"validators"`
+	Scope              ScopeConfig           `json:"scope"`
+	Messages           MessageConfig         `json:"messages"`
+	Violations         ViolationConfig       `json:"violations"`
+	Errors             ErrorConfig           `json:"errors"`
+	Failures           FailureConfig         `json:"failures"`
+	Warnings           WarningConfig         `json:"warnings"`
+	Limits             LimitConfig           `json:"limits"`
+	Streams            StreamConfig          `json:"streams"`
+	Telemetry          TelemetryConfig       `json:"telemetry"`
+	Hooks              []string              `json:"hooks"`
+}
+
+// ValidatorConfig describes how validators are built.
+type ValidatorConfig struct {
+	CustomValidator      bool     `json:"custom_validator"`
+	FailFast             bool     `json:"fail_fast"`
+	ProtovalidateOptions int      `json:"protovalidate_options"`
+	Recover              bool     `json:"recover"`
+	LazyInit             bool     `json:"lazy_init"`
+	WarmupMessages       []string `json:"warmup_messages"`
+	SchemaVersions       []string `json:"schema_versions,omitempty"`
+}
+
+// ScopeConfig describes which messages are validated.
+type ScopeConfig struct {
+	SkipProcedures   []string `json:"skip_procedures"`
+	SkipMessageTypes []string `json:"skip_message_types"`
+	OnlyMessageTypes []string `json:"only_message_types,omitempty"`
+	SampleRate       float64  `json:"sample_rate"`
+	ContextBypass    bool     `json:"context_bypass"`
+	HeaderBypass     string   `json:"header_bypass,omitempty"`
+	UpstreamResults  bool     `json:"upstream_results"`
+	DisableOption    string   `json:"disable_option,omitempty"`
+}
+
+// MessageConfig describes the checks of messages beyond their constraints.
+type MessageConfig struct {
+	EmptyMessages       string   `json:"empty_messages"`
+	MessageHooks        []string `json:"message_hooks"`
+	FieldMaskValidation bool     `json:"field_mask_validation"`
+	MaskedValidation    bool     `json:"masked_validation"`
+}
+
+// ViolationConfig describes which violations are reported.
+type ViolationConfig struct {
+	RuleSeverities   map[string]string `json:"rule_severities,omitempty"`
+	SortedViolations bool              `json:"sorted_violations"`
+	MaxViolations    int               `json:"max_violations"`
+	SummaryThreshold int               `json:"summary_threshold"`
+	SummaryKeep      int               `json:"summary_keep"`
+}
+
+// ErrorConfig describes the errors returned for invalid messages.
+type ErrorConfig struct {
+	ErrorDetails       []string `json:"error_details"`
+	ErrorMessage       string   `json:"error_message,omitempty"`
+	WithoutRuleDetails bool     `json:"without_rule_details"`
+	MaxDetailBytes     int      `json:"max_detail_bytes"`
+	RejectionIDs       bool     `json:"rejection_ids"`
+	FailedRulesHeader  bool     `json:"failed_rules_header"`
+	VersionHeaders     bool     `json:"version_headers"`
+	SchemaVersion      string   `json:"schema_version,omitempty"`
+}
+
+// FailureConfig describes how validator failures are handled.
+type FailureConfig struct {
+	FailOpen             []string `json:"fail_open"`
+	FailOpenCompilation  bool     `json:"fail_open_compilation_errors"`
+	ValidatorErrorCode   string   `json:"validator_error_code"`
+	CompilationErrorCode string   `json:"compilation_error_code"`
+	RuntimeErrorCode     string   `json:"runtime_error_code"`
+}
+
+// WarningConfig describes how invalid messages let through are reported.
+type WarningConfig struct {
+	DryRun          bool `json:"dry_run"`
+	WarningTrailers bool `json:"warning_trailers"`
+}
+
+// LimitConfig describes the resources validation may use.
+type LimitConfig struct {
+	MaxMessageSize        int                `json:"max_message_size"`
+	OversizePolicy        string             `json:"oversize_policy,omitempty"`
+	CollectionBudget      int                `json:"collection_budget"`
+	ValidationTimeout     string             `json:"validation_timeout,omitempty"`
+	ValidationTimeoutCode string             `json:"validation_timeout_code,omitempty"`
+	LimitExemptions       []string           `json:"limit_exemptions"`
+	ConcurrencyLimit      *ConcurrencyConfig `json:"concurrency_limit,omitempty"`
+	TotalConcurrencyLimit *ConcurrencyConfig `json:"total_concurrency_limit,omitempty"`
+	BatchConcurrency      int                `json:"batch_concurrency"`
+}
+
+// StreamConfig describes the checks across the messages of a stream.
+type StreamConfig struct {
+	UniqueStreamFields []string `json:"unique_stream_fields"`
+	StreamInvariants   []string `json:"stream_invariants"`
+}
+
+// TelemetryConfig describes how the outcomes of validation are recorded.
+type TelemetryConfig struct {
+	CostProfilingRate float64 `json:"cost_profiling_rate"`
+	RequestLogRate    float64 `json:"request_log_rate"`
+	RecentRejections  int     `json:"recent_rejections"`
+	RollupWindow      string  `json:"rollup_window,omitempty"`
+}
+
+// PolicyRule is one rule of a [Policy], in the order the rules were added.
+type PolicyRule struct {
+	Pattern      string     `json:"pattern"`
+	Mode         Mode       `json:"mode"`
+	EnforceAfter *time.Time `json:"enforce_after,omitempty"`
+}
+
+// ProcedureConfigRule is one configuration added with [WithProcedureConfig],
+// in the order the configurations were added.
+type ProcedureConfigRule struct {
+	Pattern           string `json:"pattern"`
+	SkipRequests      bool   `json:"skip_requests"`
+	ValidateResponses bool   `json:"validate_responses"`
+	Code              string `json:"code,omitempty"`
+	FailFast          bool   `json:"fail_fast"`
+}
+
+// ConcurrencyConfig describes the limit configured with
+// [WithProcedureConcurrencyLimit] or [WithConcurrencyLimit].
+type ConcurrencyConfig struct {
+	PerProcedure int    `json:"per_procedure,omitempty"`
+	Total        int    `json:"total,omitempty"`
+	Overflow     string `json:"overflow"`
+}
+
+// EffectiveConfig returns a snapshot of the Interceptor's configuration.
+func (i *Interceptor) EffectiveConfig() Config {
+	config := Config{
+		Nop:                i.nop,
+		Direction:          i.direction.String(),
+		StreamingResponses: i.streamingResponses,
+		Policy:             make([]PolicyRule, len(i.policy.rules)),
+		ProcedureConfigs:   make([]ProcedureConfigRule, len(i.procedureConfigs)),
+		Validators: ValidatorConfig{
+			CustomValidator:      i.validators.customValidator,
+			FailFast:             i.validators.failFast,
+			ProtovalidateOptions: i.validators.protovalidateOptions,
+			Recover:              i.validators.recoverPanics,
+			LazyInit:             i.validators.lazyInit,
+			WarmupMessages:       make([]string, len(i.validators.warmup)),
+		},
+		Scope: ScopeConfig{
+			SkipProcedures:   make([]string, 0, len(i.scope.skipProcedures)),
+			SkipMessageTypes: make([]string, 0, len(i.scope.skipTypes)),
+			SampleRate:       1,
+			ContextBypass:    i.scope.contextBypass,
+			UpstreamResults:  i.scope.upstreamResults,
+		},
+		Messages: MessageConfig{
+			EmptyMessages:       i.messages.emptyMessages.String(),
+			MessageHooks:        make([]string, 0, len(i.messages.hooksByMessage)),
+			FieldMaskValidation: i.messages.fieldMaskResolver != nil,
+			MaskedValidation:    i.messages.maskedValidation,
+		},
+		Violations: ViolationConfig{
+			SortedViolations: i.violations.sortViolations,
+			MaxViolations:    i.violations.maxViolations,
+			SummaryThreshold: i.violations.summaryThreshold,
+			SummaryKeep:      i.violations.summaryKeep,
+		},
+		Errors: ErrorConfig{
+			ErrorDetails:       []string{},
+			ErrorMessage:       i.reporting.errorMessage,
+			WithoutRuleDetails: i.reporting.withoutRules,
+			MaxDetailBytes:     i.reporting.maxDetailBytes,
+			RejectionIDs:       i.reporting.rejectionIDs,
+			FailedRulesHeader:  i.reporting.failedRulesHeader,
+			VersionHeaders:     i.reporting.versionHeaders != nil,
+		},
+		Failures: FailureConfig{
+			FailOpen:            append([]string{}, i.failures.failOpen...),
+			FailOpenCompilation: i.failures.failOpenCompilation,
+			ValidatorErrorCode:  connect.CodeInvalidArgument.String(),
+		},
+		Warnings: WarningConfig{
+			DryRun:          i.warnings.dryRun,
+			WarningTrailers: i.warnings.warningTrailers,
+		},
+		Limits: LimitConfig{
+			MaxMessageSize:   i.limits.maxMessageSize,
+			CollectionBudget: i.limits.collectionBudget,
+			LimitExemptions:  append([]string{}, i.limits.limitExemptions...),
+			BatchConcurrency: i.limits.batchConcurrency,
+		},
+		Streams: StreamConfig{
+			UniqueStreamFields: make([]string, len(i.streams.uniqueFields)),
+			StreamInvariants:   make([]string, len(i.streams.invariants)),
+		},
+		Hooks: []string{},
+	}
+	for n, rule := range i.policy.rules {
+		config.Policy[n] = PolicyRule{Pattern: rule.pattern, Mode: rule.mode}
+		if !rule.enforceAfter.IsZero() {
+			enforceAfter := rule.enforceAfter
+			config.Policy[n].EnforceAfter = &enforceAfter
+		}
+	}
+	for n, override := range i.procedureConfigs {
+		config.ProcedureConfigs[n] = ProcedureConfigRule{
+			Pattern:           override.pattern,
+			SkipRequests:      override.config.SkipRequests,
+			ValidateResponses: override.config.ValidateResponses,
+			FailFast:          override.config.FailFast,
+		}
+		if override.config.Code != 0 {
+			config.ProcedureConfigs[n].Code = override.config.Code.String()
+		}
+	}
+	for n, desc := range i.validators.warmup {
+		config.Validators.WarmupMessages[n] = string(desc.FullName())
+	}
+	for procedure := range i.scope.skipProcedures {
+		config.Scope.SkipProcedures = append(config.Scope.SkipProcedures, procedure)
+	}
+	sort.Strings(config.Scope.SkipProcedures)
+	for name := range i.scope.skipTypes {
+		config.Scope.SkipMessageTypes = append(config.Scope.SkipMessageTypes, string(name))
+	}
+	sort.Strings(config.Scope.SkipMessageTypes)
+	for name := range i.messages.hooksByMessage {
+		config.Messages.MessageHooks = append(config.Messages.MessageHooks, string(name))
+	}
+	sort.Strings(config.Messages.MessageHooks)
+	if i.scope.disableOption != nil {
+		config.Scope.DisableOption = string(i.scope.disableOption.extension.TypeDescriptor().FullName())
+	}
+	if i.scope.onlyTypes != nil {
+		config.Scope.OnlyMessageTypes = make([]string, 0, len(i.scope.onlyTypes))
+		for name := range i.scope.onlyTypes {
+			config.Scope.OnlyMessageTypes = append(config.Scope.OnlyMessageTypes, string(name))
+		}
+		sort.Strings(config.Scope.OnlyMessageTypes)
+	}
+	if i.scope.headerBypass != nil {
+		config.Scope.HeaderBypass = i.scope.headerBypass.header + ": " + redactedValue
+	}
+	if len(i.violations.severities) > 0 {
+		config.Violations.RuleSeverities = make(map[string]string, len(i.violations.severities))
+		for id, severity := range i.violations.severities {
+			config.Violations.RuleSeverities[id] = severity.String()
+		}
+	}
+	if i.limits.maxMessageSize > 0 {
+		config.Limits.OversizePolicy = i.limits.oversizePolicy.String()
+	}
+	if i.limits.validationTimeout > 0 {
+		config.Limits.ValidationTimeout = i.limits.validationTimeout.String()
+		config.Limits.ValidationTimeoutCode = connect.CodeResourceExhausted.String()
+		if i.limits.timeoutCode != 0 {
+			config.Limits.ValidationTimeoutCode = i.limits.timeoutCode.String()
+		}
+	}
+	for version := range i.validators.schemaVersions {
+		config.Validators.SchemaVersions = append(config.Validators.SchemaVersions, version)
+	}
+	sort.Strings(config.Validators.SchemaVersions)
+	if i.reporting.versionHeaders != nil {
+		config.Errors.SchemaVersion = i.reporting.versionHeaders.schemaVersion
+	}
+	if i.failures.validatorErrorCode != 0 {
+		config.Failures.ValidatorErrorCode = i.failures.validatorErrorCode.String()
+	}
+	config.Failures.CompilationErrorCode = firstCode(i.failures.compilationErrorCode, i.failures.validatorErrorCode, connect.CodeInternal).String()
+	config.Failures.RuntimeErrorCode = firstCode(i.failures.runtimeErrorCode, i.failures.validatorErrorCode, connect.CodeInternal).String()
+	if i.scope.sampleRate != nil {
+		config.Scope.SampleRate = min(max(*i.scope.sampleRate, 0), 1)
+	}
+	if !i.reporting.withoutDetails {
+		config.Errors.ErrorDetails = append(config.Errors.ErrorDetails, "buf.validate.Violations")
+		if i.reporting.badRequestDetails {
+			config.Errors.ErrorDetails = append(config.Errors.ErrorDetails, "google.rpc.BadRequest")
+		}
+		if i.violations.summaryThreshold > 0 {
+			config.Errors.ErrorDetails = append(config.Errors.ErrorDetails, "google.rpc.ErrorInfo ("+SummaryReason+")")
+		}
+		if i.reporting.groupedViolations {
+			config.Errors.ErrorDetails = append(config.Errors.ErrorDetails, "google.protobuf.Struct")
+		}
+		if i.reporting.maxDetailBytes > 0 {
+			config.Errors.ErrorDetails = append(config.Errors.ErrorDetails, "google.rpc.ErrorInfo ("+TruncatedReason+")")
+		}
+	}
+	if i.limits.procedureLimiter != nil {
+		config.Limits.ConcurrencyLimit = &ConcurrencyConfig{
+			PerProcedure: i.limits.procedureLimiter.limit,
+			Overflow:     i.limits.procedureLimiter.overflow.String(),
+		}
+	}
+	if i.limits.concurrencyLimiter != nil {
+		config.Limits.TotalConcurrencyLimit = &ConcurrencyConfig{
+			Total:    cap(i.limits.concurrencyLimiter.slots),
+			Overflow: i.limits.concurrencyLimiter.overflow.String(),
+		}
+	}
+	if i.telemetry.profiler != nil {
+		config.Telemetry.CostProfilingRate = i.telemetry.profiler.sampleRate
+	}
+	if i.telemetry.rollups != nil {
+		config.Telemetry.RollupWindow = i.telemetry.rollups.window.String()
+	}
+	if i.telemetry.recentRejections != nil {
+		config.Telemetry.RecentRejections = cap(i.telemetry.recentRejections.entries)
+	}
+	if i.telemetry.requestLogger != nil {
+		config.Telemetry.RequestLogRate = i.telemetry.requestLogger.sampleRate
+	}
+	for n, unique := range i.streams.uniqueFields {
+		config.Streams.UniqueStreamFields[n] = fmt.Sprintf("%s.%s", unique.msg.FullName(), unique.path)
+	}
+	for n, invariant := range i.streams.invariants {
+		config.Streams.StreamInvariants[n] = fmt.Sprintf("%s: %s", invariant.msg.FullName(), invariant.ID)
+	}
+	for _, hook := range []struct {
+		name string
+		set  bool
+	}{
+		{"validator_selector", i.validators.validatorSelector != nil},
+		{"extension_type_resolver", i.validators.extensionTypeResolver != nil},
+		{"procedure_matcher", i.scope.matcher != nil},
+		{"sampling_key", i.scope.samplingKey != nil},
+		{"clock", i.telemetry.clock != nil},
+		{"rejection_id_generator", i.reporting.rejectionIDGenerator != nil},
+		{"warn_func", i.warnings.warnFunc != nil},
+		{"warning_converter", i.warnings.warningConverter != nil},
+		{"promotion_hook", i.warnings.promotionHook != nil},
+		{"violation_filter", i.violations.violationFilter != nil},
+		{"message_translator", i.violations.messageTranslator != nil},
+		{"non_proto_fallback", i.messages.nonProtoFallback != nil},
+		{"validator_error_func", i.failures.validatorErrorFunc != nil},
+		{"header_bypass_allow", i.scope.headerBypass != nil && i.scope.headerBypass.allow != nil},
+		{"upstream_allow", i.scope.upstreamAllow != nil},
+		{"code_mapper", i.reporting.codeMapper != nil},
+		{"detail_level", i.reporting.detailLevel != nil},
+		{"peer_detail_level", i.reporting.peerDetailLevel != nil},
+		{"error_transformer", i.reporting.errorTransformer != nil},
+		{"error_contract", i.reporting.errorContract != nil},
+		{"observer", i.telemetry.observer != nil},
+		{"on_failure", i.telemetry.onFailure != nil},
+	} {
+		if hook.set {
+			config.Hooks = append(config.Hooks, hook.name)
+		}
+	}
+	return config
+}
@@ -0,0 +1,223 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	calculatorv1 "connectrpc.com/validate/internal/gen/example/calculator/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	t.Parallel()
+	t.Run("default", func(t *testing.T) {
+		t.Parallel()
+		interceptor, err := validate.NewInterceptor()
+		require.NoError(t, err)
+		got, err := json.Marshal(interceptor.EffectiveConfig())
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"nop": false,
+			"direction": "requests",
+			"streaming_responses": false,
+			"policy": [],
+			"procedure_configs": [],
+			"validators": {
+				"custom_validator": false,
+				"fail_fast": false,
+				"protovalidate_options": 0,
+				"recover": false,
+				"lazy_init": false,
+				"warmup_messages": []
+			},
+			"scope": {
+				"skip_procedures": [],
+				"skip_message_types": [],
+				"sample_rate": 1,
+				"context_bypass": false,
+				"upstream_results": false
+			},
+			"messages": {
+				"empty_messages": "validate",
+				"message_hooks": [],
+				"field_mask_validation": false,
+				"masked_validation": false
+			},
+			"violations": {
+				"sorted_violations": false,
+				"max_violations": 0,
+				"summary_threshold": 0,
+				"summary_keep": 0
+			},
+			"errors": {
+				"error_details": ["buf.validate.Violations"],
+				"without_rule_details": false,
+				"max_detail_bytes": 0,
+				"rejection_ids": false,
+				"failed_rules_header": false,
+				"version_headers": false
+			},
+			"failures": {
+				"fail_open": [],
+				"fail_open_compilation_errors": false,
+				"validator_error_code": "invalid_argument",
+				"compilation_error_code": "internal",
+				"runtime_error_code": "internal"
+			},
+			"warnings": {
+				"dry_run": false,
+				"warning_trailers": false
+			},
+			"limits": {
+				"max_message_size": 0,
+				"collection_budget": 0,
+				"limit_exemptions": [],
+				"batch_concurrency": 0
+			},
+			"streams": {
+				"unique_stream_fields": [],
+				"stream_invariants": []
+			},
+			"telemetry": {
+				"cost_profiling_rate": 0,
+				"request_log_rate": 0,
+				"recent_rejections": 0
+			},
+			"hooks": []
+		}`, string(got))
+	})
+	t.Run("configured", func(t *testing.T) {
+		t.Parallel()
+		desc := (&calculatorv1.CumSumRequest{}).ProtoReflect().Descriptor()
+		interceptor, err := validate.NewInterceptor(
+			validate.WithFailFast(),
+			validate.WithStreamingResponses(),
+			validate.WithWarmupServices("example.calculator.v1.CalculatorService"),
+			validate.WithPolicy(validate.Policy{}.
+				WarnUntil(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), "/acme.v1.UserService/*")),
+			validate.WithSkipProcedures("/acme.v1.BlobService/Upload"),
+			validate.WithProcedureConfig("/acme.v1.UserService/*", validate.ProcedureConfig{
+				ValidateResponses: true,
+				Code:              connect.CodeFailedPrecondition,
+			}),
+			validate.WithSkipMessageTypes("acme.v1.Chunk", "acme.v1.Blob"),
+			validate.WithFailOpen("/acme.v1.BlobService/*"),
+			validate.WithHeaderBypass("X-Validate", "off", func(http.Header, connect.Peer) bool { return true }),
+			validate.WithValidatorErrorCode(connect.CodeInternal),
+			validate.WithSampleRate(0.5),
+			validate.WithBadRequestDetails(),
+			validate.WithViolationSummary(20, 10),
+			validate.WithMaxDetailBytes(4096),
+			validate.WithProcedureConcurrencyLimit(8, validate.OverflowSkip),
+			validate.WithUniqueStreamField(desc, "number"),
+			validate.WithStreamInvariants(desc, validate.StreamInvariant{ID: "increasing", Expression: "true"}),
+			validate.WithWarnFunc(func(context.Context, connect.Spec, error) {}),
+		)
+		require.NoError(t, err)
+		got, err := json.Marshal(interceptor.EffectiveConfig())
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"nop": false,
+			"direction": "requests",
+			"streaming_responses": true,
+			"policy": [
+				{"pattern": "/acme.v1.UserService/*", "mode": "warn", "enforce_after": "2030-01-01T00:00:00Z"}
+			],
+			"procedure_configs": [
+				{"pattern": "/acme.v1.UserService/*", "skip_requests": false, "validate_responses": true, "code": "failed_precondition", "fail_fast": false}
+			],
+			"validators": {
+				"custom_validator": false,
+				"fail_fast": true,
+				"protovalidate_options": 0,
+				"recover": false,
+				"lazy_init": false,
+				"warmup_messages": [
+					"example.calculator.v1.CumSumRequest",
+					"example.calculator.v1.CumSumResponse"
+				]
+			},
+			"scope": {
+				"skip_procedures": ["/acme.v1.BlobService/Upload"],
+				"skip_message_types": ["acme.v1.Blob", "acme.v1.Chunk"],
+				"sample_rate": 0.5,
+				"context_bypass": false,
+				"header_bypass": "X-Validate: [REDACTED]",
+				"upstream_results": false
+			},
+			"messages": {
+				"empty_messages": "validate",
+				"message_hooks": [],
+				"field_mask_validation": false,
+				"masked_validation": false
+			},
+			"violations": {
+				"sorted_violations": false,
+				"max_violations": 0,
+				"summary_threshold": 20,
+				"summary_keep": 10
+			},
+			"errors": {
+				"error_details": [
+					"buf.validate.Violations",
+					"google.rpc.BadRequest",
+					"google.rpc.ErrorInfo (VIOLATIONS_SUMMARIZED)",
+					"google.rpc.ErrorInfo (VIOLATIONS_TRUNCATED)"
+				],
+				"without_rule_details": false,
+				"max_detail_bytes": 4096,
+				"rejection_ids": false,
+				"failed_rules_header": false,
+				"version_headers": false
+			},
+			"failures": {
+				"fail_open": ["/acme.v1.BlobService/*"],
+				"fail_open_compilation_errors": false,
+				"validator_error_code": "internal",
+				"compilation_error_code": "internal",
+				"runtime_error_code": "internal"
+			},
+			"warnings": {
+				"dry_run": false,
+				"warning_trailers": false
+			},
+			"limits": {
+				"max_message_size": 0,
+				"collection_budget": 0,
+				"limit_exemptions": [],
+				"concurrency_limit": {"per_procedure": 8, "overflow": "skip"},
+				"batch_concurrency": 0
+			},
+			"streams": {
+				"unique_stream_fields": ["example.calculator.v1.CumSumRequest.number"],
+				"stream_invariants": ["example.calculator.v1.CumSumRequest: increasing"]
+			},
+			"telemetry": {
+				"cost_profiling_rate": 0,
+				"request_log_rate": 0,
+				"recent_rejections": 0
+			},
+			"hooks": ["warn_func", "header_bypass_allow"]
+		}`, string(got))
+	})
+}
@@ -0,0 +1,107 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+)
+
+// An ErrorContract declares the error codes each procedure's handler may
+// return. See [WithErrorContract].
+type ErrorContract struct {
+	// Allowed returns the codes a procedure's handler may return. If it
+	// returns nil, the handler may return any code. The spec's Schema is the
+	// method's descriptor, so the codes can be derived from custom method
+	// options.
+	Allowed func(connect.Spec) []connect.Code
+	// Enforce replaces out-of-contract errors with errors coded
+	// [connect.CodeInternal], which wrap the original. Otherwise, they're
+	// only reported.
+	Enforce bool
+	// Report, if set, is called with every out-of-contract error.
+	Report func(context.Context, connect.Spec, *connect.Error)
+}
+
+// WithErrorContract configures the [Interceptor] to check that handlers only
+// return the error codes their procedures allow, extending validation from
+// the data an API accepts to the contract it promises its clients. Errors
+// that aren't [connect.Error] values have [connect.CodeUnknown]. Errors for
+// invalid messages, which handlers of streaming procedures may return from
+// Receive, are always allowed.
+//
+// Contracts are only checked for handlers, whether or not their messages are
+// validated.
+func WithErrorContract(contract ErrorContract) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.errorContract = &contract
+	})
+}
+
+// wrapUnaryContract checks the errors returned by a unary handler.
+func (i *Interceptor) wrapUnaryContract(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		res, err := next(ctx, req)
+		if err != nil && !req.Spec().IsClient {
+			err = i.checkErrorContract(ctx, req.Spec(), err)
+		}
+		return res, err
+	}
+}
+
+// wrapStreamingContract checks the errors returned by a streaming handler.
+func (i *Interceptor) wrapStreamingContract(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if err := next(ctx, conn); err != nil {
+			return i.checkErrorContract(ctx, conn.Spec(), err)
+		}
+		return nil
+	}
+}
+
+// checkErrorContract returns err, or its replacement if it's out of contract
+// and the contract is enforced.
+func (i *Interceptor) checkErrorContract(ctx context.Context, spec connect.Spec, err error) error {
+	if validationErr := new(protovalidate.ValidationError); errors.As(err, &validationErr) {
+		return err
+	}
+	if i.reporting.errorContract.Allowed == nil {
+		return err
+	}
+	allowed := i.reporting.errorContract.Allowed(spec)
+	if allowed == nil {
+		return err
+	}
+	code := connect.CodeOf(err)
+	if slices.Contains(allowed, code) {
+		return err
+	}
+	connectErr := new(connect.Error)
+	if !errors.As(err, &connectErr) {
+		connectErr = connect.NewError(code, err)
+	}
+	if i.reporting.errorContract.Report != nil {
+		i.reporting.errorContract.Report(ctx, spec, connectErr)
+	}
+	if !i.reporting.errorContract.Enforce {
+		return err
+	}
+	return connect.NewError(connect.CodeInternal, fmt.Errorf("%s returned out-of-contract code %s: %w", spec.Procedure, code, err))
+}
@@ -0,0 +1,101 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithErrorContract(t *testing.T) {
+	t.Parallel()
+	allowed := func(connect.Spec) []connect.Code {
+		return []connect.Code{connect.CodeNotFound, connect.CodeAlreadyExists}
+	}
+	tests := []struct {
+		name       string
+		enforce    bool
+		handlerErr error
+		wantCode   connect.Code
+		wantReport connect.Code
+	}{
+		{
+			name:       "allowed",
+			handlerErr: connect.NewError(connect.CodeNotFound, errors.New("no such user")),
+			wantCode:   connect.CodeNotFound,
+		},
+		{
+			name:       "reported",
+			handlerErr: connect.NewError(connect.CodePermissionDenied, errors.New("not yours")),
+			wantCode:   connect.CodePermissionDenied,
+			wantReport: connect.CodePermissionDenied,
+		},
+		{
+			name:       "enforced",
+			enforce:    true,
+			handlerErr: connect.NewError(connect.CodePermissionDenied, errors.New("not yours")),
+			wantCode:   connect.CodeInternal,
+			wantReport: connect.CodePermissionDenied,
+		},
+		{
+			name:       "not_connect_error",
+			enforce:    true,
+			handlerErr: errors.New("oops"),
+			wantCode:   connect.CodeInternal,
+			wantReport: connect.CodeUnknown,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var reported []connect.Code
+			interceptor, err := validate.NewInterceptor(validate.WithErrorContract(validate.ErrorContract{
+				Allowed: allowed,
+				Enforce: test.enforce,
+				Report: func(_ context.Context, _ connect.Spec, err *connect.Error) {
+					reported = append(reported, err.Code())
+				},
+			}))
+			require.NoError(t, err)
+			call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return nil, test.handlerErr
+			})
+			_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+			assert.Equal(t, test.wantCode, connect.CodeOf(err))
+			assert.ErrorIs(t, err, test.handlerErr)
+			if test.wantReport == 0 {
+				assert.Empty(t, reported)
+			} else {
+				assert.Equal(t, []connect.Code{test.wantReport}, reported)
+			}
+
+			// The Interceptor's own rejections are always allowed.
+			reported = nil
+			_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+				User: &userv1.User{Email: "foo"},
+			}))
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			assert.Empty(t, reported)
+		})
+	}
+}
@@ -0,0 +1,412 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// WithBadRequestDetails configures the [Interceptor] to attach a
+// [google.rpc.BadRequest] detail to errors for invalid messages, in addition
+// to the usual buf.validate.Violations detail. Many gRPC ecosystem clients and
+// gateways understand BadRequest but not protovalidate's own types. Each
+// violation becomes a FieldViolation, with the violation's field path and
+// message.
+//
+// [google.rpc.BadRequest]: https://pkg.go.dev/google.golang.org/genproto/googleapis/rpc/errdetails#BadRequest
+func WithBadRequestDetails() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.badRequestDetails = true
+	})
+}
+
+// WithGroupedViolations configures the [Interceptor] to attach a
+// [google.protobuf.Struct] detail to errors for invalid messages, in addition
+// to the usual buf.validate.Violations detail, which groups the violations by
+// field. Each key is a field path, and its value lists the field's
+// violations, in order, as objects with "constraint_id" and "message" keys.
+// Violations of message-level constraints are grouped under the empty path.
+// Form libraries can then map each input to its errors without grouping them
+// in every client. If the error reveals only field paths (see
+// [WithDetailLevel]), the lists are empty.
+//
+// [google.protobuf.Struct]: https://pkg.go.dev/google.golang.org/protobuf/types/known/structpb#Struct
+func WithGroupedViolations() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.groupedViolations = true
+	})
+}
+
+// WithoutRuleDetails configures the [Interceptor] to clear the rule and
+// constraint ID of each violation before it's attached to errors as a
+// detail, so that clients can't learn about the constraints, including
+// custom CEL constraints, declared in internal schemas. Violations keep their
+// field path and message. The error's message omits constraint IDs too,
+// grouped violations (see [WithGroupedViolations]) omit their
+// "constraint_id", summaries count violations by field alone, and the
+// [FailedRulesHeader] is never set.
+func WithoutRuleDetails() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.withoutRules = true
+	})
+}
+
+// TruncatedReason is the reason of the [google.rpc.ErrorInfo] detail that
+// marks truncated error details. See [WithMaxDetailBytes].
+//
+// [google.rpc.ErrorInfo]: https://pkg.go.dev/google.golang.org/genproto/googleapis/rpc/errdetails#ErrorInfo
+const TruncatedReason = "VIOLATIONS_TRUNCATED"
+
+// WithMaxDetailBytes configures the [Interceptor] to keep the error details
+// of invalid messages within n bytes once serialized, so that errors can't
+// grow past the body limits of proxies and be dropped entirely. If the
+// details are larger, violations are dropped from the end of every detail
+// until they fit, and a [google.rpc.ErrorInfo] detail with the reason
+// [TruncatedReason] is added. Its metadata holds the "total" number of
+// violations and the number "reported". The limit applies to details only:
+// the error's message still lists every violation, unless it's bounded with
+// [WithMaxViolations] or hidden (see [WithDetailLevel]). Non-positive limits
+// disable truncation.
+//
+// [google.rpc.ErrorInfo]: https://pkg.go.dev/google.golang.org/genproto/googleapis/rpc/errdetails#ErrorInfo
+func WithMaxDetailBytes(n int) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.maxDetailBytes = n
+	})
+}
+
+// FailedRulesHeader is the error metadata key that lists the constraints an
+// invalid message failed. See [WithFailedRulesHeader].
+const FailedRulesHeader = "Validation-Failed-Rules"
+
+// WithFailedRulesHeader configures the [Interceptor] to list the IDs of the
+// failed constraints in the [FailedRulesHeader] error metadata, separated by
+// commas and without duplicates, as in "string.email,user.handle". L7 proxies
+// and edge logs can classify rejections by reading it, without parsing
+// Protobuf error details. The metadata reflects the violations reported in
+// the error, so it's omitted when the error only reveals field paths or
+// nothing at all (see [WithDetailLevel]).
+func WithFailedRulesHeader() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.failedRulesHeader = true
+	})
+}
+
+// WithErrorMessage configures the [Interceptor] to use message as the
+// message of every error for an invalid message, as in "request failed
+// validation", while the error details still describe each violation for
+// programs. Clients that display errors verbatim then never show end users
+// the free-form violation messages of protovalidate or custom constraints.
+// The message also replaces the terse message of [WithoutErrorDetails]. An
+// empty message restores the default.
+func WithErrorMessage(message string) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.errorMessage = message
+	})
+}
+
+// WithoutErrorDetails configures the [Interceptor] to return terse errors for
+// invalid messages, which don't reveal field paths or constraint IDs to
+// clients. The errors have no details attached, even with
+// [WithBadRequestDetails], and their message is only "invalid request". It's
+// meant for APIs exposed to the public internet.
+//
+// On the server, the error still wraps the [protovalidate.ValidationError], so
+// hooks like [WithWarnFunc] and the logger configured with
+// [WithInvalidRequestLogger] see every violation.
+func WithoutErrorDetails() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.withoutDetails = true
+	})
+}
+
+// A DetailLevel controls how much of a validation error is revealed to a
+// client. See [WithDetailLevel].
+type DetailLevel int
+
+const (
+	// DetailFull reveals every violation, with the configured details. It's
+	// the default.
+	DetailFull DetailLevel = iota
+	// DetailFieldPaths reveals only the paths of the invalid fields, without
+	// constraint IDs or messages.
+	DetailFieldPaths
+	// DetailNone reveals nothing, like [WithoutErrorDetails].
+	DetailNone
+)
+
+// WithDetailLevel configures the [Interceptor] to choose how much of each
+// validation error to reveal, based on the caller's audience. For example,
+// first-party callers might see full violations while third-party callers
+// see only field paths, so one Interceptor can serve both safely. The
+// function is called with the context and [connect.Spec] of the RPC, so
+// callers are usually identified by authentication middleware that stores
+// their identity in the context. [WithoutErrorDetails] takes precedence.
+func WithDetailLevel(audience func(context.Context, connect.Spec) DetailLevel) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.detailLevel = audience
+	})
+}
+
+// terseError hides a validation error's message, but not the error itself.
+type terseError struct {
+	err *protovalidate.ValidationError
+}
+
+func (e *terseError) Error() string { return "invalid request" }
+
+func (e *terseError) Unwrap() error { return e.err }
+
+// fieldPathsError reveals only the paths of a validation error's invalid
+// fields.
+type fieldPathsError struct {
+	err *protovalidate.ValidationError
+}
+
+func (e *fieldPathsError) Error() string {
+	paths := make([]string, len(e.err.Violations))
+	for i, violation := range e.err.Violations {
+		paths[i] = protovalidate.FieldPathString(violation.Proto.GetField())
+	}
+	return "invalid fields: " + strings.Join(paths, ", ")
+}
+
+func (e *fieldPathsError) Unwrap() error { return e.err }
+
+// fixedMessageError replaces a validation error's message.
+type fixedMessageError struct {
+	message string
+	err     *protovalidate.ValidationError
+}
+
+func (e *fixedMessageError) Error() string { return e.message }
+
+func (e *fixedMessageError) Unwrap() error { return e.err }
+
+// withoutRulesError reveals a validation error's field paths and messages,
+// without constraint IDs.
+type withoutRulesError struct {
+	err *protovalidate.ValidationError
+}
+
+func (e *withoutRulesError) Error() string {
+	var builder strings.Builder
+	builder.WriteString("validation error:")
+	for _, violation := range e.err.Violations {
+		builder.WriteString("\n - ")
+		if path := protovalidate.FieldPathString(violation.Proto.GetField()); path != "" {
+			builder.WriteString(path)
+			builder.WriteString(": ")
+		}
+		builder.WriteString(violation.Proto.GetMessage())
+	}
+	return builder.String()
+}
+
+func (e *withoutRulesError) Unwrap() error { return e.err }
+
+// detailLevelFor returns how much of a validation error to reveal to the
+// caller of an RPC.
+func (i *Interceptor) detailLevelFor(ctx context.Context, spec connect.Spec) DetailLevel {
+	level := DetailFull
+	if i.reporting.withoutDetails {
+		level = DetailNone
+	} else if i.reporting.detailLevel != nil {
+		level = i.reporting.detailLevel(ctx, spec)
+	}
+	if peerLevel, ok := i.peerDetailLevelOf(ctx); ok {
+		level = max(level, peerLevel)
+	}
+	return level
+}
+
+// newError builds the error for an invalid message, with the details
+// configured for the RPC's audience attached. The summary is nil unless the
+// violations were summarized.
+func (i *Interceptor) newError(ctx context.Context, spec connect.Spec, code connect.Code, validationErr *protovalidate.ValidationError, summary *violationSummary) *connect.Error {
+	level := i.detailLevelFor(ctx, spec)
+	var cause error
+	switch level {
+	case DetailFull:
+		cause = validationErr
+		if i.reporting.withoutRules {
+			cause = &withoutRulesError{err: validationErr}
+		}
+	case DetailFieldPaths:
+		cause = &fieldPathsError{err: validationErr}
+	default:
+		cause = &terseError{err: validationErr}
+	}
+	if i.reporting.errorMessage != "" {
+		cause = &fixedMessageError{message: i.reporting.errorMessage, err: validationErr}
+	}
+	connectErr := connect.NewError(code, cause)
+	switch level {
+	case DetailFull:
+		i.addDetails(connectErr, validationErr, summary, false)
+		if i.reporting.failedRulesHeader && !i.reporting.withoutRules {
+			if rules := failedRules(validationErr); rules != "" {
+				connectErr.Meta().Set(FailedRulesHeader, rules)
+			}
+		}
+	case DetailFieldPaths:
+		i.addDetails(connectErr, validationErr, summary, true)
+	}
+	return connectErr
+}
+
+// addDetails attaches the configured error details to connectErr. If
+// pathsOnly is set, the details only reveal field paths.
+func (i *Interceptor) addDetails(connectErr *connect.Error, validationErr *protovalidate.ValidationError, summary *violationSummary, pathsOnly bool) {
+	details := i.buildDetails(validationErr, summary, pathsOnly)
+	if i.reporting.maxDetailBytes > 0 && detailsSize(details) > i.reporting.maxDetailBytes {
+		details = i.truncateDetails(validationErr, summary, pathsOnly)
+	}
+	for _, msg := range details {
+		if detail, err := connect.NewErrorDetail(msg); err == nil {
+			connectErr.AddDetail(detail)
+		}
+	}
+}
+
+func (i *Interceptor) buildDetails(validationErr *protovalidate.ValidationError, summary *violationSummary, pathsOnly bool) []proto.Message {
+	violations := validationErr.ToProto()
+	badRequest := toBadRequest(validationErr)
+	if pathsOnly {
+		for n, violation := range violations.GetViolations() {
+			violations.Violations[n] = &validatepb.Violation{Field: violation.GetField()}
+		}
+		for _, violation := range badRequest.GetFieldViolations() {
+			violation.Description = ""
+		}
+	} else if i.reporting.withoutRules {
+		for n, violation := range violations.GetViolations() {
+			violations.Violations[n] = &validatepb.Violation{
+				Field:   violation.GetField(),
+				Message: violation.Message,
+				ForKey:  violation.ForKey,
+			}
+		}
+	}
+	details := []proto.Message{violations}
+	if i.reporting.badRequestDetails {
+		details = append(details, badRequest)
+	}
+	if summary != nil {
+		details = append(details, summary.toErrorInfo(pathsOnly || i.reporting.withoutRules))
+	}
+	if i.reporting.groupedViolations {
+		details = append(details, groupViolations(validationErr, pathsOnly, i.reporting.withoutRules))
+	}
+	return details
+}
+
+// truncateDetails builds the details with as many violations as fit in the
+// limit configured with WithMaxDetailBytes, followed by a truncation marker.
+func (i *Interceptor) truncateDetails(validationErr *protovalidate.ValidationError, summary *violationSummary, pathsOnly bool) []proto.Message {
+	total := len(validationErr.Violations)
+	build := func(reported int) []proto.Message {
+		trimmed := &protovalidate.ValidationError{Violations: validationErr.Violations[:reported]}
+		return append(i.buildDetails(trimmed, summary, pathsOnly), &errdetails.ErrorInfo{
+			Reason: TruncatedReason,
+			Domain: summaryDomain,
+			Metadata: map[string]string{
+				"total":    strconv.Itoa(total),
+				"reported": strconv.Itoa(reported),
+			},
+		})
+	}
+	reported := sort.Search(total, func(n int) bool {
+		return detailsSize(build(n+1)) > i.reporting.maxDetailBytes
+	})
+	return build(reported)
+}
+
+// detailsSize returns the serialized size of details, as they're encoded in
+// errors.
+func detailsSize(details []proto.Message) int {
+	var size int
+	for _, msg := range details {
+		if encoded, err := anypb.New(msg); err == nil {
+			size += proto.Size(encoded)
+		}
+	}
+	return size
+}
+
+// failedRules lists the distinct constraint IDs of err's violations, in order
+// of appearance.
+func failedRules(err *protovalidate.ValidationError) string {
+	var ids []string
+	for _, violation := range err.Violations {
+		if id := violation.Proto.GetConstraintId(); id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// groupViolations groups the violations of err by field path. If pathsOnly is
+// set, the groups are empty, and if withoutRules is set, they omit constraint
+// IDs.
+func groupViolations(err *protovalidate.ValidationError, pathsOnly, withoutRules bool) *structpb.Struct {
+	groups := make(map[string]*structpb.ListValue)
+	for _, violation := range err.Violations {
+		path := protovalidate.FieldPathString(violation.Proto.GetField())
+		group, ok := groups[path]
+		if !ok {
+			group = &structpb.ListValue{}
+			groups[path] = group
+		}
+		if pathsOnly {
+			continue
+		}
+		fields := map[string]*structpb.Value{
+			"message": structpb.NewStringValue(violation.Proto.GetMessage()),
+		}
+		if !withoutRules {
+			fields["constraint_id"] = structpb.NewStringValue(violation.Proto.GetConstraintId())
+		}
+		group.Values = append(group.Values, structpb.NewStructValue(&structpb.Struct{Fields: fields}))
+	}
+	grouped := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(groups))}
+	for path, group := range groups {
+		grouped.Fields[path] = structpb.NewListValue(group)
+	}
+	return grouped
+}
+
+func toBadRequest(err *protovalidate.ValidationError) *errdetails.BadRequest {
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(err.Violations))
+	for i, violation := range err.Violations {
+		violations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       protovalidate.FieldPathString(violation.Proto.GetField()),
+			Description: violation.Proto.GetMessage(),
+		}
+	}
+	return &errdetails.BadRequest{FieldViolations: violations}
+}
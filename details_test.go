@@ -0,0 +1,389 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestWithBadRequestDetails(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithBadRequestDetails())
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(t, mux)
+
+	_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+		CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "foo"},
+		}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	details := connectErr.Details()
+	require.Len(t, details, 2)
+
+	first, err := details[0].Value()
+	require.NoError(t, err)
+	violations, ok := first.(*validatepb.Violations)
+	require.True(t, ok)
+	require.Len(t, violations.GetViolations(), 1)
+
+	second, err := details[1].Value()
+	require.NoError(t, err)
+	badRequest, ok := second.(*errdetails.BadRequest)
+	require.True(t, ok)
+	require.Len(t, badRequest.GetFieldViolations(), 1)
+	fieldViolation := badRequest.GetFieldViolations()[0]
+	assert.Equal(t, "user.email", fieldViolation.GetField())
+	assert.Equal(t, violations.GetViolations()[0].GetMessage(), fieldViolation.GetDescription())
+}
+
+func TestWithoutErrorDetails(t *testing.T) {
+	t.Parallel()
+	warnings := make(chan error, 1)
+	interceptor, err := validate.NewInterceptor(
+		validate.WithoutErrorDetails(),
+		validate.WithBadRequestDetails(),
+		validate.WithWarnFunc(func(_ context.Context, _ connect.Spec, err error) {
+			warnings <- err
+		}),
+		validate.WithPolicy(validate.Policy{}.Warn(userv1connect.UserServiceUpdateUserProcedure)),
+	)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	mux.Handle(userv1connect.UserServiceUpdateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceUpdateUserProcedure,
+		updateUser,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(t, mux)
+	client := userv1connect.NewUserServiceClient(srv.Client(), srv.URL)
+
+	invalid := &userv1.User{Email: "foo"}
+	_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: invalid}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	assert.Equal(t, "invalid request", connectErr.Message())
+	assert.Empty(t, connectErr.Details())
+
+	// Server-side hooks still see the violations.
+	_, err = client.UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{User: invalid}))
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	var validationErr *protovalidate.ValidationError
+	require.ErrorAs(t, <-warnings, &validationErr)
+	assert.Len(t, validationErr.Violations, 1)
+}
+
+func TestWithDetailLevel(t *testing.T) {
+	t.Parallel()
+	type audienceKey struct{}
+	interceptor, err := validate.NewInterceptor(
+		validate.WithBadRequestDetails(),
+		validate.WithDetailLevel(func(ctx context.Context, _ connect.Spec) validate.DetailLevel {
+			level, _ := ctx.Value(audienceKey{}).(validate.DetailLevel)
+			return level
+		}),
+	)
+	require.NoError(t, err)
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	req := connect.NewRequest(&userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}})
+
+	tests := []struct {
+		name        string
+		level       validate.DetailLevel
+		wantMessage string
+		wantDetails int
+	}{
+		{
+			name:        "full",
+			level:       validate.DetailFull,
+			wantMessage: "validation error:\n - user.email: value must be a valid email address [string.email]",
+			wantDetails: 2,
+		},
+		{
+			name:        "field_paths",
+			level:       validate.DetailFieldPaths,
+			wantMessage: "invalid fields: user.email",
+			wantDetails: 2,
+		},
+		{
+			name:        "none",
+			level:       validate.DetailNone,
+			wantMessage: "invalid request",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.WithValue(context.Background(), audienceKey{}, test.level)
+			_, err := interceptor.WrapUnary(next)(ctx, req)
+			require.Error(t, err)
+			var connectErr *connect.Error
+			require.ErrorAs(t, err, &connectErr)
+			assert.Equal(t, connect.CodeInvalidArgument, connectErr.Code())
+			assert.Equal(t, test.wantMessage, connectErr.Message())
+			// Every level keeps the violations on the server.
+			assert.ErrorAs(t, err, new(*protovalidate.ValidationError))
+			details := connectErr.Details()
+			require.Len(t, details, test.wantDetails)
+			if test.level != validate.DetailFieldPaths {
+				return
+			}
+			first, err := details[0].Value()
+			require.NoError(t, err)
+			violations, ok := first.(*validatepb.Violations)
+			require.True(t, ok)
+			require.Len(t, violations.GetViolations(), 1)
+			assert.Equal(t, "user.email", protovalidate.FieldPathString(violations.GetViolations()[0].GetField()))
+			assert.Empty(t, violations.GetViolations()[0].GetConstraintId())
+			assert.Empty(t, violations.GetViolations()[0].GetMessage())
+			second, err := details[1].Value()
+			require.NoError(t, err)
+			badRequest, ok := second.(*errdetails.BadRequest)
+			require.True(t, ok)
+			require.Len(t, badRequest.GetFieldViolations(), 1)
+			assert.Empty(t, badRequest.GetFieldViolations()[0].GetDescription())
+		})
+	}
+}
+
+func TestWithFailedRulesHeader(t *testing.T) {
+	t.Parallel()
+	type audienceKey struct{}
+	interceptor, err := validate.NewInterceptor(
+		validate.WithFailedRulesHeader(),
+		validate.WithDetailLevel(func(ctx context.Context, _ connect.Spec) validate.DetailLevel {
+			level, _ := ctx.Value(audienceKey{}).(validate.DetailLevel)
+			return level
+		}),
+	)
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo", Handle: "admin1"},
+	}))
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	assert.Equal(t, "string.email,user.handle", connectErr.Meta().Get(validate.FailedRulesHeader))
+
+	// Rules aren't revealed to audiences that only see field paths.
+	ctx := context.WithValue(context.Background(), audienceKey{}, validate.DetailFieldPaths)
+	_, err = call(ctx, connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	}))
+	require.ErrorAs(t, err, &connectErr)
+	assert.Empty(t, connectErr.Meta().Values(validate.FailedRulesHeader))
+}
+
+func TestWithGroupedViolations(t *testing.T) {
+	t.Parallel()
+	name := &validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("name")}
+	validator := staticValidator{err: &protovalidate.ValidationError{Violations: []*validate.Violation{
+		newViolation("string.min_len", name),
+		newViolation("message.cel"),
+		newViolation("string.pattern", name),
+	}}}
+	interceptor, err := validate.NewInterceptor(
+		validate.WithValidator(validator),
+		validate.WithGroupedViolations(),
+	)
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	details := connectErr.Details()
+	require.Len(t, details, 2)
+	value, err := details[1].Value()
+	require.NoError(t, err)
+	grouped, ok := value.(*structpb.Struct)
+	require.True(t, ok)
+	assert.Equal(t, map[string]any{
+		"name": []any{
+			map[string]any{"constraint_id": "string.min_len", "message": "invalid"},
+			map[string]any{"constraint_id": "string.pattern", "message": "invalid"},
+		},
+		"": []any{
+			map[string]any{"constraint_id": "message.cel", "message": "invalid"},
+		},
+	}, grouped.AsMap())
+}
+
+func TestWithMaxDetailBytes(t *testing.T) {
+	t.Parallel()
+	name := &validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("name")}
+	violations := make([]*validate.Violation, 100)
+	for n := range violations {
+		violations[n] = newViolation("string.pattern", name)
+	}
+	validator := staticValidator{err: &protovalidate.ValidationError{Violations: violations}}
+	handler := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	detailsOf := func(t *testing.T, maxBytes int) []*connect.ErrorDetail {
+		t.Helper()
+		interceptor, err := validate.NewInterceptor(
+			validate.WithValidator(validator),
+			validate.WithMaxDetailBytes(maxBytes),
+		)
+		require.NoError(t, err)
+		_, err = interceptor.WrapUnary(handler)(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+		var connectErr *connect.Error
+		require.ErrorAs(t, err, &connectErr)
+		return connectErr.Details()
+	}
+	t.Run("fits", func(t *testing.T) {
+		t.Parallel()
+		details := detailsOf(t, 1<<20)
+		require.Len(t, details, 1)
+		value, err := details[0].Value()
+		require.NoError(t, err)
+		got, ok := value.(*validatepb.Violations)
+		require.True(t, ok)
+		assert.Len(t, got.GetViolations(), 100)
+	})
+	t.Run("truncated", func(t *testing.T) {
+		t.Parallel()
+		const maxBytes = 512
+		details := detailsOf(t, maxBytes)
+		require.Len(t, details, 2)
+		var size int
+		for _, detail := range details {
+			size += len(detail.Bytes())
+		}
+		assert.LessOrEqual(t, size, maxBytes)
+
+		value, err := details[0].Value()
+		require.NoError(t, err)
+		got, ok := value.(*validatepb.Violations)
+		require.True(t, ok)
+		reported := len(got.GetViolations())
+		assert.Positive(t, reported)
+		assert.Less(t, reported, 100)
+
+		value, err = details[1].Value()
+		require.NoError(t, err)
+		info, ok := value.(*errdetails.ErrorInfo)
+		require.True(t, ok)
+		assert.Equal(t, validate.TruncatedReason, info.GetReason())
+		assert.Equal(t, "100", info.GetMetadata()["total"])
+		assert.Equal(t, strconv.Itoa(reported), info.GetMetadata()["reported"])
+	})
+}
+
+func TestWithoutRuleDetails(t *testing.T) {
+	t.Parallel()
+	name := &validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("name")}
+	violation := newViolation("name.not_admin", name)
+	violation.Proto.Rule = &validatepb.FieldPath{Elements: []*validatepb.FieldPathElement{
+		{FieldNumber: proto.Int32(23), FieldName: proto.String("cel")},
+	}}
+	validator := staticValidator{err: &protovalidate.ValidationError{Violations: []*validate.Violation{violation}}}
+	interceptor, err := validate.NewInterceptor(
+		validate.WithValidator(validator),
+		validate.WithoutRuleDetails(),
+		validate.WithGroupedViolations(),
+		validate.WithFailedRulesHeader(),
+	)
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	assert.Equal(t, "validation error:\n - name: invalid", connectErr.Message())
+	assert.Empty(t, connectErr.Meta().Get(validate.FailedRulesHeader))
+	details := connectErr.Details()
+	require.Len(t, details, 2)
+
+	value, err := details[0].Value()
+	require.NoError(t, err)
+	violations, ok := value.(*validatepb.Violations)
+	require.True(t, ok)
+	require.Len(t, violations.GetViolations(), 1)
+	got := violations.GetViolations()[0]
+	assert.Equal(t, "name", protovalidate.FieldPathString(got.GetField()))
+	assert.Equal(t, "invalid", got.GetMessage())
+	assert.Empty(t, got.GetConstraintId())
+	assert.Nil(t, got.GetRule())
+
+	value, err = details[1].Value()
+	require.NoError(t, err)
+	grouped, ok := value.(*structpb.Struct)
+	require.True(t, ok)
+	assert.Equal(t, map[string]any{
+		"name": []any{map[string]any{"message": "invalid"}},
+	}, grouped.AsMap())
+
+	// The server still sees every violation.
+	var validationErr *protovalidate.ValidationError
+	require.ErrorAs(t, connectErr, &validationErr)
+	assert.Equal(t, "name.not_admin", validationErr.Violations[0].Proto.GetConstraintId())
+}
+
+func TestWithErrorMessage(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithErrorMessage("request failed validation"))
+	require.NoError(t, err)
+	_, err = newUserClient(t, interceptor).CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	assert.Equal(t, "request failed validation", connectErr.Message())
+	violation := requireSingleViolation(t, connectErr)
+	assert.Equal(t, "string.email", violation.GetConstraintId())
+}
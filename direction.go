@@ -0,0 +1,69 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import "fmt"
+
+// A Direction chooses which messages the [Interceptor] validates. See
+// [WithDirection].
+type Direction int
+
+const (
+	// DirectionRequests validates requests: the messages clients send and
+	// handlers receive. It's the default.
+	DirectionRequests Direction = iota
+	// DirectionResponses validates responses: the messages handlers send and
+	// clients receive.
+	DirectionResponses
+	// DirectionBoth validates requests and responses.
+	DirectionBoth
+)
+
+// String implements [fmt.Stringer].
+func (d Direction) String() string {
+	switch d {
+	case DirectionRequests:
+		return "requests"
+	case DirectionResponses:
+		return "responses"
+	case DirectionBoth:
+		return "both"
+	}
+	return fmt.Sprintf("direction_%d", int(d))
+}
+
+func (d Direction) requests() bool {
+	return d == DirectionRequests || d == DirectionBoth
+}
+
+func (d Direction) responses() bool {
+	return d == DirectionResponses || d == DirectionBoth
+}
+
+// WithDirection configures the [Interceptor] to validate requests, responses,
+// or both, uniformly across unary and streaming RPCs. On handlers, an invalid
+// response isn't sent: the RPC fails with the same error an invalid request
+// would produce, or, on streams, Send returns it and the handler decides how
+// to end the stream. On clients, an invalid response is returned as an error
+// instead of the response, or from Receive on streams.
+//
+// [WithStreamingResponses] and the ValidateResponses field of
+// [ProcedureConfig] add response validation for handler streams regardless of
+// the direction, and the SkipRequests field skips requests.
+func WithDirection(direction Direction) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.direction = direction
+	})
+}
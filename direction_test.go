@@ -0,0 +1,91 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	calculatorv1 "connectrpc.com/validate/internal/gen/example/calculator/v1"
+	"connectrpc.com/validate/internal/gen/example/calculator/v1/calculatorv1connect"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDirection(t *testing.T) {
+	t.Parallel()
+	valid := &userv1.User{Email: "someone@example.com"}
+	invalid := &userv1.User{Email: "foo"}
+	tests := []struct {
+		direction validate.Direction
+		req, res  *userv1.User
+		wantErr   bool
+	}{
+		{direction: validate.DirectionRequests, req: invalid, res: valid, wantErr: true},
+		{direction: validate.DirectionRequests, req: valid, res: invalid},
+		{direction: validate.DirectionResponses, req: invalid, res: valid},
+		{direction: validate.DirectionResponses, req: valid, res: invalid, wantErr: true},
+		{direction: validate.DirectionBoth, req: invalid, res: valid, wantErr: true},
+		{direction: validate.DirectionBoth, req: valid, res: invalid, wantErr: true},
+	}
+	for _, test := range tests {
+		interceptor, err := validate.NewInterceptor(validate.WithDirection(test.direction))
+		require.NoError(t, err)
+		res := test.res
+		call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+			return connect.NewResponse(&userv1.CreateUserResponse{User: res}), nil
+		})
+		_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: test.req}))
+		if test.wantErr {
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err), test.direction.String())
+		} else {
+			assert.NoError(t, err, test.direction.String())
+		}
+	}
+}
+
+func TestWithDirectionStreamingClient(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithDirection(validate.DirectionResponses))
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	mux.Handle(calculatorv1connect.CalculatorServiceCumSumProcedure, connect.NewBidiStreamHandler(
+		calculatorv1connect.CalculatorServiceCumSumProcedure,
+		cumSumSuccess,
+	))
+	srv := httptest.NewUnstartedServer(mux)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	client := calculatorv1connect.NewCalculatorServiceClient(srv.Client(), srv.URL, connect.WithInterceptors(interceptor))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+
+	// The request is invalid, but only the response, a negative sum, is
+	// validated.
+	stream := client.CumSum(ctx)
+	require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: -1}))
+	_, err = stream.Receive()
+	require.Error(t, err)
+	assert.Equal(t, "int64.gte", requireSingleViolation(t, err).GetConstraintId())
+	require.NoError(t, stream.CloseRequest())
+	require.NoError(t, stream.CloseResponse())
+}
@@ -0,0 +1,110 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// emptyMessageID is the constraint ID of the violation reported for empty
+// messages.
+const emptyMessageID = "message.empty"
+
+// An EmptyMessagePolicy chooses how messages without any populated fields
+// are validated. See [WithEmptyMessages].
+type EmptyMessagePolicy int
+
+const (
+	// EmptyValidate validates empty messages like any other. It's the
+	// default.
+	EmptyValidate EmptyMessagePolicy = iota
+	// EmptyReject rejects empty messages with a single violation, without
+	// evaluating their constraints or running the hooks configured with
+	// [WithMessageHook]. It's the cheapest policy, but it rejects empty
+	// messages that would be valid, so it's only appropriate when every
+	// request carries data.
+	EmptyReject
+	// EmptyCollapse validates empty messages, including with the hooks
+	// configured with [WithMessageHook], and if they're invalid, reports a
+	// single violation instead of the violations found.
+	EmptyCollapse
+)
+
+// String implements [fmt.Stringer].
+func (p EmptyMessagePolicy) String() string {
+	switch p {
+	case EmptyValidate:
+		return "validate"
+	case EmptyReject:
+		return "reject"
+	case EmptyCollapse:
+		return "collapse"
+	}
+	return fmt.Sprintf("empty_%d", int(p))
+}
+
+// WithEmptyMessages configures the [Interceptor] to treat messages without
+// any populated fields according to the [EmptyMessagePolicy]. Clients that
+// send empty requests by mistake, for example because of a serialization bug,
+// otherwise get a wall of violations, one for each required field. With
+// [EmptyReject] or [EmptyCollapse], they get a single violation with the
+// constraint ID "message.empty" and the message "message is empty", which
+// isn't attached to any field. Messages whose types have no fields, like
+// google.protobuf.Empty, are never considered empty.
+func WithEmptyMessages(policy EmptyMessagePolicy) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.messages.emptyMessages = policy
+	})
+}
+
+// isEmptyMessage reports whether msg has fields, but none of them are
+// populated.
+func isEmptyMessage(msg proto.Message) bool {
+	refl := msg.ProtoReflect()
+	if refl.Descriptor().Fields().Len() == 0 || len(refl.GetUnknown()) > 0 {
+		return false
+	}
+	empty := true
+	refl.Range(func(protoreflect.FieldDescriptor, protoreflect.Value) bool {
+		empty = false
+		return false
+	})
+	return empty
+}
+
+// collapseEmptyMessage replaces the violations of an empty message with a
+// single violation. Errors other than a *protovalidate.ValidationError are
+// returned unchanged.
+func collapseEmptyMessage(err error) error {
+	if validationErr := new(protovalidate.ValidationError); !errors.As(err, &validationErr) {
+		return err
+	}
+	return emptyMessageError()
+}
+
+func emptyMessageError() *protovalidate.ValidationError {
+	return &protovalidate.ValidationError{Violations: []*Violation{{
+		Proto: &validatepb.Violation{
+			ConstraintId: proto.String(emptyMessageID),
+			Message:      proto.String("message is empty"),
+		},
+	}}}
+}
@@ -0,0 +1,89 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func TestWithEmptyMessages(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		policy validate.EmptyMessagePolicy
+		req    connect.AnyRequest
+		want   string // constraint ID, or empty if the message is valid
+	}{
+		{policy: validate.EmptyValidate, req: connect.NewRequest(&userv1.User{}), want: "string.email_empty"},
+		{policy: validate.EmptyReject, req: connect.NewRequest(&userv1.User{}), want: "message.empty"},
+		{policy: validate.EmptyReject, req: connect.NewRequest(&userv1.CreateUserRequest{}), want: "message.empty"},
+		{policy: validate.EmptyReject, req: connect.NewRequest(&emptypb.Empty{})},
+		{policy: validate.EmptyCollapse, req: connect.NewRequest(&userv1.User{}), want: "message.empty"},
+		{policy: validate.EmptyCollapse, req: connect.NewRequest(&userv1.CreateUserRequest{})},
+		{policy: validate.EmptyCollapse, req: connect.NewRequest(&userv1.User{Email: "foo"}), want: "string.email"},
+	}
+	for _, test := range tests {
+		test := test
+		name := test.policy.String() + "/" + messageName(test.req)
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithEmptyMessages(test.policy))
+			require.NoError(t, err)
+			_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&emptypb.Empty{}), nil
+			})(context.Background(), test.req)
+			if test.want == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, test.want, requireSingleViolation(t, err).GetConstraintId())
+		})
+	}
+}
+
+func messageName(req connect.AnyRequest) string {
+	msg, _ := req.Any().(proto.Message)
+	return string(msg.ProtoReflect().Descriptor().Name())
+}
+
+func TestWithEmptyMessagesHooks(t *testing.T) {
+	t.Parallel()
+	// CreateUserRequest has no constraints of its own, so only the hook finds
+	// the empty message invalid.
+	var calls int
+	interceptor, err := validate.NewInterceptor(
+		validate.WithEmptyMessages(validate.EmptyCollapse),
+		validate.WithMessageHook(func(context.Context, *userv1.CreateUserRequest) error {
+			calls++
+			return &protovalidate.ValidationError{Violations: []*validate.Violation{newViolation("user.required")}}
+		}),
+	)
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "message.empty", requireSingleViolation(t, err).GetConstraintId())
+}
@@ -0,0 +1,169 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"errors"
+	"path"
+
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+)
+
+// WithFailOpen configures the [Interceptor] to let messages through
+// unvalidated when validation can't run for procedures matching any of the
+// patterns, which use the syntax of [path.Match]. Validation can't run when
+// the validator fails with an error other than a
+// [protovalidate.ValidationError], like a [protovalidate.CompilationError]
+// caused by a malformed constraint.
+//
+// By default, the Interceptor fails closed and rejects such messages, with
+// the code configured with [WithValidatorErrorCode]. Either way, the function
+// configured with [WithValidatorErrorFunc] is called.
+func WithFailOpen(patterns ...string) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.failures.failOpen = append(i.failures.failOpen, patterns...)
+	})
+}
+
+// WithFailOpenOnCompilationErrors configures the [Interceptor] to let
+// messages through unvalidated when their constraints don't compile, on every
+// procedure. A [protovalidate.CompilationError] only affects the message
+// types with malformed constraints, like a bad CEL expression in a
+// third-party schema, so the valid constraints of other messages are still
+// enforced. Other errors that keep validation from running, like a
+// [protovalidate.RuntimeError], still fail closed unless the procedure matches
+// [WithFailOpen]. Use [WithValidatorErrorFunc] to log the messages let
+// through.
+func WithFailOpenOnCompilationErrors() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.failures.failOpenCompilation = true
+	})
+}
+
+// WithValidatorErrorCode configures the [Interceptor] to reject messages with
+// the given code when validation can't run, so clients and dashboards can tell
+// those failures from invalid messages. [connect.CodeInternal] and
+// [connect.CodeUnavailable] are typical choices. By default, a
+// [protovalidate.CompilationError], a [protovalidate.RuntimeError], or an
+// error from a hook configured with [WithMessageHook] isn't the client's
+// fault, so it's rejected with [connect.CodeInternal], while other errors are
+// rejected with [connect.CodeInvalidArgument], like invalid messages.
+// [WithCompilationErrorCode] and [WithRuntimeErrorCode] take precedence for
+// their class of errors. Panics recovered because of [WithRecover] always use
+// [connect.CodeInternal].
+func WithValidatorErrorCode(code connect.Code) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.failures.validatorErrorCode = code
+	})
+}
+
+// WithCompilationErrorCode configures the [Interceptor] to reject messages
+// with the given code when their constraints don't compile, and the
+// validator fails with a [protovalidate.CompilationError]. By default, they're
+// rejected with [connect.CodeInternal], or with the code configured with
+// [WithValidatorErrorCode].
+func WithCompilationErrorCode(code connect.Code) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.failures.compilationErrorCode = code
+	})
+}
+
+// WithRuntimeErrorCode configures the [Interceptor] to reject messages with
+// the given code when evaluating their constraints fails, and the validator
+// fails with a [protovalidate.RuntimeError]. By default, they're rejected with
+// [connect.CodeInternal], or with the code configured with
+// [WithValidatorErrorCode].
+func WithRuntimeErrorCode(code connect.Code) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.failures.runtimeErrorCode = code
+	})
+}
+
+// WithValidatorErrorFunc configures the [Interceptor] to call a function
+// whenever validation can't run, so those failures can be counted separately
+// from invalid messages. The function is called with the validator's error,
+// and failedOpen reports whether the message was let through because of
+// [WithFailOpen].
+func WithValidatorErrorFunc(fn func(ctx context.Context, spec connect.Spec, err error, failedOpen bool)) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.failures.validatorErrorFunc = fn
+	})
+}
+
+// validatorFailure handles an error that kept the validator from deciding
+// whether a message is valid. It returns nil if the procedure fails open.
+func (i *Interceptor) validatorFailure(ctx context.Context, spec connect.Spec, err error) error {
+	timeoutErr := new(TimeoutError)
+	timedOut := errors.As(err, &timeoutErr)
+	compilationErr := new(protovalidate.CompilationError)
+	failOpen := !timedOut && (i.failsOpen(spec.Procedure) ||
+		i.failures.failOpenCompilation && errors.As(err, &compilationErr))
+	if i.failures.validatorErrorFunc != nil {
+		i.failures.validatorErrorFunc(ctx, spec, err, failOpen)
+	}
+	if failOpen {
+		return nil
+	}
+	code := i.validatorFailureCode(err)
+	if panicErr := new(PanicError); errors.As(err, &panicErr) {
+		code = connect.CodeInternal
+	}
+	if timedOut {
+		code = connect.CodeResourceExhausted
+		if i.limits.timeoutCode != 0 {
+			code = i.limits.timeoutCode
+		}
+	}
+	return connect.NewError(code, err)
+}
+
+// validatorFailureCode returns the code of errors for validator failures
+// other than panics and timeouts.
+func (i *Interceptor) validatorFailureCode(err error) connect.Code {
+	compilationErr := new(protovalidate.CompilationError)
+	if errors.As(err, &compilationErr) {
+		return firstCode(i.failures.compilationErrorCode, i.failures.validatorErrorCode, connect.CodeInternal)
+	}
+	runtimeErr := new(protovalidate.RuntimeError)
+	if errors.As(err, &runtimeErr) {
+		return firstCode(i.failures.runtimeErrorCode, i.failures.validatorErrorCode, connect.CodeInternal)
+	}
+	hookErr := new(messageHookError)
+	if errors.As(err, &hookErr) {
+		return firstCode(i.failures.validatorErrorCode, connect.CodeInternal)
+	}
+	return firstCode(i.failures.validatorErrorCode, connect.CodeInvalidArgument)
+}
+
+func (i *Interceptor) failsOpen(procedure string) bool {
+	for _, pattern := range i.failures.failOpen {
+		if ok, _ := path.Match(pattern, procedure); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// firstCode returns the first of codes that's set.
+func firstCode(codes ...connect.Code) connect.Code {
+	for _, code := range codes {
+		if code != 0 {
+			return code
+		}
+	}
+	return 0
+}
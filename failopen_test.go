@@ -0,0 +1,170 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWithFailOpen(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		opts       []validate.Option
+		wantCode   connect.Code
+		failedOpen bool
+	}{
+		{
+			name:     "default",
+			wantCode: connect.CodeInternal,
+		},
+		{
+			name:     "code",
+			opts:     []validate.Option{validate.WithValidatorErrorCode(connect.CodeUnavailable)},
+			wantCode: connect.CodeUnavailable,
+		},
+		{
+			name: "compilation_code",
+			opts: []validate.Option{
+				validate.WithValidatorErrorCode(connect.CodeUnavailable),
+				validate.WithCompilationErrorCode(connect.CodeFailedPrecondition),
+			},
+			wantCode: connect.CodeFailedPrecondition,
+		},
+		{
+			name:     "runtime_code",
+			opts:     []validate.Option{validate.WithRuntimeErrorCode(connect.CodeUnavailable)},
+			wantCode: connect.CodeInternal,
+		},
+		{
+			name:     "other_procedure",
+			opts:     []validate.Option{validate.WithFailOpen(userv1connect.UserServiceUpdateUserProcedure)},
+			wantCode: connect.CodeInternal,
+		},
+		{
+			name:       "fail_open",
+			opts:       []validate.Option{validate.WithFailOpen("/example.user.v1.UserService/*")},
+			failedOpen: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			failures := make(chan bool, 1)
+			opts := append([]validate.Option{
+				validate.WithValidator(failingValidator{}),
+				validate.WithValidatorErrorFunc(func(_ context.Context, _ connect.Spec, err error, failedOpen bool) {
+					assert.ErrorAs(t, err, new(*protovalidate.CompilationError))
+					failures <- failedOpen
+				}),
+			}, test.opts...)
+			interceptor, err := validate.NewInterceptor(opts...)
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+				userv1connect.UserServiceCreateUserProcedure,
+				createUser,
+				connect.WithInterceptors(interceptor),
+			))
+			srv := startHTTPServer(t, mux)
+
+			_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+				CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+					User: &userv1.User{Email: "someone@example.com"},
+				}))
+			if test.wantCode == 0 {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, test.wantCode, connect.CodeOf(err))
+			}
+			require.Len(t, failures, 1)
+			assert.Equal(t, test.failedOpen, <-failures)
+		})
+	}
+}
+
+// failingValidator fails every message as if its constraints didn't compile.
+type failingValidator struct{}
+
+func (failingValidator) Validate(proto.Message) error {
+	return &protovalidate.CompilationError{}
+}
+
+func TestWithFailOpenOnCompilationErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		validator  protovalidate.Validator
+		failedOpen bool
+	}{
+		{name: "compilation", validator: failingValidator{}, failedOpen: true},
+		{name: "runtime", validator: staticValidator{err: &protovalidate.RuntimeError{}}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			failures := make(chan bool, 1)
+			interceptor, err := validate.NewInterceptor(
+				validate.WithValidator(test.validator),
+				validate.WithFailOpenOnCompilationErrors(),
+				validate.WithValidatorErrorFunc(func(_ context.Context, _ connect.Spec, _ error, failedOpen bool) {
+					failures <- failedOpen
+				}),
+			)
+			require.NoError(t, err)
+			_, err = newUserClient(t, interceptor).CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+				User: &userv1.User{Email: "someone@example.com"},
+			}))
+			if test.failedOpen {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+			}
+			require.Len(t, failures, 1)
+			assert.Equal(t, test.failedOpen, <-failures)
+		})
+	}
+}
+
+func TestWithRuntimeErrorCode(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(
+		validate.WithValidator(staticValidator{err: &protovalidate.RuntimeError{}}),
+		validate.WithCompilationErrorCode(connect.CodeFailedPrecondition),
+		validate.WithRuntimeErrorCode(connect.CodeUnavailable),
+	)
+	require.NoError(t, err)
+	_, err = newUserClient(t, interceptor).CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "someone@example.com"},
+	}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeUnavailable, connect.CodeOf(err))
+}
@@ -0,0 +1,125 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+)
+
+// A FieldError describes one violation in the shape of the field errors of
+// [go-playground/validator], which many web frameworks and form libraries
+// already know how to render. Use [AsFieldErrors] to convert validation
+// errors.
+//
+// [go-playground/validator]: https://pkg.go.dev/github.com/go-playground/validator/v10#FieldError
+type FieldError struct {
+	// Namespace is the full path of the field, in the syntax of
+	// [protovalidate.FieldPathString], like "user.tags[2]". It's empty for
+	// violations of message-level constraints.
+	Namespace string
+	// Field is the last element of Namespace, like "tags[2]".
+	Field string
+	// Tag is the ID of the violated constraint, like "string.email".
+	Tag string
+	// Param is the value of the violated rule, like "3" for
+	// string.min_len = 3. It's empty for custom constraints, and when the
+	// violation came from a [connect.Error], which doesn't carry rule values.
+	Param string
+	// Value is the value of the invalid field. It's nil for fields marked
+	// with the debug_redact option, and when the violation came from a
+	// [connect.Error], which doesn't carry field values.
+	Value any
+	// Message is the violation's message.
+	Message string
+}
+
+// Error implements error.
+func (e FieldError) Error() string {
+	if e.Namespace == "" {
+		return fmt.Sprintf("validation failed on the '%s' tag: %s", e.Tag, e.Message)
+	}
+	return fmt.Sprintf("field '%s' failed validation on the '%s' tag: %s", e.Namespace, e.Tag, e.Message)
+}
+
+// FieldErrors is a list of [FieldError], like go-playground/validator's
+// ValidationErrors.
+type FieldErrors []FieldError
+
+// Error implements error, rendering each FieldError on its own line.
+func (e FieldErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, fieldErr := range e {
+		lines[i] = fieldErr.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// AsFieldErrors converts the violations in err to [FieldErrors]. On the
+// server, err is usually a [*protovalidate.ValidationError], as passed to
+// hooks like [WithErrorTransformer], or an error wrapping one, like the
+// errors the [Interceptor] returns. On the client, it's a [*connect.Error]
+// with a buf.validate.Violations detail. It reports false if err carries no
+// violations.
+func AsFieldErrors(err error) (FieldErrors, bool) {
+	if validationErr := new(protovalidate.ValidationError); errors.As(err, &validationErr) {
+		fieldErrs := make(FieldErrors, len(validationErr.Violations))
+		for i, violation := range validationErr.Violations {
+			fieldErrs[i] = newFieldError(violation.Proto)
+			if violation.RuleValue.IsValid() {
+				fieldErrs[i].Param = fmt.Sprint(violation.RuleValue.Interface())
+			}
+			if violation.FieldValue.IsValid() {
+				fieldErrs[i].Value = violation.FieldValue.Interface()
+			}
+		}
+		return fieldErrs, len(fieldErrs) > 0
+	}
+	connectErr := new(connect.Error)
+	if !errors.As(err, &connectErr) {
+		return nil, false
+	}
+	var fieldErrs FieldErrors
+	for _, detail := range connectErr.Details() {
+		value, err := detail.Value()
+		if err != nil {
+			continue
+		}
+		if violations, ok := value.(*validatepb.Violations); ok {
+			for _, violation := range violations.GetViolations() {
+				fieldErrs = append(fieldErrs, newFieldError(violation))
+			}
+		}
+	}
+	return fieldErrs, len(fieldErrs) > 0
+}
+
+func newFieldError(violation *validatepb.Violation) FieldError {
+	fieldErr := FieldError{
+		Namespace: protovalidate.FieldPathString(violation.GetField()),
+		Tag:       violation.GetConstraintId(),
+		Message:   violation.GetMessage(),
+	}
+	if elements := violation.GetField().GetElements(); len(elements) > 0 {
+		last := &validatepb.FieldPath{Elements: elements[len(elements)-1:]}
+		fieldErr.Field = protovalidate.FieldPathString(last)
+	}
+	return fieldErr
+}
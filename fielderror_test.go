@@ -0,0 +1,73 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAsFieldErrors(t *testing.T) {
+	t.Parallel()
+	var serverErrs validate.FieldErrors
+	interceptor, err := validate.NewInterceptor(validate.WithOnFailure(
+		func(_ context.Context, _ connect.Spec, _ proto.Message, err *protovalidate.ValidationError) {
+			serverErrs, _ = validate.AsFieldErrors(err)
+		},
+	))
+	require.NoError(t, err)
+	client := newUserClient(t, interceptor)
+	_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo", Handle: "admin"},
+	}))
+	require.Error(t, err)
+
+	want := validate.FieldErrors{
+		{
+			Namespace: "user.email",
+			Field:     "email",
+			Tag:       "string.email",
+			Param:     "true",
+			Value:     "foo",
+			Message:   "value must be a valid email address",
+		},
+		{
+			Namespace: "user.handle",
+			Field:     "handle",
+			Tag:       "user.handle",
+			Message:   "handle [REDACTED] is reserved",
+		},
+	}
+	assert.Equal(t, want, serverErrs)
+
+	// Clients only see what the error details carry.
+	clientErrs, ok := validate.AsFieldErrors(err)
+	require.True(t, ok)
+	want[0].Param, want[0].Value = "", nil
+	assert.Equal(t, want, clientErrs)
+	assert.Equal(t, "field 'user.email' failed validation on the 'string.email' tag: value must be a valid email address\n"+
+		"field 'user.handle' failed validation on the 'user.handle' tag: handle [REDACTED] is reserved", clientErrs.Error())
+
+	_, ok = validate.AsFieldErrors(connect.NewError(connect.CodeInternal, nil))
+	assert.False(t, ok)
+}
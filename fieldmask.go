@@ -0,0 +1,388 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	fieldMaskName        = "google.protobuf.FieldMask"
+	fieldMaskPathsNumber = 1
+
+	fieldMaskUnknownPathID    = "field_mask.unknown_path"
+	fieldMaskOutputOnlyPathID = "field_mask.output_only"
+)
+
+// A FieldMaskResolver identifies the message that the paths in a
+// google.protobuf.FieldMask field refer to. It's called with the descriptor
+// of the request message and the descriptor of the FieldMask field within it.
+// Returning nil skips checking that field.
+type FieldMaskResolver func(msg protoreflect.MessageDescriptor, mask protoreflect.FieldDescriptor) protoreflect.MessageDescriptor
+
+// WithFieldMaskValidation configures the [Interceptor] to check the paths in
+// top-level google.protobuf.FieldMask fields of request messages. Each path
+// must name a field of the target message, and it must not name a field
+// annotated as OUTPUT_ONLY with google.api.field_behavior. Paths that break
+// these rules are reported as violations alongside any violations found by
+// the validator. As [AIP-161] allows, the segment after a map field is a map
+// key, quoted with backticks if it isn't an identifier, as in
+// "labels.`app.kubernetes.io/name`".
+//
+// By default, the target of a FieldMask is the request's only other singular
+// message field, which matches the shape of [AIP-134] Update requests.
+// FieldMasks without exactly one candidate target aren't checked. To resolve
+// targets differently, use [WithFieldMaskResolver].
+//
+// [AIP-134]: https://google.aip.dev/134
+// [AIP-161]: https://google.aip.dev/161
+func WithFieldMaskValidation() Option {
+	return WithFieldMaskResolver(resolveFieldMaskTarget)
+}
+
+// WithFieldMaskResolver is like [WithFieldMaskValidation], but uses the
+// supplied [FieldMaskResolver] to find the target of each FieldMask.
+func WithFieldMaskResolver(resolver FieldMaskResolver) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.messages.fieldMaskResolver = resolver
+	})
+}
+
+// WithMaskedValidation configures the [Interceptor] to validate only the
+// fields named in the FieldMask of partial updates, like [AIP-134] Update
+// requests. Without it, constraints like required fields force clients to
+// send full resources just to pass validation. Violations within the target
+// of the FieldMask are reported only if they're on or below a masked path, so
+// message-level constraints of the target itself are skipped too. Requests
+// without a FieldMask, with an empty FieldMask, or with the "*" path are
+// validated in full.
+//
+// Masked paths may name map entries, as in "labels.foo", in which case only
+// the violations of that entry are reported.
+//
+// Targets are resolved like with [WithFieldMaskValidation], or with the
+// [FieldMaskResolver] configured with [WithFieldMaskResolver]. To resolve
+// them differently without also checking the paths, use
+// [WithMaskedValidationResolver]. Since violations are dropped after
+// validation, combining it with [WithFailFast] may hide violations of masked
+// fields.
+//
+// [AIP-134]: https://google.aip.dev/134
+func WithMaskedValidation() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.messages.maskedValidation = true
+	})
+}
+
+// WithMaskedValidationResolver is like [WithMaskedValidation], but uses the
+// supplied [FieldMaskResolver] to find the target of each FieldMask. Unlike
+// [WithFieldMaskResolver], it doesn't turn on checking of the masks' paths.
+func WithMaskedValidationResolver(resolver FieldMaskResolver) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.messages.maskedValidation = true
+		i.messages.maskedResolver = resolver
+	})
+}
+
+func resolveFieldMaskTarget(msg protoreflect.MessageDescriptor, mask protoreflect.FieldDescriptor) protoreflect.MessageDescriptor {
+	var target protoreflect.MessageDescriptor
+	fields := msg.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field == mask || field.Message() == nil || field.IsList() || field.IsMap() {
+			continue
+		}
+		if isFieldMask(field) {
+			continue
+		}
+		if target != nil {
+			return nil // ambiguous
+		}
+		target = field.Message()
+	}
+	return target
+}
+
+// appendFieldMaskViolations checks the FieldMask fields of msg and merges any
+// violations into err. Errors other than a *protovalidate.ValidationError are
+// returned unchanged.
+func appendFieldMaskViolations(err error, msg proto.Message, resolver FieldMaskResolver) error {
+	var validationErr *protovalidate.ValidationError
+	if err != nil && !errors.As(err, &validationErr) {
+		return err
+	}
+	refl := msg.ProtoReflect()
+	desc := refl.Descriptor()
+	fields := desc.Fields()
+	var violations []*protovalidate.Violation
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if !isFieldMask(field) || field.IsList() || !refl.Has(field) {
+			continue
+		}
+		target := resolver(desc, field)
+		if target == nil {
+			continue
+		}
+		paths := refl.Get(field).Message().Get(field.Message().Fields().ByNumber(fieldMaskPathsNumber)).List()
+		for index := 0; index < paths.Len(); index++ {
+			if violation := checkFieldMaskPath(target, field, index, paths.Get(index).String()); violation != nil {
+				violations = append(violations, violation)
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return err
+	}
+	if validationErr == nil {
+		validationErr = &protovalidate.ValidationError{}
+	}
+	validationErr.Violations = append(validationErr.Violations, violations...)
+	return validationErr
+}
+
+func checkFieldMaskPath(target protoreflect.MessageDescriptor, mask protoreflect.FieldDescriptor, index int, path string) *protovalidate.Violation {
+	if path == "*" {
+		return nil // full replacement, per AIP-134
+	}
+	desc := target
+	segments := splitFieldMaskPath(path)
+	for i := 0; i < len(segments); i++ {
+		if desc == nil {
+			return newFieldMaskViolation(mask, index, path, fieldMaskUnknownPathID,
+				fmt.Sprintf("path %q is not a field of %s", path, target.FullName()))
+		}
+		field := desc.Fields().ByName(protoreflect.Name(segments[i]))
+		if field == nil {
+			return newFieldMaskViolation(mask, index, path, fieldMaskUnknownPathID,
+				fmt.Sprintf("path %q is not a field of %s", path, target.FullName()))
+		}
+		if isOutputOnly(field) {
+			return newFieldMaskViolation(mask, index, path, fieldMaskOutputOnlyPathID,
+				fmt.Sprintf("path %q refers to an output-only field", path))
+		}
+		desc = nil
+		if field.IsMap() && i < len(segments)-1 {
+			// Per AIP-161, the segment after a map is a key, which may be
+			// followed by the fields of message values.
+			i++
+			if i < len(segments)-1 {
+				desc = field.MapValue().Message()
+			}
+			continue
+		}
+		if i < len(segments)-1 && field.Message() != nil && !field.IsList() {
+			desc = field.Message()
+		}
+	}
+	return nil
+}
+
+// splitFieldMaskPath splits a FieldMask path into segments. Map keys that
+// aren't valid identifiers are quoted with backticks, per AIP-161, so dots
+// within backticks don't separate segments, and the backticks are dropped.
+func splitFieldMaskPath(path string) []string {
+	var segments []string
+	var segment strings.Builder
+	quoted := false
+	for _, r := range path {
+		switch {
+		case r == '`':
+			quoted = !quoted
+		case r == '.' && !quoted:
+			segments = append(segments, segment.String())
+			segment.Reset()
+		default:
+			segment.WriteRune(r)
+		}
+	}
+	return append(segments, segment.String())
+}
+
+func newFieldMaskViolation(mask protoreflect.FieldDescriptor, index int, path, constraintID, message string) *protovalidate.Violation {
+	pathsField := mask.Message().Fields().ByNumber(fieldMaskPathsNumber)
+	return &protovalidate.Violation{
+		Proto: &validatepb.Violation{
+			Field: &validatepb.FieldPath{
+				Elements: []*validatepb.FieldPathElement{
+					{
+						FieldNumber: proto.Int32(int32(mask.Number())),
+						FieldName:   proto.String(string(mask.Name())),
+						FieldType:   descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					},
+					{
+						FieldNumber: proto.Int32(int32(pathsField.Number())),
+						FieldName:   proto.String(string(pathsField.Name())),
+						FieldType:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						Subscript:   &validatepb.FieldPathElement_Index{Index: uint64(index)},
+					},
+				},
+			},
+			ConstraintId: proto.String(constraintID),
+			Message:      proto.String(message),
+		},
+		FieldValue:      protoreflect.ValueOfString(path),
+		FieldDescriptor: pathsField,
+	}
+}
+
+func isFieldMask(field protoreflect.FieldDescriptor) bool {
+	msg := field.Message()
+	return msg != nil && msg.FullName() == fieldMaskName
+}
+
+func isOutputOnly(field protoreflect.FieldDescriptor) bool {
+	behaviors, ok := proto.GetExtension(field.Options(), annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+	return ok && slices.Contains(behaviors, annotations.FieldBehavior_OUTPUT_ONLY)
+}
+
+// dropUnmaskedViolations drops the violations of fields within the targets of
+// msg's FieldMasks that aren't on or below a masked path. Errors other than a
+// *protovalidate.ValidationError are returned unchanged.
+func dropUnmaskedViolations(err error, msg proto.Message, resolver FieldMaskResolver) error {
+	var validationErr *protovalidate.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+	masked := maskedPaths(msg, resolver)
+	if len(masked) == 0 {
+		return err
+	}
+	violations := make([]*Violation, 0, len(validationErr.Violations))
+	for _, violation := range validationErr.Violations {
+		if keepMaskedViolation(fieldPathSegments(violation.Proto.GetField()), masked) {
+			violations = append(violations, violation)
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return &protovalidate.ValidationError{Violations: violations}
+}
+
+// maskedPaths maps the name of each field of msg that's the target of a
+// partial FieldMask to the segments of the masked paths, prefixed with the
+// field's name.
+func maskedPaths(msg proto.Message, resolver FieldMaskResolver) map[string][][]string {
+	refl := msg.ProtoReflect()
+	desc := refl.Descriptor()
+	fields := desc.Fields()
+	var masked map[string][][]string
+	for i := 0; i < fields.Len(); i++ {
+		mask := fields.Get(i)
+		if !isFieldMask(mask) || mask.IsList() || !refl.Has(mask) {
+			continue
+		}
+		target := maskTargetField(desc, mask, resolver)
+		if target == nil {
+			continue
+		}
+		paths := refl.Get(mask).Message().Get(mask.Message().Fields().ByNumber(fieldMaskPathsNumber)).List()
+		if paths.Len() == 0 {
+			continue
+		}
+		prefixed := make([][]string, 0, paths.Len())
+		for index := 0; index < paths.Len(); index++ {
+			path := paths.Get(index).String()
+			if path == "*" {
+				prefixed = nil
+				break
+			}
+			prefixed = append(prefixed, append([]string{string(target.Name())}, splitFieldMaskPath(path)...))
+		}
+		if prefixed == nil {
+			continue
+		}
+		if masked == nil {
+			masked = make(map[string][][]string)
+		}
+		masked[string(target.Name())] = append(masked[string(target.Name())], prefixed...)
+	}
+	return masked
+}
+
+// maskTargetField finds the only singular field of msg whose type is the
+// target of mask.
+func maskTargetField(msg protoreflect.MessageDescriptor, mask protoreflect.FieldDescriptor, resolver FieldMaskResolver) protoreflect.FieldDescriptor {
+	target := resolver(msg, mask)
+	if target == nil {
+		return nil
+	}
+	var found protoreflect.FieldDescriptor
+	fields := msg.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if field == mask || field.IsList() || field.IsMap() || field.Message() == nil || field.Message().FullName() != target.FullName() {
+			continue
+		}
+		if found != nil {
+			return nil // ambiguous
+		}
+		found = field
+	}
+	return found
+}
+
+// keepMaskedViolation reports whether a violation of the field at path, given
+// as segments, should be kept, given the masked paths of each FieldMask
+// target.
+func keepMaskedViolation(path []string, masked map[string][][]string) bool {
+	if len(path) == 0 {
+		return true // message-level
+	}
+	paths, ok := masked[path[0]]
+	if !ok {
+		return true // not within a target
+	}
+	for _, maskedPath := range paths {
+		if len(maskedPath) <= len(path) && slices.Equal(path[:len(maskedPath)], maskedPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldPathSegments splits a violation's field path into segments that can be
+// compared with the segments of FieldMask paths: field names, each followed by
+// the key if the element is a map entry. List indexes have no counterpart in
+// FieldMask paths, so they're dropped.
+func fieldPathSegments(path *validatepb.FieldPath) []string {
+	segments := make([]string, 0, len(path.GetElements()))
+	for _, element := range path.GetElements() {
+		segments = append(segments, element.GetFieldName())
+		switch subscript := element.GetSubscript().(type) {
+		case *validatepb.FieldPathElement_StringKey:
+			segments = append(segments, subscript.StringKey)
+		case *validatepb.FieldPathElement_IntKey:
+			segments = append(segments, strconv.FormatInt(subscript.IntKey, 10))
+		case *validatepb.FieldPathElement_UintKey:
+			segments = append(segments, strconv.FormatUint(subscript.UintKey, 10))
+		case *validatepb.FieldPathElement_BoolKey:
+			segments = append(segments, strconv.FormatBool(subscript.BoolKey))
+		}
+	}
+	return segments
+}
@@ -0,0 +1,357 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestWithFieldMaskValidation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		paths    []string
+		wantPath string // field path, from error details
+		wantRule string // constraint ID, from error details
+	}{
+		{
+			name:  "valid",
+			paths: []string{"email", "birth_date.seconds"},
+		},
+		{
+			name:  "wildcard",
+			paths: []string{"*"},
+		},
+		{
+			name:     "unknown",
+			paths:    []string{"email", "nickname"},
+			wantPath: "update_mask.paths[1]",
+			wantRule: "field_mask.unknown_path",
+		},
+		{
+			name:     "through_scalar",
+			paths:    []string{"email.domain"},
+			wantPath: "update_mask.paths[0]",
+			wantRule: "field_mask.unknown_path",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			interceptor, err := validate.NewInterceptor(validate.WithFieldMaskValidation())
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			mux.Handle(userv1connect.UserServiceUpdateUserProcedure, connect.NewUnaryHandler(
+				userv1connect.UserServiceUpdateUserProcedure,
+				updateUser,
+				connect.WithInterceptors(interceptor),
+			))
+			srv := startHTTPServer(t, mux)
+
+			_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+				UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{
+					User:       &userv1.User{Email: "someone@example.com"},
+					UpdateMask: &fieldmaskpb.FieldMask{Paths: test.paths},
+				}))
+			if test.wantPath == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			violation := requireSingleViolation(t, err)
+			assert.Equal(t, test.wantPath, protovalidate.FieldPathString(violation.GetField()))
+			assert.Equal(t, test.wantRule, violation.GetConstraintId())
+		})
+	}
+}
+
+func TestFieldMaskMapKeys(t *testing.T) {
+	t.Parallel()
+	// google.protobuf.Struct has a map of messages, so masks of UpdateUser
+	// are checked against it.
+	interceptor, err := validate.NewInterceptor(validate.WithFieldMaskResolver(
+		func(protoreflect.MessageDescriptor, protoreflect.FieldDescriptor) protoreflect.MessageDescriptor {
+			return (&structpb.Struct{}).ProtoReflect().Descriptor()
+		},
+	))
+	require.NoError(t, err)
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.UpdateUserResponse{}), nil
+	}
+	tests := []struct {
+		path  string
+		valid bool
+	}{
+		{path: "fields", valid: true},
+		{path: "fields.some_key", valid: true},
+		{path: "fields.`app.kubernetes.io/name`", valid: true},
+		{path: "fields.some_key.string_value", valid: true},
+		{path: "fields.some_key.nickname"},
+		{path: "fields.some_key.string_value.length"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+			_, err := interceptor.WrapUnary(next)(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{
+				User:       &userv1.User{Email: "someone@example.com"},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{test.path}},
+			}))
+			if test.valid {
+				require.NoError(t, err)
+				return
+			}
+			violation := requireSingleViolation(t, err)
+			assert.Equal(t, "field_mask.unknown_path", violation.GetConstraintId())
+		})
+	}
+}
+
+func TestWithFieldMaskResolver(t *testing.T) {
+	t.Parallel()
+	msgDesc := newOutputOnlyUpdateRequest(t)
+	msg := dynamicpb.NewMessage(msgDesc)
+	mask := dynamicpb.NewMessage((&fieldmaskpb.FieldMask{}).ProtoReflect().Descriptor())
+	paths := mask.NewField(mask.Descriptor().Fields().ByName("paths")).List()
+	paths.Append(protoreflect.ValueOfString("create_time"))
+	mask.Set(mask.Descriptor().Fields().ByName("paths"), protoreflect.ValueOfList(paths))
+	msg.Set(msgDesc.Fields().ByName("update_mask"), protoreflect.ValueOfMessage(mask))
+
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.UpdateUserResponse{}), nil
+	}
+
+	interceptor, err := validate.NewInterceptor(validate.WithFieldMaskValidation())
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(next)(context.Background(), connect.NewRequest(msg))
+	require.Error(t, err)
+	violation := requireSingleViolation(t, err)
+	assert.Equal(t, "update_mask.paths[0]", protovalidate.FieldPathString(violation.GetField()))
+	assert.Equal(t, "field_mask.output_only", violation.GetConstraintId())
+
+	// A resolver that declines to pick a target skips the check.
+	interceptor, err = validate.NewInterceptor(validate.WithFieldMaskResolver(
+		func(protoreflect.MessageDescriptor, protoreflect.FieldDescriptor) protoreflect.MessageDescriptor {
+			return nil
+		},
+	))
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(next)(context.Background(), connect.NewRequest(msg))
+	require.NoError(t, err)
+}
+
+func TestWithMaskedValidation(t *testing.T) {
+	t.Parallel()
+	birthDate := timestamppb.New(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC))
+	tests := []struct {
+		name     string
+		user     *userv1.User
+		paths    []string
+		wantRule string // constraint ID, from error details
+	}{
+		{
+			name:  "unmasked",
+			user:  &userv1.User{Email: "foo", BirthDate: birthDate},
+			paths: []string{"birth_date"},
+		},
+		{
+			name:     "masked",
+			user:     &userv1.User{Email: "foo", BirthDate: birthDate},
+			paths:    []string{"birth_date", "email"},
+			wantRule: "string.email",
+		},
+		{
+			name:     "wildcard",
+			user:     &userv1.User{Email: "someone@example.com", BirthDate: birthDate},
+			paths:    []string{"*"},
+			wantRule: "user.signup_date",
+		},
+		{
+			name:     "empty",
+			user:     &userv1.User{Email: "foo"},
+			wantRule: "string.email",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithMaskedValidation())
+			require.NoError(t, err)
+			_, err = newUserClient(t, interceptor).UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{
+				User:       test.user,
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: test.paths},
+			}))
+			if test.wantRule == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			violation := requireSingleViolation(t, err)
+			assert.Equal(t, test.wantRule, violation.GetConstraintId())
+		})
+	}
+}
+
+// newOutputOnlyUpdateRequest builds an UpdateBookRequest whose Book has an
+// output-only field. The example schemas don't depend on googleapis, so the
+// descriptors are built by hand.
+func newOutputOnlyUpdateRequest(tb testing.TB) protoreflect.MessageDescriptor {
+	tb.Helper()
+	outputOnly := &descriptorpb.FieldOptions{}
+	proto.SetExtension(outputOnly, annotations.E_FieldBehavior, []annotations.FieldBehavior{
+		annotations.FieldBehavior_OUTPUT_ONLY,
+	})
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("fieldmask_test.proto"),
+		Package:    proto.String("fieldmask.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/api/field_behavior.proto", "google/protobuf/field_mask.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Book"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("title"),
+						Number:   proto.Int32(1),
+						Label:    optional,
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						JsonName: proto.String("title"),
+					},
+					{
+						Name:     proto.String("create_time"),
+						Number:   proto.Int32(2),
+						Label:    optional,
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+						JsonName: proto.String("createTime"),
+						Options:  outputOnly,
+					},
+				},
+			},
+			{
+				Name: proto.String("UpdateBookRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("book"),
+						Number:   proto.Int32(1),
+						Label:    optional,
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+						TypeName: proto.String(".fieldmask.test.Book"),
+						JsonName: proto.String("book"),
+					},
+					{
+						Name:     proto.String("update_mask"),
+						Number:   proto.Int32(2),
+						Label:    optional,
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+						TypeName: proto.String(".google.protobuf.FieldMask"),
+						JsonName: proto.String("updateMask"),
+					},
+				},
+			},
+		},
+	}, protoregistry.GlobalFiles)
+	require.NoError(tb, err)
+	return file.Messages().ByName("UpdateBookRequest")
+}
+
+func updateUser(_ context.Context, req *connect.Request[userv1.UpdateUserRequest]) (*connect.Response[userv1.UpdateUserResponse], error) {
+	return connect.NewResponse(&userv1.UpdateUserResponse{User: req.Msg.User}), nil
+}
+
+func TestMaskedValidationMapEntries(t *testing.T) {
+	t.Parallel()
+	// The example schemas have no maps, so the validator reports a violation
+	// of a map entry directly.
+	violation := newViolation("string.min_len",
+		&validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("user")},
+		&validatepb.FieldPathElement{
+			FieldNumber: proto.Int32(9),
+			FieldName:   proto.String("labels"),
+			Subscript:   &validatepb.FieldPathElement_StringKey{StringKey: "app.kubernetes.io/name"},
+		},
+	)
+	validator := staticValidator{err: &protovalidate.ValidationError{Violations: []*validate.Violation{violation}}}
+	tests := []struct {
+		path  string
+		valid bool
+	}{
+		{path: "labels"},
+		{path: "labels.`app.kubernetes.io/name`"},
+		{path: "labels.other", valid: true},
+		{path: "email", valid: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithValidator(validator), validate.WithMaskedValidation())
+			require.NoError(t, err)
+			_, err = newUserClient(t, interceptor).UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{
+				User:       &userv1.User{Email: "someone@example.com"},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{test.path}},
+			}))
+			if test.valid {
+				require.NoError(t, err)
+				return
+			}
+			assert.Equal(t, "string.min_len", requireSingleViolation(t, err).GetConstraintId())
+		})
+	}
+}
+
+func TestWithMaskedValidationResolver(t *testing.T) {
+	t.Parallel()
+	// A resolver that finds no target validates requests in full, and the
+	// masks' paths aren't checked.
+	interceptor, err := validate.NewInterceptor(validate.WithMaskedValidationResolver(
+		func(protoreflect.MessageDescriptor, protoreflect.FieldDescriptor) protoreflect.MessageDescriptor {
+			return nil
+		},
+	))
+	require.NoError(t, err)
+	_, err = newUserClient(t, interceptor).UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{
+		User:       &userv1.User{Email: "foo"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"nickname"}},
+	}))
+	assert.Equal(t, "string.email", requireSingleViolation(t, err).GetConstraintId())
+}
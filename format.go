@@ -0,0 +1,87 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bufbuild/protovalidate-go"
+)
+
+// A Format is an output format for [FormatViolations].
+type Format int
+
+const (
+	// FormatText renders each violation on its own line, as
+	// "field [constraint_id]: message". The field path uses the syntax of
+	// [protovalidate.FieldPathString], like "user.tags[2]", and is omitted
+	// for violations of message-level constraints. If the message is empty,
+	// it's omitted along with its colon. Lines are separated by "\n", with no
+	// trailing newline.
+	FormatText Format = iota
+	// FormatJSON renders a JSON array with an object for each violation,
+	// with the keys "field", "rule", "constraint_id", and "message", in that
+	// order. Every key is always present; "field" and "rule" are paths in the
+	// same syntax as FormatText. The array is empty, not null, if there are
+	// no violations.
+	FormatJSON
+)
+
+// FormatViolations renders violations in a stable, documented format,
+// suitable for logs, command-line tools, and golden files. Violations are
+// rendered in the order given.
+func FormatViolations(violations []*Violation, format Format) string {
+	switch format {
+	case FormatText:
+		lines := make([]string, len(violations))
+		for i, violation := range violations {
+			var builder strings.Builder
+			if field := protovalidate.FieldPathString(violation.Proto.GetField()); field != "" {
+				builder.WriteString(field)
+				builder.WriteByte(' ')
+			}
+			fmt.Fprintf(&builder, "[%s]", violation.Proto.GetConstraintId())
+			if message := violation.Proto.GetMessage(); message != "" {
+				builder.WriteString(": ")
+				builder.WriteString(message)
+			}
+			lines[i] = builder.String()
+		}
+		return strings.Join(lines, "\n")
+	case FormatJSON:
+		objects := make([]formattedViolation, len(violations))
+		for i, violation := range violations {
+			objects[i] = formattedViolation{
+				Field:        protovalidate.FieldPathString(violation.Proto.GetField()),
+				Rule:         protovalidate.FieldPathString(violation.Proto.GetRule()),
+				ConstraintID: violation.Proto.GetConstraintId(),
+				Message:      violation.Proto.GetMessage(),
+			}
+		}
+		// Marshaling strings can't fail.
+		out, _ := json.Marshal(objects)
+		return string(out)
+	}
+	return fmt.Sprintf("unknown format %d", int(format))
+}
+
+type formattedViolation struct {
+	Field        string `json:"field"`
+	Rule         string `json:"rule"`
+	ConstraintID string `json:"constraint_id"`
+	Message      string `json:"message"`
+}
@@ -0,0 +1,71 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestFormatViolations(t *testing.T) {
+	t.Parallel()
+	err := protovalidate.Validate(&userv1.CreateUserRequest{
+		User: &userv1.User{
+			Email:      "foo",
+			BirthDate:  timestamppb.New(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+			SignupDate: timestamppb.New(time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)),
+		},
+	})
+	var validationErr *protovalidate.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	tests := []struct {
+		name       string
+		violations []*validate.Violation
+		format     validate.Format
+		want       string
+	}{
+		{
+			name:       "text",
+			violations: validationErr.Violations,
+			format:     validate.FormatText,
+			want: "user [user.signup_date]: signup date must be on or after birth date\n" +
+				"user.email [string.email]: value must be a valid email address",
+		},
+		{
+			name:       "json",
+			violations: validationErr.Violations,
+			format:     validate.FormatJSON,
+			want: `[{"field":"user","rule":"","constraint_id":"user.signup_date","message":"signup date must be on or after birth date"},` +
+				`{"field":"user.email","rule":"string.email","constraint_id":"string.email","message":"value must be a valid email address"}]`,
+		},
+		{name: "empty_text", format: validate.FormatText, want: ""},
+		{name: "empty_json", format: validate.FormatJSON, want: "[]"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, validate.FormatViolations(test.violations, test.format))
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// WithMessageHook configures the [Interceptor] to run hook on every message
+// of type T, after protovalidate, for rules that can't be expressed in CEL,
+// like uniqueness checks against a database or checksum verification. The
+// violations of a [protovalidate.ValidationError] returned by the hook are
+// merged with protovalidate's, so they're reported in the same error
+// details. Other errors, like a database timeout, aren't the client's fault:
+// a [*connect.Error] is returned to the client as-is, and any other error is
+// handled as if validation couldn't run, with [connect.CodeInternal] by
+// default (see [WithValidatorErrorCode] and [WithFailOpen]). Either way, the
+// remaining hooks don't run.
+//
+// Hooks run only if protovalidate could validate the message, and hooks for
+// the same type run in the order they're configured. T must be a concrete
+// message type, like *userv1.CreateUserRequest.
+func WithMessageHook[T proto.Message](hook func(context.Context, T) error) Option {
+	var zero T
+	var name protoreflect.FullName
+	if any(zero) != nil {
+		name = zero.ProtoReflect().Descriptor().FullName()
+	}
+	return optionFunc(func(i *Interceptor) {
+		i.messages.messageHooks = append(i.messages.messageHooks, messageHook{
+			typ:  reflect.TypeOf((*T)(nil)).Elem().String(),
+			name: name,
+			run: func(ctx context.Context, msg proto.Message) error {
+				typed, ok := msg.(T)
+				if !ok {
+					return nil
+				}
+				return hook(ctx, typed)
+			},
+		})
+	})
+}
+
+type messageHook struct {
+	typ  string
+	name protoreflect.FullName // empty if T isn't a concrete type
+	run  func(context.Context, proto.Message) error
+}
+
+// resolveMessageHooks indexes the configured hooks by message name.
+func (i *Interceptor) resolveMessageHooks() error {
+	for _, hook := range i.messages.messageHooks {
+		if hook.name == "" {
+			return fmt.Errorf("message hook for %s: need a concrete message type", hook.typ)
+		}
+		if i.messages.hooksByMessage == nil {
+			i.messages.hooksByMessage = make(map[protoreflect.FullName][]messageHook)
+		}
+		i.messages.hooksByMessage[hook.name] = append(i.messages.hooksByMessage[hook.name], hook)
+	}
+	return nil
+}
+
+// runMessageHooks runs the hooks for msg, merging their violations with
+// those of err, the result of protovalidate.
+func (i *Interceptor) runMessageHooks(ctx context.Context, msg proto.Message, err error) error {
+	hooks := i.messages.hooksByMessage[msg.ProtoReflect().Descriptor().FullName()]
+	if len(hooks) == 0 {
+		return err
+	}
+	merged := &protovalidate.ValidationError{}
+	if err != nil {
+		validationErr := new(protovalidate.ValidationError)
+		if !errors.As(err, &validationErr) {
+			return err
+		}
+		merged.Violations = append(merged.Violations, validationErr.Violations...)
+	}
+	for _, hook := range hooks {
+		hookErr := hook.run(ctx, msg)
+		if hookErr == nil {
+			continue
+		}
+		validationErr := new(protovalidate.ValidationError)
+		if errors.As(hookErr, &validationErr) {
+			merged.Violations = append(merged.Violations, validationErr.Violations...)
+			continue
+		}
+		if connectErr := new(connect.Error); errors.As(hookErr, &connectErr) {
+			return hookErr
+		}
+		return &messageHookError{err: hookErr}
+	}
+	if len(merged.Violations) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// messageHookError is an error returned by a message hook that doesn't
+// report violations.
+type messageHookError struct {
+	err error
+}
+
+func (e *messageHookError) Error() string { return "message hook: " + e.err.Error() }
+
+func (e *messageHookError) Unwrap() error { return e.err }
@@ -0,0 +1,144 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWithMessageHook(t *testing.T) {
+	t.Parallel()
+	emailField := &validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("email")}
+	userField := &validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("user")}
+	interceptor, err := validate.NewInterceptor(
+		validate.WithMessageHook(func(_ context.Context, req *userv1.CreateUserRequest) error {
+			if req.GetUser().GetHandle() != "taken" {
+				return nil
+			}
+			return &protovalidate.ValidationError{Violations: []*validate.Violation{
+				newViolation("user.unique_handle", userField, emailField),
+			}}
+		}),
+		validate.WithMessageHook(func(_ context.Context, req *userv1.CreateUserRequest) error {
+			if req.GetUser().GetHandle() != "taken" {
+				return nil
+			}
+			return &protovalidate.ValidationError{Violations: []*validate.Violation{
+				newViolation("user.checksum"),
+			}}
+		}),
+		validate.WithMessageHook(func(context.Context, *userv1.UpdateUserRequest) error {
+			return errors.New("unexpected call")
+		}),
+	)
+	require.NoError(t, err)
+	client := newUserClient(t, interceptor)
+
+	_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "someone@example.com", Handle: "free"},
+	}))
+	require.NoError(t, err)
+
+	_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo", Handle: "taken"},
+	}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	violations := violationsOf(t, err)
+	require.Len(t, violations, 3)
+	assert.Equal(t, "string.email", violations[0].GetConstraintId())
+	assert.Equal(t, "user.unique_handle", violations[1].GetConstraintId())
+	assert.Equal(t, "user.email", protovalidate.FieldPathString(violations[1].GetField()))
+	assert.Equal(t, "user.checksum", violations[2].GetConstraintId())
+
+	config := interceptor.EffectiveConfig()
+	assert.Equal(t, []string{
+		"example.user.v1.CreateUserRequest",
+		"example.user.v1.UpdateUserRequest",
+	}, config.Messages.MessageHooks)
+}
+
+func TestWithMessageHookErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		err      error
+		opts     []validate.Option
+		wantCode connect.Code
+	}{
+		{
+			name:     "connect",
+			err:      connect.NewError(connect.CodeUnavailable, errors.New("database unavailable")),
+			wantCode: connect.CodeUnavailable,
+		},
+		{
+			name:     "other",
+			err:      errors.New("database timeout"),
+			wantCode: connect.CodeInternal,
+		},
+		{
+			name:     "validator_error_code",
+			err:      errors.New("database timeout"),
+			opts:     []validate.Option{validate.WithValidatorErrorCode(connect.CodeUnavailable)},
+			wantCode: connect.CodeUnavailable,
+		},
+		{
+			name: "fail_open",
+			err:  errors.New("database timeout"),
+			opts: []validate.Option{validate.WithFailOpen("/example.user.v1.UserService/*")},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			opts := append([]validate.Option{
+				validate.WithMessageHook(func(context.Context, *userv1.CreateUserRequest) error {
+					return test.err
+				}),
+			}, test.opts...)
+			interceptor, err := validate.NewInterceptor(opts...)
+			require.NoError(t, err)
+			_, err = newUserClient(t, interceptor).CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+				User: &userv1.User{Email: "someone@example.com"},
+			}))
+			if test.wantCode == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, test.wantCode, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestWithMessageHookInterface(t *testing.T) {
+	t.Parallel()
+	_, err := validate.NewInterceptor(
+		validate.WithMessageHook(func(context.Context, proto.Message) error { return nil }),
+	)
+	require.Error(t, err)
+}
@@ -0,0 +1,155 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// A StreamInvariant is a CEL expression that must hold for every message of
+// one type on a stream. Unlike protovalidate's constraints, which see one
+// message at a time, invariants can refer to a running aggregate of the
+// stream. Expressions may use these variables:
+//
+//   - this: the current message
+//   - prev: the previous message of the same type, or an empty message if
+//     this is the first
+//   - index: the position of this message among messages of its type,
+//     starting at 0
+//   - total_size: the combined size in bytes of this message and all
+//     previous messages of its type
+//
+// For example, "index == 0 || this.seq > prev.seq" requires sequence numbers
+// to increase strictly, and "total_size <= 1048576" caps the size of a
+// stream at 1 MiB.
+//
+// As with protovalidate's CEL constraints, the expression may evaluate to a
+// bool, where false is a violation, or to a string, where a non-empty string
+// is a violation with that message.
+type StreamInvariant struct {
+	ID         string // constraint ID of violations
+	Message    string // message of violations, if the expression evaluates to a bool
+	Expression string
+}
+
+// WithStreamInvariants configures the [Interceptor] to check invariants for
+// messages of the given type on streams. Violations are reported with the
+// path "stream[i]", where i is the message's position on the stream. Unary
+// RPCs aren't affected.
+func WithStreamInvariants(msg protoreflect.MessageDescriptor, invariants ...StreamInvariant) Option {
+	return optionFunc(func(i *Interceptor) {
+		for _, invariant := range invariants {
+			i.streams.invariants = append(i.streams.invariants, &streamInvariant{msg: msg, StreamInvariant: invariant})
+		}
+	})
+}
+
+type streamInvariant struct {
+	StreamInvariant
+
+	msg     protoreflect.MessageDescriptor
+	program cel.Program // compiled in compile
+}
+
+func (s *streamInvariant) compile() error {
+	typeName := string(s.msg.FullName())
+	env, err := cel.NewEnv(
+		cel.TypeDescs(s.msg.ParentFile()),
+		cel.Variable("this", cel.ObjectType(typeName)),
+		cel.Variable("prev", cel.ObjectType(typeName)),
+		cel.Variable("index", cel.IntType),
+		cel.Variable("total_size", cel.IntType),
+	)
+	if err != nil {
+		return fmt.Errorf("stream invariant %q: %w", s.ID, err)
+	}
+	ast, issues := env.Compile(s.Expression)
+	if issues.Err() != nil {
+		return fmt.Errorf("stream invariant %q: %w", s.ID, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.StringType {
+		return fmt.Errorf("stream invariant %q: expression must evaluate to a bool or a string, not %s", s.ID, ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return fmt.Errorf("stream invariant %q: %w", s.ID, err)
+	}
+	s.program = program
+	return nil
+}
+
+// invariantState is the running aggregate of one invariant on one stream.
+type invariantState struct {
+	prev      proto.Message
+	index     int64
+	totalSize int64
+}
+
+// check evaluates the invariant for the next message on a stream, returning a
+// violation if it doesn't hold. Position is the message's position on the
+// stream.
+func (s *streamInvariant) check(state *invariantState, msg proto.Message, position int) (*protovalidate.Violation, error) {
+	prev := state.prev
+	if prev == nil {
+		prev = dynamicpb.NewMessage(s.msg)
+	}
+	state.totalSize += int64(proto.Size(msg))
+	val, _, err := s.program.Eval(map[string]any{
+		"this":       msg,
+		"prev":       prev,
+		"index":      state.index,
+		"total_size": state.totalSize,
+	})
+	// Clone the message, since senders may reuse it.
+	state.prev = proto.Clone(msg)
+	state.index++
+	if err != nil {
+		return nil, fmt.Errorf("stream invariant %q: %w", s.ID, err)
+	}
+	message := s.Message
+	switch val := val.(type) {
+	case types.Bool:
+		if val {
+			return nil, nil
+		}
+	case types.String:
+		if val == "" {
+			return nil, nil
+		}
+		message = string(val)
+	default:
+		return nil, fmt.Errorf("stream invariant %q: unexpected result %v", s.ID, val)
+	}
+	return &protovalidate.Violation{
+		Proto: &validatepb.Violation{
+			Field: &validatepb.FieldPath{
+				Elements: []*validatepb.FieldPathElement{{
+					FieldName: proto.String("stream"),
+					Subscript: &validatepb.FieldPathElement_Index{Index: uint64(position)},
+				}},
+			},
+			ConstraintId: proto.String(s.ID),
+			Message:      proto.String(message),
+		},
+	}, nil
+}
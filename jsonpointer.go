@@ -0,0 +1,84 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"strconv"
+	"strings"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// JSONPointer converts the field path of a violation into a [JSON Pointer]
+// into the JSON form of a message, so that errors reported to REST clients
+// match the paths in OpenAPI schemas generated from the same Protobuf schema.
+// The path must be relative to msg, the descriptor of the validated message.
+//
+// Fields are named with their JSON names, which honor the json_name option,
+// and list indexes and map keys become their own reference tokens. For
+// example, the path "user.birth_date" becomes "/user/birthDate", and
+// "update_mask.paths[1]" becomes "/updateMask/paths/1". If a field in the path
+// isn't part of msg, it and the rest of the path keep their Protobuf names.
+//
+// [JSON Pointer]: https://www.rfc-editor.org/rfc/rfc6901
+func JSONPointer(msg protoreflect.MessageDescriptor, path *validatepb.FieldPath) string {
+	var builder strings.Builder
+	desc := msg
+	for _, element := range path.GetElements() {
+		var field protoreflect.FieldDescriptor
+		if desc != nil {
+			field = desc.Fields().ByNumber(protoreflect.FieldNumber(element.GetFieldNumber()))
+		}
+		if field == nil || string(field.Name()) != element.GetFieldName() {
+			field = nil
+			desc = nil
+			writeJSONPointerToken(&builder, element.GetFieldName())
+		} else {
+			writeJSONPointerToken(&builder, field.JSONName())
+			desc = field.Message()
+			if field.IsMap() {
+				desc = field.MapValue().Message()
+			}
+		}
+		switch subscript := element.GetSubscript().(type) {
+		case *validatepb.FieldPathElement_Index:
+			writeJSONPointerToken(&builder, strconv.FormatUint(subscript.Index, 10))
+		case *validatepb.FieldPathElement_BoolKey:
+			writeJSONPointerToken(&builder, strconv.FormatBool(subscript.BoolKey))
+		case *validatepb.FieldPathElement_IntKey:
+			writeJSONPointerToken(&builder, strconv.FormatInt(subscript.IntKey, 10))
+		case *validatepb.FieldPathElement_UintKey:
+			writeJSONPointerToken(&builder, strconv.FormatUint(subscript.UintKey, 10))
+		case *validatepb.FieldPathElement_StringKey:
+			writeJSONPointerToken(&builder, subscript.StringKey)
+		}
+	}
+	return builder.String()
+}
+
+func writeJSONPointerToken(builder *strings.Builder, token string) {
+	builder.WriteByte('/')
+	for _, r := range token {
+		switch r {
+		case '~':
+			builder.WriteString("~0")
+		case '/':
+			builder.WriteString("~1")
+		default:
+			builder.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"testing"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestJSONPointer(t *testing.T) {
+	t.Parallel()
+	desc := (&userv1.UpdateUserRequest{}).ProtoReflect().Descriptor()
+	tests := []struct {
+		name     string
+		elements []*validatepb.FieldPathElement
+		want     string
+	}{
+		{
+			name: "empty",
+			want: "",
+		},
+		{
+			name: "nested",
+			elements: []*validatepb.FieldPathElement{
+				{FieldNumber: proto.Int32(1), FieldName: proto.String("user")},
+				{FieldNumber: proto.Int32(2), FieldName: proto.String("birth_date")},
+			},
+			want: "/user/birthDate",
+		},
+		{
+			name: "index",
+			elements: []*validatepb.FieldPathElement{
+				{FieldNumber: proto.Int32(2), FieldName: proto.String("update_mask")},
+				{
+					FieldNumber: proto.Int32(1),
+					FieldName:   proto.String("paths"),
+					Subscript:   &validatepb.FieldPathElement_Index{Index: 3},
+				},
+			},
+			want: "/updateMask/paths/3",
+		},
+		{
+			name: "unknown_field",
+			elements: []*validatepb.FieldPathElement{
+				{FieldNumber: proto.Int32(1), FieldName: proto.String("user")},
+				{
+					FieldNumber: proto.Int32(42),
+					FieldName:   proto.String("labels"),
+					Subscript:   &validatepb.FieldPathElement_StringKey{StringKey: "a/b~c"},
+				},
+				{FieldNumber: proto.Int32(1), FieldName: proto.String("display_name")},
+			},
+			want: "/user/labels/a~1b~0c/display_name",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			path := &validatepb.FieldPath{Elements: test.elements}
+			assert.Equal(t, test.want, validate.JSONPointer(desc, path))
+		})
+	}
+}
+
+func TestJSONPointerFromViolation(t *testing.T) {
+	t.Parallel()
+	validator, err := protovalidate.New()
+	require.NoError(t, err)
+	msg := &userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	}
+	err = validator.Validate(msg)
+	var validationErr *protovalidate.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Violations, 1)
+	path := validationErr.Violations[0].Proto.GetField()
+	assert.Equal(t, "/user/email", validate.JSONPointer(msg.ProtoReflect().Descriptor(), path))
+}
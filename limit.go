@@ -0,0 +1,269 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+)
+
+// An Overflow chooses what happens to messages that arrive while a
+// concurrency limit is saturated.
+type Overflow int
+
+const (
+	// OverflowReject fails the RPC immediately with
+	// [connect.CodeResourceExhausted]. It's the default.
+	OverflowReject Overflow = iota
+	// OverflowSkip passes the message through without validating it.
+	OverflowSkip
+	// OverflowWait queues the message until a slot frees up or the RPC's
+	// context is done.
+	OverflowWait
+)
+
+// String implements [fmt.Stringer].
+func (o Overflow) String() string {
+	switch o {
+	case OverflowReject:
+		return "reject"
+	case OverflowSkip:
+		return "skip"
+	case OverflowWait:
+		return "wait"
+	}
+	return fmt.Sprintf("overflow_%d", int(o))
+}
+
+// WithProcedureConcurrencyLimit configures the [Interceptor] to validate at
+// most n messages at a time for each procedure, which keeps validation-heavy
+// procedures from monopolizing the CPU during traffic spikes. Messages that
+// arrive while a procedure is at its limit are rejected, passed through
+// unvalidated, or queued, as chosen by the [Overflow]. Procedures in
+// [ModeWarn] never reject overflowing messages. Non-positive values of n
+// don't limit concurrency.
+func WithProcedureConcurrencyLimit(n int, overflow Overflow) Option {
+	return optionFunc(func(i *Interceptor) {
+		if n <= 0 {
+			i.limits.procedureLimiter = nil
+			return
+		}
+		i.limits.procedureLimiter = &procedureLimiter{limit: n, overflow: overflow}
+	})
+}
+
+// WithConcurrencyLimit configures the [Interceptor] to validate at most n
+// messages at a time across all procedures, which caps validation's share of
+// the CPU when CEL-heavy messages compete with handlers during load spikes.
+// Messages that arrive while the limit is reached are rejected, passed through
+// unvalidated, or queued, as chosen by the [Overflow]. Procedures in
+// [ModeWarn] never reject overflowing messages. It can be combined with
+// [WithProcedureConcurrencyLimit], in which case a message needs a slot under
+// both limits. Non-positive values of n don't limit concurrency.
+func WithConcurrencyLimit(n int, overflow Overflow) Option {
+	return optionFunc(func(i *Interceptor) {
+		if n <= 0 {
+			i.limits.concurrencyLimiter = nil
+			return
+		}
+		i.limits.concurrencyLimiter = &concurrencyLimiter{
+			slots:    make(chan struct{}, n),
+			overflow: overflow,
+		}
+	})
+}
+
+// An OversizePolicy chooses what happens to messages larger than the limit
+// configured with [WithMaxMessageSize].
+type OversizePolicy int
+
+const (
+	// OversizeReject fails the RPC with [connect.CodeResourceExhausted]. It's
+	// the default.
+	OversizeReject OversizePolicy = iota
+	// OversizeSkip passes the message through without validating it.
+	OversizeSkip
+)
+
+// String implements [fmt.Stringer].
+func (p OversizePolicy) String() string {
+	switch p {
+	case OversizeReject:
+		return "reject"
+	case OversizeSkip:
+		return "skip"
+	}
+	return fmt.Sprintf("oversize_%d", int(p))
+}
+
+// WithMaxMessageSize configures the [Interceptor] to measure each message
+// with [proto.Size] before validating it, and to reject messages larger than
+// n bytes or pass them through unvalidated, as chosen by the
+// [OversizePolicy]. It protects the validator from spending the CPU on giant
+// messages. Measuring is much cheaper than validating, but it still walks the
+// whole message: the size on the wire isn't available to interceptors.
+// Procedures in [ModeWarn] never reject oversized messages. Non-positive
+// values of n don't limit sizes.
+//
+// [proto.Size]: https://pkg.go.dev/google.golang.org/protobuf/proto#Size
+func WithMaxMessageSize(n int, policy OversizePolicy) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.limits.maxMessageSize = n
+		i.limits.oversizePolicy = policy
+	})
+}
+
+// checkSize checks the size of msg against the limit configured with
+// WithMaxMessageSize. If msg is too large, it returns false, along with the
+// error to fail the RPC with, if any.
+func (i *Interceptor) checkSize(mode Mode, msg proto.Message) (bool, error) {
+	size := proto.Size(msg)
+	if size <= i.limits.maxMessageSize {
+		return true, nil
+	}
+	if i.limits.oversizePolicy != OversizeReject || mode != ModeEnforce {
+		return false, nil
+	}
+	return false, connect.NewError(
+		connect.CodeResourceExhausted,
+		fmt.Errorf("message is %d bytes, larger than the %d bytes allowed for validation", size, i.limits.maxMessageSize),
+	)
+}
+
+// WithLimitExemptions configures the [Interceptor] to exempt procedures
+// matching any of the patterns, which use the syntax of [path.Match], from the
+// limits on validation: [WithMaxMessageSize], [WithCollectionBudget],
+// [WithConcurrencyLimit], [WithProcedureConcurrencyLimit], and
+// [WithValidationTimeout]. It's meant for RPCs where thorough
+// validation is worth the cost, like administrative bulk imports, so that one
+// global budget needn't fit every procedure. Exempt procedures are still
+// validated like any other.
+func WithLimitExemptions(patterns ...string) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.limits.limitExemptions = append(i.limits.limitExemptions, patterns...)
+	})
+}
+
+// limitExempt reports whether the procedure is exempt from the limits on
+// validation.
+func (i *Interceptor) limitExempt(procedure string) bool {
+	for _, pattern := range i.limits.limitExemptions {
+		if ok, _ := path.Match(pattern, procedure); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// concurrencyLimiter is a semaphore shared by all procedures.
+type concurrencyLimiter struct {
+	slots    chan struct{}
+	overflow Overflow
+}
+
+// procedureLimiter holds a semaphore for each procedure.
+type procedureLimiter struct {
+	limit    int
+	overflow Overflow
+
+	mu         sync.Mutex
+	semaphores map[string]chan struct{}
+}
+
+// semaphore returns the procedure's semaphore.
+func (l *procedureLimiter) semaphore(procedure string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	semaphore, ok := l.semaphores[procedure]
+	if !ok {
+		if l.semaphores == nil {
+			l.semaphores = make(map[string]chan struct{})
+		}
+		semaphore = make(chan struct{}, l.limit)
+		l.semaphores[procedure] = semaphore
+	}
+	return semaphore
+}
+
+// reserve reserves a slot in semaphore for validating a message. If it
+// succeeds, it returns a function that releases the slot. Otherwise, the
+// message isn't validated, and err is the error to fail the RPC with, if any.
+// The limit describes the semaphore in errors.
+func reserve(ctx context.Context, mode Mode, semaphore chan struct{}, overflow Overflow, limit string) (release func(), ok bool, err error) {
+	release = func() { <-semaphore }
+	select {
+	case semaphore <- struct{}{}:
+		return release, true, nil
+	default:
+	}
+	if overflow == OverflowWait {
+		select {
+		case semaphore <- struct{}{}:
+			return release, true, nil
+		case <-ctx.Done():
+			if mode != ModeEnforce {
+				return nil, false, nil
+			}
+			return nil, false, contextError(ctx.Err())
+		}
+	}
+	if overflow != OverflowReject || mode != ModeEnforce {
+		return nil, false, nil
+	}
+	return nil, false, connect.NewError(
+		connect.CodeResourceExhausted,
+		fmt.Errorf("too many concurrent validations %s", limit),
+	)
+}
+
+type heldSlotsKey struct{}
+
+// heldSlots holds the concurrency slots reserved to validate a message, so
+// that a validation abandoned because of a timeout can keep them until it
+// actually finishes.
+type heldSlots struct {
+	releases []func()
+	detached bool
+}
+
+// release releases the slots, unless they were detached.
+func (h *heldSlots) release() {
+	if h.detached {
+		return
+	}
+	for _, release := range h.releases {
+		release()
+	}
+}
+
+// detach transfers the slots in ctx, if any, to the caller, who must call the
+// returned function once validation finishes.
+func detachSlots(ctx context.Context) func() {
+	slots, ok := ctx.Value(heldSlotsKey{}).(*heldSlots)
+	if !ok {
+		return func() {}
+	}
+	slots.detached = true
+	return func() {
+		for _, release := range slots.releases {
+			release()
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestWithProcedureConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		overflow validate.Overflow
+		wantCode connect.Code
+	}{
+		{name: "reject", overflow: validate.OverflowReject, wantCode: connect.CodeResourceExhausted},
+		{name: "skip", overflow: validate.OverflowSkip},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			validator := newBlockingValidator()
+			interceptor, err := validate.NewInterceptor(
+				validate.WithValidator(validator),
+				validate.WithProcedureConcurrencyLimit(1, test.overflow),
+			)
+			require.NoError(t, err)
+			call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+			})
+			req := connect.NewRequest(&userv1.CreateUserRequest{})
+
+			first := make(chan error, 1)
+			go func() {
+				_, err := call(context.Background(), req)
+				first <- err
+			}()
+			<-validator.entered
+
+			_, err = call(context.Background(), req)
+			if test.wantCode > 0 {
+				assert.Equal(t, test.wantCode, connect.CodeOf(err))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, int64(1), validator.calls.Load())
+
+			close(validator.release)
+			require.NoError(t, <-first)
+			// The slot is free again.
+			_, err = call(context.Background(), req)
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), validator.calls.Load())
+		})
+	}
+}
+
+func TestWithConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+	newCall := func(t *testing.T, overflow validate.Overflow) (connect.UnaryFunc, *blockingValidator) {
+		t.Helper()
+		validator := newBlockingValidator()
+		interceptor, err := validate.NewInterceptor(
+			validate.WithValidator(validator),
+			validate.WithConcurrencyLimit(1, overflow),
+		)
+		require.NoError(t, err)
+		return interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+			return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+		}), validator
+	}
+	req := connect.NewRequest(&userv1.CreateUserRequest{})
+
+	t.Run("reject", func(t *testing.T) {
+		t.Parallel()
+		call, validator := newCall(t, validate.OverflowReject)
+		first := make(chan error, 1)
+		go func() {
+			_, err := call(context.Background(), req)
+			first <- err
+		}()
+		<-validator.entered
+		_, err := call(context.Background(), req)
+		assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+		close(validator.release)
+		require.NoError(t, <-first)
+	})
+	t.Run("wait", func(t *testing.T) {
+		t.Parallel()
+		call, validator := newCall(t, validate.OverflowWait)
+		first := make(chan error, 1)
+		go func() {
+			_, err := call(context.Background(), req)
+			first <- err
+		}()
+		<-validator.entered
+
+		// Waiting stops when the context is done.
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := call(ctx, req)
+		assert.Equal(t, connect.CodeDeadlineExceeded, connect.CodeOf(err))
+
+		second := make(chan error, 1)
+		go func() {
+			_, err := call(context.Background(), req)
+			second <- err
+		}()
+		close(validator.release)
+		require.NoError(t, <-first)
+		require.NoError(t, <-second)
+		assert.Equal(t, int64(2), validator.calls.Load())
+	})
+}
+
+func TestWithLimitExemptions(t *testing.T) {
+	t.Parallel()
+	const (
+		importProcedure = "/acme.admin.v1.ImportService/Import"
+		otherProcedure  = "/acme.v1.StructService/Echo"
+	)
+	interceptor, err := validate.NewInterceptor(
+		validate.WithCollectionBudget(1),
+		validate.WithLimitExemptions("/acme.admin.v1.*/*"),
+	)
+	require.NoError(t, err)
+	echo := func(_ context.Context, req *connect.Request[structpb.Struct]) (*connect.Response[structpb.Struct], error) {
+		return connect.NewResponse(req.Msg), nil
+	}
+	mux := http.NewServeMux()
+	for _, procedure := range []string{importProcedure, otherProcedure} {
+		mux.Handle(procedure, connect.NewUnaryHandler(procedure, echo, connect.WithInterceptors(interceptor)))
+	}
+	srv := startHTTPServer(t, mux)
+	msg, err := structpb.NewStruct(map[string]any{"a": 1, "b": 2})
+	require.NoError(t, err)
+
+	client := connect.NewClient[structpb.Struct, structpb.Struct](srv.Client(), srv.URL+importProcedure)
+	_, err = client.CallUnary(context.Background(), connect.NewRequest(msg))
+	require.NoError(t, err)
+	client = connect.NewClient[structpb.Struct, structpb.Struct](srv.Client(), srv.URL+otherProcedure)
+	_, err = client.CallUnary(context.Background(), connect.NewRequest(msg))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func TestWithMaxMessageSize(t *testing.T) {
+	t.Parallel()
+	small := &userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}}
+	large := &userv1.CreateUserRequest{User: &userv1.User{Email: strings.Repeat("foo", 100)}}
+	tests := []struct {
+		name     string
+		policy   validate.OversizePolicy
+		msg      *userv1.CreateUserRequest
+		wantCode connect.Code
+	}{
+		{name: "small", msg: small, wantCode: connect.CodeInvalidArgument},
+		{name: "reject", policy: validate.OversizeReject, msg: large, wantCode: connect.CodeResourceExhausted},
+		{name: "skip", policy: validate.OversizeSkip, msg: large},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithMaxMessageSize(100, test.policy))
+			require.NoError(t, err)
+			_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+			})(context.Background(), connect.NewRequest(test.msg))
+			if test.wantCode == 0 {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, test.wantCode, connect.CodeOf(err))
+			}
+		})
+	}
+}
+
+// blockingValidator accepts every message, but blocks until released. Each
+// call signals entered first.
+type blockingValidator struct {
+	entered chan struct{}
+	release chan struct{}
+	calls   atomic.Int64
+}
+
+func newBlockingValidator() *blockingValidator {
+	return &blockingValidator{
+		entered: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+}
+
+func (v *blockingValidator) Validate(proto.Message) error {
+	v.calls.Add(1)
+	v.entered <- struct{}{}
+	<-v.release
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// WithInvalidRequestLogger configures the [Interceptor] to log a sample of
+// invalid messages, whether they're rejected or only warned about. The
+// sampleRate is the fraction of invalid messages to log, from 0 to 1.
+//
+// By default, log records never contain field values. Instead, they describe
+// the message's shape: the names and types of the populated fields, and the
+// lengths of strings, bytes, lists, and maps. To log more of the message, use
+// [WithPayloadSerializer]. Violations are logged as field paths and constraint
+// IDs, without their messages, which may quote values.
+func WithInvalidRequestLogger(logger *slog.Logger, sampleRate float64) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.telemetry.requestLogger = &requestLogger{
+			logger:     logger,
+			sampleRate: sampleRate,
+		}
+	})
+}
+
+type requestLogger struct {
+	logger     *slog.Logger
+	sampleRate float64
+	serializer PayloadSerializer
+}
+
+func (l *requestLogger) log(ctx context.Context, spec connect.Spec, msg proto.Message, validationErr *protovalidate.ValidationError) {
+	attrs := []slog.Attr{slog.String("procedure", spec.Procedure)}
+	if payload, err := l.serializer.SerializePayload(msg); err != nil {
+		attrs = append(attrs, slog.String("message_error", err.Error()))
+	} else {
+		attrs = append(attrs, slog.String("message", string(payload)))
+	}
+	if id, ok := RejectionID(ctx); ok {
+		attrs = append(attrs, slog.String("rejection_id", id))
+	}
+	if validationErr != nil {
+		// Messages may quote field values, so they're left out.
+		violations := make([]*Violation, len(validationErr.Violations))
+		for i, violation := range validationErr.Violations {
+			violations[i] = &Violation{Proto: &validatepb.Violation{
+				Field:        violation.Proto.GetField(),
+				Rule:         violation.Proto.GetRule(),
+				ConstraintId: proto.String(violation.Proto.GetConstraintId()),
+			}}
+		}
+		attrs = append(attrs, slog.String("violations", FormatViolations(violations, FormatText)))
+	}
+	l.logger.LogAttrs(ctx, slog.LevelWarn, "invalid request", attrs...)
+}
+
+// describeMessage renders the populated fields of a message without their
+// values, as in "acme.v1.User{email: string(len=3), tags: [2]string}".
+func describeMessage(msg protoreflect.Message) string {
+	var builder strings.Builder
+	writeMessage(&builder, msg)
+	return builder.String()
+}
+
+func writeMessage(builder *strings.Builder, msg protoreflect.Message) {
+	builder.WriteString(string(msg.Descriptor().FullName()))
+	builder.WriteByte('{')
+	fields := msg.Descriptor().Fields()
+	first := true
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		if !msg.Has(field) {
+			continue
+		}
+		if !first {
+			builder.WriteString(", ")
+		}
+		first = false
+		builder.WriteString(string(field.Name()))
+		builder.WriteString(": ")
+		value := msg.Get(field)
+		switch {
+		case field.IsMap():
+			fmt.Fprintf(builder, "map[%s]%s(len=%d)", kindName(field.MapKey()), kindName(field.MapValue()), value.Map().Len())
+		case field.IsList():
+			fmt.Fprintf(builder, "[%d]%s", value.List().Len(), kindName(field))
+		case field.Message() != nil:
+			writeMessage(builder, value.Message())
+		case field.Kind() == protoreflect.StringKind:
+			fmt.Fprintf(builder, "string(len=%d)", len(value.String()))
+		case field.Kind() == protoreflect.BytesKind:
+			fmt.Fprintf(builder, "bytes(len=%d)", len(value.Bytes()))
+		default:
+			builder.WriteString(kindName(field))
+		}
+	}
+	builder.WriteByte('}')
+}
+
+func kindName(field protoreflect.FieldDescriptor) string {
+	switch {
+	case field.Message() != nil:
+		return string(field.Message().FullName())
+	case field.Enum() != nil:
+		return string(field.Enum().FullName())
+	default:
+		return field.Kind().String()
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithInvalidRequestLogger(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		sampleRate float64
+		email      string
+		wantLog    bool
+	}{
+		{name: "invalid", sampleRate: 1, email: "secret-address", wantLog: true},
+		{name: "valid", sampleRate: 1, email: "someone@example.com"},
+		{name: "unsampled", sampleRate: 0, email: "secret-address"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+			interceptor, err := validate.NewInterceptor(validate.WithInvalidRequestLogger(logger, test.sampleRate))
+			require.NoError(t, err)
+
+			req := connect.NewRequest(&userv1.CreateUserRequest{
+				User: &userv1.User{Email: test.email},
+			})
+			_, _ = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+			})(context.Background(), req)
+
+			if !test.wantLog {
+				assert.Empty(t, buf.String())
+				return
+			}
+			got := buf.String()
+			assert.Contains(t, got, "invalid request")
+			assert.Contains(t, got, "example.user.v1.CreateUserRequest{user: example.user.v1.User{email: string(len=14)}}")
+			assert.Contains(t, got, "user.email [string.email]")
+			assert.NotContains(t, got, test.email)
+		})
+	}
+}
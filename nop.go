@@ -0,0 +1,27 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+// NopInterceptor returns an Interceptor that doesn't validate anything: its
+// interceptor methods return the functions they wrap unchanged, and
+// [Interceptor.Batch] reports every message as valid. Test suites can inject
+// it in place of a configured Interceptor to turn enforcement off without
+// changing how handlers are wired.
+//
+// The Interceptor's validator is still available from
+// [Interceptor.Validator], and is constructed on first use.
+func NopInterceptor() *Interceptor {
+	return &Interceptor{nop: true, validators: validatorSettings{lazyInit: true}}
+}
@@ -0,0 +1,46 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNopInterceptor(t *testing.T) {
+	t.Parallel()
+	interceptor := validate.NopInterceptor()
+	invalid := &userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}}
+
+	client := newUserClient(t, interceptor)
+	_, err := client.CreateUser(context.Background(), connect.NewRequest(invalid))
+	require.NoError(t, err)
+
+	errs := interceptor.Batch(context.Background(), []proto.Message{invalid})
+	assert.Equal(t, []error{nil}, errs)
+	assert.True(t, interceptor.EffectiveConfig().Nop)
+
+	// The validator still works out of band.
+	validator, err := interceptor.Validator()
+	require.NoError(t, err)
+	assert.Error(t, validator.Validate(invalid))
+}
@@ -0,0 +1,112 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/bufbuild/protovalidate-go"
+)
+
+// WithSortedViolations configures the [Interceptor] to report violations in a
+// stable order, rather than the order the validator found them in, so that
+// golden tests and clients mapping violations to form fields see the same
+// errors on every run. Violations are sorted by field path, comparing the
+// paths element by element: by field name, then by list index or map key, with
+// a path sorting before the paths it's a prefix of. Violations of the same
+// field are sorted by constraint ID, and then by message.
+//
+// Sorting happens before violations are summarized or limited, so
+// [WithViolationSummary] and [WithMaxViolations] keep the first violations
+// in this order.
+func WithSortedViolations() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.violations.sortViolations = true
+	})
+}
+
+// sortViolations sorts the violations of err in place.
+func sortViolations(err *protovalidate.ValidationError) {
+	slices.SortStableFunc(err.Violations, func(a, b *Violation) int {
+		if c := compareFieldPaths(a.Proto.GetField(), b.Proto.GetField()); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Proto.GetConstraintId(), b.Proto.GetConstraintId()); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Proto.GetMessage(), b.Proto.GetMessage())
+	})
+}
+
+func compareFieldPaths(a, b *validatepb.FieldPath) int {
+	aElements, bElements := a.GetElements(), b.GetElements()
+	for n := 0; n < min(len(aElements), len(bElements)); n++ {
+		if c := strings.Compare(aElements[n].GetFieldName(), bElements[n].GetFieldName()); c != 0 {
+			return c
+		}
+		if c := compareSubscripts(aElements[n], bElements[n]); c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(aElements), len(bElements))
+}
+
+// compareSubscripts compares the list indexes or map keys of two elements.
+// Elements without a subscript sort first, and subscripts of different kinds
+// sort by kind.
+func compareSubscripts(a, b *validatepb.FieldPathElement) int {
+	if c := cmp.Compare(subscriptKind(a), subscriptKind(b)); c != 0 {
+		return c
+	}
+	switch a.GetSubscript().(type) {
+	case *validatepb.FieldPathElement_Index:
+		return cmp.Compare(a.GetIndex(), b.GetIndex())
+	case *validatepb.FieldPathElement_BoolKey:
+		return cmp.Compare(boolOrder(a.GetBoolKey()), boolOrder(b.GetBoolKey()))
+	case *validatepb.FieldPathElement_IntKey:
+		return cmp.Compare(a.GetIntKey(), b.GetIntKey())
+	case *validatepb.FieldPathElement_UintKey:
+		return cmp.Compare(a.GetUintKey(), b.GetUintKey())
+	case *validatepb.FieldPathElement_StringKey:
+		return strings.Compare(a.GetStringKey(), b.GetStringKey())
+	}
+	return 0
+}
+
+func subscriptKind(element *validatepb.FieldPathElement) int {
+	switch element.GetSubscript().(type) {
+	case *validatepb.FieldPathElement_Index:
+		return 1
+	case *validatepb.FieldPathElement_BoolKey:
+		return 2
+	case *validatepb.FieldPathElement_IntKey:
+		return 3
+	case *validatepb.FieldPathElement_UintKey:
+		return 4
+	case *validatepb.FieldPathElement_StringKey:
+		return 5
+	}
+	return 0
+}
+
+func boolOrder(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWithSortedViolations(t *testing.T) {
+	t.Parallel()
+	item := func(index uint64) *validatepb.FieldPathElement {
+		return &validatepb.FieldPathElement{
+			FieldNumber: proto.Int32(2),
+			FieldName:   proto.String("items"),
+			Subscript:   &validatepb.FieldPathElement_Index{Index: index},
+		}
+	}
+	sku := &validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("sku")}
+	name := &validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("name")}
+	validator := staticValidator{err: &protovalidate.ValidationError{Violations: []*validate.Violation{
+		newViolation("string.min_len", item(10), sku),
+		newViolation("string.min_len", name),
+		newViolation("string.pattern", item(2), sku),
+		newViolation("required", item(2)),
+		newViolation("required", name),
+		newViolation("string.min_len", item(2), sku),
+	}}}
+	interceptor, err := validate.NewInterceptor(
+		validate.WithValidator(validator),
+		validate.WithSortedViolations(),
+	)
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+	require.Error(t, err)
+	var got []string
+	for _, violation := range violationsOf(t, err) {
+		got = append(got, protovalidate.FieldPathString(violation.GetField())+" "+violation.GetConstraintId())
+	}
+	assert.Equal(t, []string{
+		"items[2] required",
+		"items[2].sku string.min_len",
+		"items[2].sku string.pattern",
+		"items[10].sku string.min_len",
+		"name required",
+		"name string.min_len",
+	}, got)
+}
@@ -0,0 +1,128 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"encoding/base64"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// A PayloadSerializer renders the invalid messages captured by the
+// [Interceptor], for example in the records of [WithInvalidRequestLogger].
+// Different compliance regimes allow different levels of retention, so the
+// package offers [SkeletonPayloads], [RedactedJSONPayloads], [JSONPayloads],
+// and [BinaryPayloads], from least to most revealing.
+//
+// Serializers must be safe to call concurrently.
+type PayloadSerializer interface {
+	SerializePayload(msg proto.Message) ([]byte, error)
+}
+
+// WithPayloadSerializer configures the [Interceptor] to capture invalid
+// messages with the given [PayloadSerializer]. The default is
+// [SkeletonPayloads].
+func WithPayloadSerializer(serializer PayloadSerializer) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.telemetry.payloadSerializer = serializer
+	})
+}
+
+// SkeletonPayloads returns a [PayloadSerializer] that describes a message's
+// shape without any field values: the names and types of the populated
+// fields, and the lengths of strings, bytes, lists, and maps. For example,
+// "acme.v1.User{email: string(len=3), tags: [2]string}".
+func SkeletonPayloads() PayloadSerializer {
+	return skeletonSerializer{}
+}
+
+// RedactedJSONPayloads returns a [PayloadSerializer] that renders messages
+// as JSON, omitting fields marked with the debug_redact option.
+func RedactedJSONPayloads() PayloadSerializer {
+	return redactedJSONSerializer{}
+}
+
+// JSONPayloads returns a [PayloadSerializer] that renders messages as JSON,
+// including fields marked with the debug_redact option. It's meant for
+// development, where seeing every value outweighs keeping secrets out of logs.
+func JSONPayloads() PayloadSerializer {
+	return jsonSerializer{}
+}
+
+// BinaryPayloads returns a [PayloadSerializer] that preserves messages
+// exactly, as base64-encoded Protobuf binary.
+func BinaryPayloads() PayloadSerializer {
+	return binarySerializer{}
+}
+
+type skeletonSerializer struct{}
+
+func (skeletonSerializer) SerializePayload(msg proto.Message) ([]byte, error) {
+	return []byte(describeMessage(msg.ProtoReflect())), nil
+}
+
+type redactedJSONSerializer struct{}
+
+func (redactedJSONSerializer) SerializePayload(msg proto.Message) ([]byte, error) {
+	clone := proto.Clone(msg)
+	clearRedacted(clone.ProtoReflect())
+	return protojson.Marshal(clone)
+}
+
+// clearRedacted clears fields marked with the debug_redact option from msg
+// and the messages it contains.
+func clearRedacted(msg protoreflect.Message) {
+	var redacted []protoreflect.FieldDescriptor
+	msg.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case isRedacted(field):
+			redacted = append(redacted, field)
+		case field.IsList() && field.Message() != nil:
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				clearRedacted(list.Get(i).Message())
+			}
+		case field.IsMap() && field.MapValue().Message() != nil:
+			value.Map().Range(func(_ protoreflect.MapKey, value protoreflect.Value) bool {
+				clearRedacted(value.Message())
+				return true
+			})
+		case !field.IsList() && !field.IsMap() && field.Message() != nil:
+			clearRedacted(value.Message())
+		}
+		return true
+	})
+	for _, field := range redacted {
+		msg.Clear(field)
+	}
+}
+
+type jsonSerializer struct{}
+
+func (jsonSerializer) SerializePayload(msg proto.Message) ([]byte, error) {
+	return protojson.Marshal(msg)
+}
+
+type binarySerializer struct{}
+
+func (binarySerializer) SerializePayload(msg proto.Message) ([]byte, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(data)), nil
+}
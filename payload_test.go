@@ -0,0 +1,120 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPayloadSerializers(t *testing.T) {
+	t.Parallel()
+	msg := &userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo", Handle: "admin-bob"},
+	}
+	tests := []struct {
+		name       string
+		serializer validate.PayloadSerializer
+		check      func(*testing.T, []byte)
+	}{
+		{
+			name:       "skeleton",
+			serializer: validate.SkeletonPayloads(),
+			check: func(t *testing.T, payload []byte) {
+				t.Helper()
+				assert.Equal(
+					t,
+					"example.user.v1.CreateUserRequest{user: example.user.v1.User{email: string(len=3), handle: string(len=9)}}",
+					string(payload),
+				)
+			},
+		},
+		{
+			name:       "redacted_json",
+			serializer: validate.RedactedJSONPayloads(),
+			check: func(t *testing.T, payload []byte) {
+				t.Helper()
+				var got userv1.CreateUserRequest
+				require.NoError(t, protojson.Unmarshal(payload, &got))
+				want := &userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}}
+				assert.True(t, proto.Equal(want, &got), "got %v", &got)
+			},
+		},
+		{
+			name:       "json",
+			serializer: validate.JSONPayloads(),
+			check: func(t *testing.T, payload []byte) {
+				t.Helper()
+				var got userv1.CreateUserRequest
+				require.NoError(t, protojson.Unmarshal(payload, &got))
+				assert.True(t, proto.Equal(msg, &got), "got %v", &got)
+			},
+		},
+		{
+			name:       "binary",
+			serializer: validate.BinaryPayloads(),
+			check: func(t *testing.T, payload []byte) {
+				t.Helper()
+				data, err := base64.StdEncoding.DecodeString(string(payload))
+				require.NoError(t, err)
+				var got userv1.CreateUserRequest
+				require.NoError(t, proto.Unmarshal(data, &got))
+				assert.True(t, proto.Equal(msg, &got), "got %v", &got)
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			payload, err := test.serializer.SerializePayload(msg)
+			require.NoError(t, err)
+			test.check(t, payload)
+		})
+	}
+	// Serializers don't modify the message.
+	assert.Equal(t, "admin-bob", msg.GetUser().GetHandle())
+}
+
+func TestWithPayloadSerializer(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	interceptor, err := validate.NewInterceptor(
+		validate.WithInvalidRequestLogger(slog.New(slog.NewTextHandler(&buf, nil)), 1),
+		validate.WithPayloadSerializer(validate.RedactedJSONPayloads()),
+	)
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo", Handle: "admin-bob"},
+	}))
+	require.Error(t, err)
+	got := buf.String()
+	assert.Contains(t, got, "invalid request")
+	assert.Contains(t, got, "foo")
+	assert.NotContains(t, got, "admin-bob")
+}
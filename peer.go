@@ -0,0 +1,73 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"net/netip"
+
+	"connectrpc.com/connect"
+)
+
+// WithPeerDetailLevel configures the [Interceptor] to choose how much of each
+// validation error to reveal based on the calling peer, so that rule IDs
+// aren't leaked to the public internet but stay available to internal
+// callers for debugging. The classifier is called with the [connect.Peer] of
+// each RPC handled by the Interceptor; [PrivatePeers] is a classifier based on
+// the peer's network address. If [WithDetailLevel] is also configured, the
+// more restrictive of the two levels applies, and [WithoutErrorDetails]
+// takes precedence over both. Clients don't classify their peers.
+func WithPeerDetailLevel(classify func(connect.Peer) DetailLevel) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.peerDetailLevel = classify
+	})
+}
+
+// PrivatePeers is a classifier for [WithPeerDetailLevel] that reveals every
+// violation, with [DetailFull], to peers with loopback, private, or
+// link-local addresses, and nothing, with [DetailNone], to every other peer,
+// including peers with unknown addresses. Behind a proxy, the peer is the
+// proxy itself, so classify peers by the headers it sets instead.
+func PrivatePeers(peer connect.Peer) DetailLevel {
+	addrPort, err := netip.ParseAddrPort(peer.Addr)
+	if err != nil {
+		return DetailNone
+	}
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() {
+		return DetailFull
+	}
+	return DetailNone
+}
+
+type peerKey struct{}
+
+// withPeer returns a copy of ctx that records the calling peer, if peers are
+// classified.
+func (i *Interceptor) withPeer(ctx context.Context, spec connect.Spec, peer connect.Peer) context.Context {
+	if i.reporting.peerDetailLevel == nil || spec.IsClient {
+		return ctx
+	}
+	return context.WithValue(ctx, peerKey{}, peer)
+}
+
+// peerDetailLevelOf returns the detail level for the peer recorded in ctx.
+func (i *Interceptor) peerDetailLevelOf(ctx context.Context) (DetailLevel, bool) {
+	peer, ok := ctx.Value(peerKey{}).(connect.Peer)
+	if !ok {
+		return DetailFull, false
+	}
+	return i.reporting.peerDetailLevel(peer), true
+}
@@ -0,0 +1,94 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithPeerDetailLevel(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		classify    func(connect.Peer) validate.DetailLevel
+		wantMessage string
+		wantDetails int
+	}{
+		{
+			name:        "private",
+			classify:    validate.PrivatePeers,
+			wantMessage: "validation error:\n - user.email: value must be a valid email address [string.email]",
+			wantDetails: 1,
+		},
+		{
+			name: "untrusted",
+			classify: func(peer connect.Peer) validate.DetailLevel {
+				assert.NotEmpty(t, peer.Addr)
+				return validate.DetailNone
+			},
+			wantMessage: "invalid request",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithPeerDetailLevel(test.classify))
+			require.NoError(t, err)
+			_, err = newUserClient(t, interceptor).CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+				User: &userv1.User{Email: "foo"},
+			}))
+			require.Error(t, err)
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			var connectErr *connect.Error
+			require.ErrorAs(t, err, &connectErr)
+			assert.Equal(t, test.wantMessage, connectErr.Message())
+			assert.Len(t, connectErr.Details(), test.wantDetails)
+		})
+	}
+}
+
+func TestPrivatePeers(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		addr string
+		want validate.DetailLevel
+	}{
+		{addr: "127.0.0.1:8080", want: validate.DetailFull},
+		{addr: "[::1]:8080", want: validate.DetailFull},
+		{addr: "10.1.2.3:443", want: validate.DetailFull},
+		{addr: "192.168.0.10:443", want: validate.DetailFull},
+		{addr: "[fd00::1]:443", want: validate.DetailFull},
+		{addr: "[::ffff:10.0.0.1]:443", want: validate.DetailFull},
+		{addr: "203.0.113.7:443", want: validate.DetailNone},
+		{addr: "[2001:db8::1]:443", want: validate.DetailNone},
+		{addr: "", want: validate.DetailNone},
+		{addr: "example.com:443", want: validate.DetailNone},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.addr, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, validate.PrivatePeers(connect.Peer{Addr: test.addr}))
+		})
+	}
+}
@@ -0,0 +1,392 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// methodOptionsName is the type that options disabling validation extend.
+const methodOptionsName = "google.protobuf.MethodOptions"
+
+// A Mode describes how an [Interceptor] treats the messages of an RPC.
+type Mode int
+
+const (
+	// ModeEnforce validates messages and rejects invalid ones. It's the
+	// default.
+	ModeEnforce Mode = iota
+	// ModeWarn validates messages, but reports failures to the function
+	// configured with [WithWarnFunc] rather than rejecting the RPC.
+	ModeWarn
+	// ModeSkip doesn't validate messages at all.
+	ModeSkip
+)
+
+// String implements [fmt.Stringer].
+func (m Mode) String() string {
+	switch m {
+	case ModeEnforce:
+		return "enforce"
+	case ModeWarn:
+		return "warn"
+	case ModeSkip:
+		return "skip"
+	}
+	return fmt.Sprintf("mode_%d", int(m))
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (m Mode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// A Policy chooses a [Mode] for each procedure. Policies are built from
+// procedure patterns, which use the syntax of [path.Match]: for example,
+// "/acme.user.v1.UserService/*" matches every method of UserService, and
+// "/acme.user.v1.UserService/CreateUser" matches a single method. When more
+// than one pattern matches a procedure, the pattern added last wins, and
+// procedures that don't match any pattern are enforced.
+//
+// Policies are immutable values, and the zero value is ready to use. Each
+// builder method returns a new Policy, so a base policy can be shared and
+// extended:
+//
+//	policy := validate.Policy{}.
+//		Warn("/acme.legacy.v1.*/*").
+//		Skip("/acme.legacy.v1.BlobService/Upload")
+type Policy struct {
+	rules []policyRule
+}
+
+type policyRule struct {
+	pattern      string
+	mode         Mode
+	enforceAfter time.Time // for ModeWarn rules, when to start enforcing
+}
+
+func (r policyRule) modeAt(now time.Time) Mode {
+	if r.mode == ModeWarn && !r.enforceAfter.IsZero() && !now.Before(r.enforceAfter) {
+		return ModeEnforce
+	}
+	return r.mode
+}
+
+// Enforce returns a copy of the Policy that enforces validation for
+// procedures matching any of the patterns.
+func (p Policy) Enforce(patterns ...string) Policy {
+	return p.with(policyRule{mode: ModeEnforce}, patterns)
+}
+
+// Warn returns a copy of the Policy that only warns about invalid messages
+// for procedures matching any of the patterns.
+func (p Policy) Warn(patterns ...string) Policy {
+	return p.with(policyRule{mode: ModeWarn}, patterns)
+}
+
+// WarnUntil returns a copy of the Policy that only warns about invalid
+// messages for procedures matching any of the patterns until enforceAfter,
+// and enforces validation from then on. Scheduling the promotion up front
+// means it can't be forgotten; use [WithPromotionHook] to be notified as it
+// approaches.
+func (p Policy) WarnUntil(enforceAfter time.Time, patterns ...string) Policy {
+	return p.with(policyRule{mode: ModeWarn, enforceAfter: enforceAfter}, patterns)
+}
+
+// Skip returns a copy of the Policy that skips validation for procedures
+// matching any of the patterns.
+func (p Policy) Skip(patterns ...string) Policy {
+	return p.with(policyRule{mode: ModeSkip}, patterns)
+}
+
+// Mode returns the current mode for a procedure.
+func (p Policy) Mode(procedure string) Mode {
+	return p.ModeAt(procedure, time.Now())
+}
+
+// ModeAt returns the mode for a procedure at the given time. It differs from
+// [Policy.Mode] only for procedures governed by [Policy.WarnUntil].
+func (p Policy) ModeAt(procedure string, now time.Time) Mode {
+	if index := p.match(procedure); index >= 0 {
+		return p.rules[index].modeAt(now)
+	}
+	return ModeEnforce
+}
+
+// match returns the index of the rule governing a procedure, or -1 if no rule
+// matches.
+func (p Policy) match(procedure string) int {
+	for i := len(p.rules) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(p.rules[i].pattern, procedure); ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func (p Policy) with(template policyRule, patterns []string) Policy {
+	rules := make([]policyRule, len(p.rules), len(p.rules)+len(patterns))
+	copy(rules, p.rules)
+	for _, pattern := range patterns {
+		rule := template
+		rule.pattern = pattern
+		rules = append(rules, rule)
+	}
+	return Policy{rules: rules}
+}
+
+func (p Policy) check() error {
+	for _, rule := range p.rules {
+		if _, err := path.Match(rule.pattern, ""); err != nil {
+			return fmt.Errorf("invalid procedure pattern %q: %w", rule.pattern, err)
+		}
+	}
+	return nil
+}
+
+// WithPolicy configures the [Interceptor] to enforce, warn about, or skip
+// validation according to the [Policy]. It replaces any policy configured by
+// earlier options.
+func WithPolicy(policy Policy) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.policy = policy
+	})
+}
+
+// WithSkipProcedures configures the [Interceptor] to pass messages for the
+// named procedures through without validation, while still validating every
+// other procedure. Procedures are named in full, as in
+// "/acme.user.v1.UserService/CreateUser". Skipped procedures take precedence
+// over the Interceptor's [Policy], regardless of the order of the options.
+// Calling it more than once adds to the list.
+func WithSkipProcedures(procedures ...string) Option {
+	return optionFunc(func(i *Interceptor) {
+		if i.scope.skipProcedures == nil {
+			i.scope.skipProcedures = make(map[string]struct{}, len(procedures))
+		}
+		for _, procedure := range procedures {
+			i.scope.skipProcedures[procedure] = struct{}{}
+		}
+	})
+}
+
+// WithSkipMessageTypes configures the [Interceptor] to pass messages of the
+// named types through without validation, regardless of which procedure
+// carries them. It's useful for large messages that don't benefit from
+// validation, like the chunks of a streaming upload.
+func WithSkipMessageTypes(names ...protoreflect.FullName) Option {
+	return optionFunc(func(i *Interceptor) {
+		if i.scope.skipTypes == nil {
+			i.scope.skipTypes = make(map[protoreflect.FullName]struct{}, len(names))
+		}
+		for _, name := range names {
+			i.scope.skipTypes[name] = struct{}{}
+		}
+	})
+}
+
+// WithOnlyMessageTypes configures the [Interceptor] to validate only messages
+// of the named types, and to pass every other message through without
+// validation, including messages that don't implement [proto.Message]. On a
+// mux where only a few procedures have constraints, it avoids the overhead of
+// validating the rest. Calling it more than once adds to the list. If a type
+// is also named by [WithSkipMessageTypes], it's skipped.
+func WithOnlyMessageTypes(names ...protoreflect.FullName) Option {
+	return optionFunc(func(i *Interceptor) {
+		if i.scope.onlyTypes == nil {
+			i.scope.onlyTypes = make(map[protoreflect.FullName]struct{}, len(names))
+		}
+		for _, name := range names {
+			i.scope.onlyTypes[name] = struct{}{}
+		}
+	})
+}
+
+// WithDisableOption configures the [Interceptor] to skip validation for
+// methods annotated with a boolean method option, so that the decision lives
+// next to the API definition. The option is an extension of
+// google.protobuf.MethodOptions defined in the application's own Protobuf
+// files, like:
+//
+//	extend google.protobuf.MethodOptions {
+//	  bool skip_validation = 50000;
+//	}
+//
+//	service BlobService {
+//	  rpc Upload(stream UploadRequest) returns (UploadResponse) {
+//	    option (acme.options.v1.skip_validation) = true;
+//	  }
+//	}
+//
+// Pass the extension's generated type, like
+// optionsv1.E_SkipValidation. Methods are read from [connect.Spec.Schema],
+// which generated Connect code populates; RPCs without a method descriptor
+// are validated as usual. NewInterceptor returns an error if the extension
+// isn't a boolean extension of google.protobuf.MethodOptions.
+func WithDisableOption(extension protoreflect.ExtensionType) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.scope.disableOption = &disableOption{extension: extension}
+	})
+}
+
+// disableOption checks methods for an option that disables validation,
+// caching the result for each procedure.
+type disableOption struct {
+	extension protoreflect.ExtensionType
+	cache     sync.Map // procedure to bool
+}
+
+func (o *disableOption) check() error {
+	desc := o.extension.TypeDescriptor()
+	if desc.ContainingMessage().FullName() != methodOptionsName || desc.Kind() != protoreflect.BoolKind || desc.IsList() {
+		return fmt.Errorf("disable option %s: not a boolean extension of %s", desc.FullName(), methodOptionsName)
+	}
+	return nil
+}
+
+func (o *disableOption) disabled(spec connect.Spec) bool {
+	if cached, ok := o.cache.Load(spec.Procedure); ok {
+		disabled, _ := cached.(bool)
+		return disabled
+	}
+	method, ok := spec.Schema.(protoreflect.MethodDescriptor)
+	if !ok {
+		return false
+	}
+	disabled, _ := proto.GetExtension(method.Options(), o.extension).(bool)
+	o.cache.Store(spec.Procedure, disabled)
+	return disabled
+}
+
+// WithProcedureMatcher configures the [Interceptor] to validate only the RPCs
+// for which the matcher returns true. The matcher sees the full
+// [connect.Spec], so it can decide based on the procedure, the stream type,
+// or whether the Interceptor is running on a client. RPCs that the matcher
+// accepts are then validated according to the Interceptor's [Policy].
+func WithProcedureMatcher(matcher func(connect.Spec) bool) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.scope.matcher = matcher
+	})
+}
+
+// WithDryRun configures the [Interceptor] to validate messages but never
+// reject them: every procedure the [Policy] would enforce is treated as if it
+// were in [ModeWarn]. Failures are still reported to the function configured
+// with [WithWarnFunc], to the logger configured with
+// [WithInvalidRequestLogger], and to clients with [WithWarningTrailers], so
+// new constraints can be rolled out on a live API and observed before they're
+// enforced. Skipped procedures stay skipped.
+func WithDryRun() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.warnings.dryRun = true
+	})
+}
+
+// WithWarnFunc configures the [Interceptor] to call a function whenever a
+// procedure in [ModeWarn] receives an invalid message. The error is the one
+// the Interceptor would have returned if the procedure were enforced. It's
+// also called for violations demoted with [WithRuleSeverity]. Without a
+// WarnFunc, warnings are discarded.
+func WithWarnFunc(warn func(ctx context.Context, spec connect.Spec, err error)) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.warnings.warnFunc = warn
+	})
+}
+
+// A Promotion describes a scheduled change from warning about to enforcing
+// validation, configured with [Policy.WarnUntil].
+type Promotion struct {
+	Pattern      string    // procedure pattern of the WarnUntil rule
+	EnforceAfter time.Time // when the rule starts enforcing validation
+	Promoted     bool      // false for advance notice, true once enforcing
+}
+
+// WithPromotionHook configures the [Interceptor] to call a hook as the
+// scheduled promotions in its [Policy] approach and take effect. For each
+// [Policy.WarnUntil] rule, the hook is called at most twice: once with advance
+// notice, during the lead time before EnforceAfter, and once after the rule
+// starts enforcing validation.
+//
+// Promotions aren't tracked in the background. Instead, the hook is called
+// from the first RPC that the rule governs in each period, so rules for idle
+// procedures may promote silently. Hooks must be safe to call concurrently.
+func WithPromotionHook(lead time.Duration, hook func(context.Context, Promotion)) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.warnings.promotionLead = lead
+		i.warnings.promotionHook = hook
+	})
+}
+
+// promotionState records which hooks have fired for a WarnUntil rule.
+type promotionState struct {
+	noticed  atomic.Bool
+	promoted atomic.Bool
+}
+
+func (i *Interceptor) mode(ctx context.Context, spec connect.Spec) Mode {
+	if i.scope.contextBypass && skipRequested(ctx) {
+		return ModeSkip
+	}
+	if _, ok := i.scope.skipProcedures[spec.Procedure]; ok {
+		return ModeSkip
+	}
+	if i.scope.matcher != nil && !i.scope.matcher(spec) {
+		return ModeSkip
+	}
+	if i.scope.disableOption != nil && i.scope.disableOption.disabled(spec) {
+		return ModeSkip
+	}
+	index := i.policy.match(spec.Procedure)
+	if index < 0 {
+		return i.dryRunMode(ModeEnforce)
+	}
+	rule := i.policy.rules[index]
+	now := i.now()
+	if i.warnings.promotionHook != nil && !rule.enforceAfter.IsZero() && rule.mode == ModeWarn {
+		i.notifyPromotion(ctx, &i.warnings.promotions[index], rule, now)
+	}
+	return i.dryRunMode(rule.modeAt(now))
+}
+
+func (i *Interceptor) dryRunMode(mode Mode) Mode {
+	if i.warnings.dryRun && mode == ModeEnforce {
+		return ModeWarn
+	}
+	return mode
+}
+
+func (i *Interceptor) notifyPromotion(ctx context.Context, state *promotionState, rule policyRule, now time.Time) {
+	promotion := Promotion{Pattern: rule.pattern, EnforceAfter: rule.enforceAfter}
+	switch {
+	case !now.Before(rule.enforceAfter):
+		if state.promoted.CompareAndSwap(false, true) {
+			promotion.Promoted = true
+			i.warnings.promotionHook(ctx, promotion)
+		}
+	case !now.Before(rule.enforceAfter.Add(-i.warnings.promotionLead)):
+		if state.noticed.CompareAndSwap(false, true) {
+			i.warnings.promotionHook(ctx, promotion)
+		}
+	}
+}
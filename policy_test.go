@@ -0,0 +1,677 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	calculatorv1 "connectrpc.com/validate/internal/gen/example/calculator/v1"
+	"connectrpc.com/validate/internal/gen/example/calculator/v1/calculatorv1connect"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestPolicyMode(t *testing.T) {
+	t.Parallel()
+	policy := validate.Policy{}.
+		Warn("/acme.legacy.v1.*/*").
+		Skip("/acme.legacy.v1.BlobService/Upload").
+		Enforce("/acme.legacy.v1.UserService/*")
+	tests := []struct {
+		procedure string
+		want      validate.Mode
+	}{
+		{procedure: "/acme.user.v1.UserService/CreateUser", want: validate.ModeEnforce},
+		{procedure: "/acme.legacy.v1.BlobService/Download", want: validate.ModeWarn},
+		{procedure: "/acme.legacy.v1.BlobService/Upload", want: validate.ModeSkip},
+		{procedure: "/acme.legacy.v1.UserService/CreateUser", want: validate.ModeEnforce},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, policy.Mode(test.procedure), test.procedure)
+	}
+	// Scheduled promotions take effect at the deadline.
+	deadline := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	scheduled := validate.Policy{}.WarnUntil(deadline, "/a/*")
+	assert.Equal(t, validate.ModeWarn, scheduled.ModeAt("/a/b", deadline.Add(-time.Second)))
+	assert.Equal(t, validate.ModeEnforce, scheduled.ModeAt("/a/b", deadline))
+	// Builders don't modify the receiver.
+	base := validate.Policy{}.Warn("/a/*")
+	_ = base.Skip("/a/*")
+	assert.Equal(t, validate.ModeWarn, base.Mode("/a/b"))
+}
+
+func TestWithPolicy(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		policy   validate.Policy
+		wantCode connect.Code
+		wantWarn bool
+	}{
+		{
+			name:     "enforce",
+			policy:   validate.Policy{}.Warn("/example.user.v1.UserService/*").Enforce(userv1connect.UserServiceCreateUserProcedure),
+			wantCode: connect.CodeInvalidArgument,
+		},
+		{
+			name:     "warn",
+			policy:   validate.Policy{}.Warn("/example.user.v1.UserService/*"),
+			wantWarn: true,
+		},
+		{
+			name:   "skip",
+			policy: validate.Policy{}.Skip(userv1connect.UserServiceCreateUserProcedure),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			warnings := make(chan error, 1)
+			interceptor, err := validate.NewInterceptor(
+				validate.WithPolicy(test.policy),
+				validate.WithWarnFunc(func(_ context.Context, spec connect.Spec, err error) {
+					assert.Equal(t, userv1connect.UserServiceCreateUserProcedure, spec.Procedure)
+					warnings <- err
+				}),
+			)
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+				userv1connect.UserServiceCreateUserProcedure,
+				createUser,
+				connect.WithInterceptors(interceptor),
+			))
+			srv := startHTTPServer(t, mux)
+
+			_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+				CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+					User: &userv1.User{Email: "foo"},
+				}))
+			if test.wantCode > 0 {
+				assert.Equal(t, test.wantCode, connect.CodeOf(err))
+			} else {
+				require.NoError(t, err)
+			}
+			if test.wantWarn {
+				require.Len(t, warnings, 1)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(<-warnings))
+			} else {
+				assert.Empty(t, warnings)
+			}
+		})
+	}
+}
+
+func TestWithPromotionHook(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	promotions := make(chan validate.Promotion, 4)
+	interceptor, err := validate.NewInterceptor(
+		validate.WithPolicy(validate.Policy{}.
+			WarnUntil(now.Add(-time.Hour), userv1connect.UserServiceCreateUserProcedure).
+			WarnUntil(now.Add(time.Hour), userv1connect.UserServiceUpdateUserProcedure),
+		),
+		validate.WithPromotionHook(2*time.Hour, func(_ context.Context, promotion validate.Promotion) {
+			promotions <- promotion
+		}),
+	)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	mux.Handle(userv1connect.UserServiceUpdateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceUpdateUserProcedure,
+		updateUser,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(t, mux)
+	client := userv1connect.NewUserServiceClient(srv.Client(), srv.URL)
+
+	// Each hook fires once, no matter how many RPCs the rule governs.
+	invalid := &userv1.User{Email: "foo"}
+	for i := 0; i < 2; i++ {
+		_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: invalid}))
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		_, err = client.UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{User: invalid}))
+		require.NoError(t, err)
+	}
+	require.Len(t, promotions, 2)
+	promoted := <-promotions
+	assert.Equal(t, userv1connect.UserServiceCreateUserProcedure, promoted.Pattern)
+	assert.True(t, promoted.Promoted)
+	noticed := <-promotions
+	assert.Equal(t, userv1connect.UserServiceUpdateUserProcedure, noticed.Pattern)
+	assert.False(t, noticed.Promoted)
+	assert.True(t, noticed.EnforceAfter.After(now))
+}
+
+func TestWithDryRun(t *testing.T) {
+	t.Parallel()
+	warnings := make(chan error, 2)
+	interceptor, err := validate.NewInterceptor(
+		validate.WithDryRun(),
+		validate.WithSkipProcedures(userv1connect.UserServiceUpdateUserProcedure),
+		validate.WithWarnFunc(func(_ context.Context, _ connect.Spec, err error) {
+			warnings <- err
+		}),
+	)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	mux.Handle(userv1connect.UserServiceUpdateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceUpdateUserProcedure,
+		updateUser,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(t, mux)
+	client := userv1connect.NewUserServiceClient(srv.Client(), srv.URL)
+
+	invalid := &userv1.User{Email: "foo"}
+	res, err := client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: invalid}))
+	require.NoError(t, err)
+	assert.Equal(t, "foo", res.Msg.GetUser().GetEmail())
+	_, err = client.UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{User: invalid}))
+	require.NoError(t, err)
+
+	// Only the enforced procedure reports a warning.
+	require.Len(t, warnings, 1)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(<-warnings))
+}
+
+func TestWithContextBypass(t *testing.T) {
+	t.Parallel()
+	breakGlass := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+			if req.Header().Get("Break-Glass") != "" {
+				ctx = validate.SkipValidation(ctx)
+			}
+			return next(ctx, req)
+		}
+	})
+	tests := []struct {
+		name       string
+		opts       []validate.Option
+		breakGlass bool
+		wantErr    bool
+	}{
+		{name: "bypassed", opts: []validate.Option{validate.WithContextBypass()}, breakGlass: true},
+		{name: "unmarked", opts: []validate.Option{validate.WithContextBypass()}, wantErr: true},
+		{name: "disabled", breakGlass: true, wantErr: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(test.opts...)
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+				userv1connect.UserServiceCreateUserProcedure,
+				createUser,
+				connect.WithInterceptors(breakGlass, interceptor),
+			))
+			srv := startHTTPServer(t, mux)
+
+			req := connect.NewRequest(&userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}})
+			if test.breakGlass {
+				req.Header().Set("Break-Glass", "INC-1234")
+			}
+			_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+				CreateUser(context.Background(), req)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWithHeaderBypass(t *testing.T) {
+	t.Parallel()
+	requireSecret := func(header http.Header, _ connect.Peer) bool {
+		return header.Get("X-Validate-Secret") == "hunter2"
+	}
+	allowAll := func(http.Header, connect.Peer) bool { return true }
+	tests := []struct {
+		name    string
+		allow   func(http.Header, connect.Peer) bool
+		header  map[string]string
+		wantErr bool
+	}{
+		{name: "no_header", allow: allowAll, wantErr: true},
+		{name: "other_value", allow: allowAll, header: map[string]string{"X-Validate": "on"}, wantErr: true},
+		{name: "bypassed", allow: allowAll, header: map[string]string{"X-Validate": "off"}},
+		{
+			name:   "allowed",
+			allow:  requireSecret,
+			header: map[string]string{"X-Validate": "off", "X-Validate-Secret": "hunter2"},
+		},
+		{
+			name:    "disallowed",
+			allow:   requireSecret,
+			header:  map[string]string{"X-Validate": "off", "X-Validate-Secret": "guess"},
+			wantErr: true,
+		},
+	}
+	_, err := validate.NewInterceptor(validate.WithHeaderBypass("X-Validate", "off", nil))
+	require.Error(t, err)
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithHeaderBypass("X-Validate", "off", test.allow))
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+				userv1connect.UserServiceCreateUserProcedure,
+				createUser,
+				connect.WithInterceptors(interceptor),
+			))
+			srv := startHTTPServer(t, mux)
+
+			req := connect.NewRequest(&userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}})
+			for key, value := range test.header {
+				req.Header().Set(key, value)
+			}
+			_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+				CreateUser(context.Background(), req)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWithWarningConverter(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(
+		validate.WithPolicy(validate.Policy{}.Warn(userv1connect.UserServiceCreateUserProcedure)),
+		validate.WithWarningConverter(func(err *protovalidate.ValidationError) proto.Message {
+			return wrapperspb.String(err.Violations[0].Proto.GetConstraintId())
+		}),
+	)
+	require.NoError(t, err)
+
+	warnings := make(chan []proto.Message, 2)
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		func(ctx context.Context, req *connect.Request[userv1.CreateUserRequest]) (*connect.Response[userv1.CreateUserResponse], error) {
+			warnings <- validate.Warnings(ctx)
+			return createUser(ctx, req)
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(t, mux)
+	client := userv1connect.NewUserServiceClient(srv.Client(), srv.URL)
+
+	_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	}))
+	require.NoError(t, err)
+	got := <-warnings
+	require.Len(t, got, 1)
+	assert.True(t, proto.Equal(wrapperspb.String("string.email"), got[0]))
+
+	_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "someone@example.com"},
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, <-warnings)
+}
+
+func TestWithWarningTrailers(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(
+		validate.WithDryRun(),
+		validate.WithWarningTrailers(),
+	)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	mux.Handle(clientStreamProcedure, connect.NewClientStreamHandler(
+		clientStreamProcedure,
+		func(_ context.Context, stream *connect.ClientStream[calculatorv1.CumSumRequest]) (*connect.Response[calculatorv1.CumSumResponse], error) {
+			var sum int64
+			for stream.Receive() {
+				sum += stream.Msg().GetNumber()
+			}
+			return connect.NewResponse(&calculatorv1.CumSumResponse{Sum: sum}), stream.Err()
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(t, mux)
+
+	t.Run("unary", func(t *testing.T) {
+		t.Parallel()
+		client := userv1connect.NewUserServiceClient(srv.Client(), srv.URL)
+		res, err := client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "foo", Handle: "admin1"},
+		}))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"user.email [string.email]", "user.handle [user.handle]"}, res.Trailer().Values(validate.WarningsTrailer))
+
+		res, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "someone@example.com"},
+		}))
+		require.NoError(t, err)
+		assert.Empty(t, res.Trailer().Values(validate.WarningsTrailer))
+	})
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+		client := connect.NewClient[calculatorv1.CumSumRequest, calculatorv1.CumSumResponse](
+			srv.Client(), srv.URL+clientStreamProcedure,
+		)
+		stream := client.CallClientStream(context.Background())
+		for _, number := range []int64{1, 0, 2, -1} {
+			require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: number}))
+		}
+		res, err := stream.CloseAndReceive()
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), res.Msg.GetSum())
+		assert.Equal(t, []string{"number [int64.gt]", "number [int64.gt]"}, res.Trailer().Values(validate.WarningsTrailer))
+	})
+}
+
+func TestWarningTrailersDetailLevel(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		opt  validate.Option
+		want []string
+	}{
+		{
+			name: "field_paths",
+			opt: validate.WithDetailLevel(func(context.Context, connect.Spec) validate.DetailLevel {
+				return validate.DetailFieldPaths
+			}),
+			want: []string{"user.email", "user.handle"},
+		},
+		{
+			name: "without_rules",
+			opt:  validate.WithoutRuleDetails(),
+			want: []string{"user.email", "user.handle"},
+		},
+		{
+			name: "untrusted_peer",
+			opt: validate.WithPeerDetailLevel(func(connect.Peer) validate.DetailLevel {
+				return validate.DetailNone
+			}),
+		},
+		{
+			name: "without_details",
+			opt:  validate.WithoutErrorDetails(),
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(
+				validate.WithDryRun(),
+				validate.WithWarningTrailers(),
+				test.opt,
+			)
+			require.NoError(t, err)
+			res, err := newUserClient(t, interceptor).CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+				User: &userv1.User{Email: "foo", Handle: "admin1"},
+			}))
+			require.NoError(t, err)
+			assert.Equal(t, test.want, res.Trailer().Values(validate.WarningsTrailer))
+		})
+	}
+}
+
+func TestWithSkipProcedures(t *testing.T) {
+	t.Parallel()
+	// Skips apply even if a later policy replaces the earlier one.
+	interceptor, err := validate.NewInterceptor(
+		validate.WithSkipProcedures(userv1connect.UserServiceCreateUserProcedure),
+		validate.WithPolicy(validate.Policy{}.Enforce("/example.user.v1.UserService/*")),
+	)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	mux.Handle(userv1connect.UserServiceUpdateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceUpdateUserProcedure,
+		updateUser,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(t, mux)
+	client := userv1connect.NewUserServiceClient(srv.Client(), srv.URL)
+
+	invalid := &userv1.User{Email: "foo"}
+	_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: invalid}))
+	require.NoError(t, err)
+	_, err = client.UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{User: invalid}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func TestWithSkipMessageTypes(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithSkipMessageTypes(
+		(&userv1.CreateUserRequest{}).ProtoReflect().Descriptor().FullName(),
+	))
+	require.NoError(t, err)
+	next := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+
+	invalid := &userv1.User{Email: "foo"}
+	_, err = next(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: invalid}))
+	require.NoError(t, err)
+	_, err = next(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{User: invalid}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+}
+
+func TestWithOnlyMessageTypes(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithOnlyMessageTypes(
+		(&userv1.UpdateUserRequest{}).ProtoReflect().Descriptor().FullName(),
+	))
+	require.NoError(t, err)
+	next := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+
+	invalid := &userv1.User{Email: "foo"}
+	_, err = next(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: invalid}))
+	require.NoError(t, err)
+	_, err = next(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{User: invalid}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	assert.Equal(t, []string{"example.user.v1.UpdateUserRequest"}, interceptor.EffectiveConfig().Scope.OnlyMessageTypes)
+}
+
+func TestWithDisableOption(t *testing.T) {
+	t.Parallel()
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	optionsFile, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("disable_option_test.proto"),
+		Package:    proto.String("disable.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		Extension: []*descriptorpb.FieldDescriptorProto{
+			{
+				Name:     proto.String("skip_validation"),
+				Number:   proto.Int32(50000),
+				Label:    optional,
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum(),
+				Extendee: proto.String(".google.protobuf.MethodOptions"),
+				JsonName: proto.String("skipValidation"),
+			},
+			{
+				Name:     proto.String("owner"),
+				Number:   proto.Int32(50001),
+				Label:    optional,
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Extendee: proto.String(".google.protobuf.MethodOptions"),
+				JsonName: proto.String("owner"),
+			},
+		},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	skipValidation := dynamicpb.NewExtensionType(optionsFile.Extensions().ByName("skip_validation"))
+	methodOptions := &descriptorpb.MethodOptions{}
+	proto.SetExtension(methodOptions, skipValidation, true)
+	user := (&userv1.CreateUserRequest{}).ProtoReflect().Descriptor()
+	serviceFile, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("disable_service_test.proto"),
+		Package:    proto.String("disable.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{user.ParentFile().Path()},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("UserService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{
+					Name:       proto.String("CreateUser"),
+					InputType:  proto.String("." + string(user.FullName())),
+					OutputType: proto.String("." + string(user.FullName())),
+					Options:    methodOptions,
+				},
+				{
+					Name:       proto.String("UpdateUser"),
+					InputType:  proto.String("." + string(user.FullName())),
+					OutputType: proto.String("." + string(user.FullName())),
+				},
+			},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	methods := serviceFile.Services().Get(0).Methods()
+
+	interceptor, err := validate.NewInterceptor(validate.WithDisableOption(skipValidation))
+	require.NoError(t, err)
+	mux := http.NewServeMux()
+	for n := 0; n < methods.Len(); n++ {
+		procedure := "/disable.test.UserService/" + string(methods.Get(n).Name())
+		mux.Handle(procedure, connect.NewUnaryHandler(
+			procedure,
+			createUser,
+			connect.WithSchema(methods.Get(n)),
+			connect.WithInterceptors(interceptor),
+		))
+	}
+	srv := startHTTPServer(t, mux)
+	invalid := &userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}}
+	for _, test := range []struct {
+		method   string
+		wantCode connect.Code
+	}{
+		{method: "CreateUser"},
+		{method: "UpdateUser", wantCode: connect.CodeInvalidArgument},
+	} {
+		client := connect.NewClient[userv1.CreateUserRequest, userv1.CreateUserResponse](
+			srv.Client(), srv.URL+"/disable.test.UserService/"+test.method,
+		)
+		_, err = client.CallUnary(context.Background(), connect.NewRequest(invalid))
+		if test.wantCode == 0 {
+			assert.NoError(t, err, test.method)
+		} else {
+			assert.Equal(t, test.wantCode, connect.CodeOf(err), test.method)
+		}
+	}
+
+	_, err = validate.NewInterceptor(validate.WithDisableOption(
+		dynamicpb.NewExtensionType(optionsFile.Extensions().ByName("owner")),
+	))
+	assert.Error(t, err)
+}
+
+func TestWithProcedureMatcher(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithProcedureMatcher(func(spec connect.Spec) bool {
+		return spec.StreamType == connect.StreamTypeBidi
+	}))
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	mux.Handle(calculatorv1connect.CalculatorServiceCumSumProcedure, connect.NewBidiStreamHandler(
+		calculatorv1connect.CalculatorServiceCumSumProcedure,
+		cumSumSuccess,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := httptest.NewUnstartedServer(mux)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+		CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "foo"},
+		}))
+	require.NoError(t, err)
+
+	stream := calculatorv1connect.NewCalculatorServiceClient(srv.Client(), srv.URL).CumSum(context.Background())
+	require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: 0}))
+	require.NoError(t, stream.CloseRequest())
+	_, err = stream.Receive()
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	require.NoError(t, stream.CloseResponse())
+}
+
+func TestWithPolicyInvalidPattern(t *testing.T) {
+	t.Parallel()
+	_, err := validate.NewInterceptor(validate.WithPolicy(validate.Policy{}.Skip("/svc/[")))
+	require.Error(t, err)
+}
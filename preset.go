@@ -0,0 +1,68 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+// presetOption bundles options, so that they're applied in order with the
+// others and can be overridden by the options that follow them.
+type presetOption []Option
+
+func (o presetOption) apply(i *Interceptor) {
+	for _, opt := range o {
+		opt.apply(i)
+	}
+}
+
+// PresetDev returns the options suited to development: errors carry
+// [google.rpc.BadRequest] details in addition to the Violations details, each
+// invalid message gets a rejection ID, and the payloads of invalid requests
+// are logged as JSON with every field value, including fields marked with the
+// debug_redact option (see [JSONPayloads]). Logging still needs a logger,
+// configured with [WithInvalidRequestLogger].
+//
+// [google.rpc.BadRequest]: https://pkg.go.dev/google.golang.org/genproto/googleapis/rpc/errdetails#BadRequest
+func PresetDev() Option {
+	return presetOption{
+		WithBadRequestDetails(),
+		WithRejectionIDs(),
+		WithPayloadSerializer(JSONPayloads()),
+	}
+}
+
+// PresetProd returns the options suited to production: panics in the
+// validator become errors (see [WithRecover]), each invalid message gets a
+// rejection ID for matching user reports to logs, errors for messages with
+// more than 20
+// violations report only the first 10 and summarize the rest (see
+// [WithViolationSummary]), and 1% of validations are measured for
+// [Interceptor.ExpensiveMessages]. Logged payloads keep the default
+// [SkeletonPayloads], which reveal no field values. It doesn't limit the size
+// of messages, since the right limits depend on the service; see
+// [WithCollectionBudget] and [WithMaxMessageSize].
+//
+// To change any of these, pass the corresponding option after the preset:
+//
+//	validate.NewInterceptor(
+//		validate.PresetProd(),
+//		validate.WithViolationSummary(50, 20),
+//	)
+func PresetProd() Option {
+	return presetOption{
+		WithRecover(),
+		WithRejectionIDs(),
+		WithViolationSummary(20, 10),
+		WithCostProfiling(0.01),
+		WithPayloadSerializer(SkeletonPayloads()),
+	}
+}
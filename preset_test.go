@@ -0,0 +1,84 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPresets(t *testing.T) {
+	t.Parallel()
+	t.Run("dev", func(t *testing.T) {
+		t.Parallel()
+		interceptor, err := validate.NewInterceptor(validate.PresetDev())
+		require.NoError(t, err)
+		config := interceptor.EffectiveConfig()
+		assert.Equal(t, []string{"buf.validate.Violations", "google.rpc.BadRequest"}, config.Errors.ErrorDetails)
+		assert.True(t, config.Errors.RejectionIDs)
+		assert.False(t, config.Validators.Recover)
+	})
+	t.Run("dev_payloads", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		interceptor, err := validate.NewInterceptor(
+			validate.PresetDev(),
+			validate.WithInvalidRequestLogger(slog.New(slog.NewTextHandler(&buf, nil)), 1),
+		)
+		require.NoError(t, err)
+		_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+			return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+		})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "foo", Handle: "admin-bob"},
+		}))
+		require.Error(t, err)
+		// Payloads include fields marked with debug_redact.
+		assert.Contains(t, buf.String(), "admin-bob")
+	})
+	t.Run("prod", func(t *testing.T) {
+		t.Parallel()
+		interceptor, err := validate.NewInterceptor(validate.PresetProd())
+		require.NoError(t, err)
+		config := interceptor.EffectiveConfig()
+		assert.Equal(t, []string{"buf.validate.Violations", "google.rpc.ErrorInfo (VIOLATIONS_SUMMARIZED)"}, config.Errors.ErrorDetails)
+		assert.True(t, config.Validators.Recover)
+		assert.True(t, config.Errors.RejectionIDs)
+		assert.Zero(t, config.Limits.CollectionBudget)
+		assert.Equal(t, 20, config.Violations.SummaryThreshold)
+		assert.Equal(t, 10, config.Violations.SummaryKeep)
+		assert.InDelta(t, 0.01, config.Telemetry.CostProfilingRate, 0)
+	})
+	t.Run("override", func(t *testing.T) {
+		t.Parallel()
+		interceptor, err := validate.NewInterceptor(
+			validate.WithCostProfiling(0.5),
+			validate.PresetProd(),
+			validate.WithViolationSummary(50, 20),
+		)
+		require.NoError(t, err)
+		config := interceptor.EffectiveConfig()
+		assert.InDelta(t, 0.01, config.Telemetry.CostProfilingRate, 0)
+		assert.Equal(t, 50, config.Violations.SummaryThreshold)
+		assert.Equal(t, 20, config.Violations.SummaryKeep)
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+	"path"
+	"sync"
+
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+)
+
+// ProcedureConfig overrides the [Interceptor]'s behavior for some procedures.
+// See [WithProcedureConfig]. Fields left at their zero value keep the
+// Interceptor's configuration.
+type ProcedureConfig struct {
+	// SkipRequests passes requests through without validation. Responses are
+	// still validated if ValidateResponses is set.
+	SkipRequests bool
+	// ValidateResponses validates the messages that handlers send on server
+	// and bidirectional streams, like [WithStreamingResponses].
+	ValidateResponses bool
+	// Code is the code of the error returned for invalid messages. It takes
+	// precedence over the code chosen with [WithCodeMapper].
+	Code connect.Code
+	// FailFast stops the default validator at the first constraint violation,
+	// like [WithFailFast]. It can't be combined with [WithValidator], and it
+	// doesn't affect validators chosen with [WithValidatorSelector] or set
+	// with [Interceptor.SetValidator].
+	FailFast bool
+}
+
+// WithProcedureConfig configures the [Interceptor] to override its behavior
+// for the procedures matching pattern, so that one Interceptor can serve a
+// mux of many services. Patterns are matched like the patterns of a [Policy]:
+// they may name a procedure in full, as in
+// "/acme.user.v1.UserService/CreateUser", or use wildcards, as in
+// "/acme.user.v1.UserService/*". If several patterns match a procedure, the
+// one added last wins; configurations aren't merged.
+func WithProcedureConfig(pattern string, config ProcedureConfig) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.procedureConfigs = append(i.procedureConfigs, procedureConfig{
+			pattern: pattern,
+			config:  config,
+		})
+	})
+}
+
+type procedureConfig struct {
+	pattern string
+	config  ProcedureConfig
+}
+
+// checkProcedureConfigs reports invalid patterns and configurations that
+// can't be applied.
+func (i *Interceptor) checkProcedureConfigs() error {
+	for _, override := range i.procedureConfigs {
+		if _, err := path.Match(override.pattern, ""); err != nil {
+			return fmt.Errorf("invalid procedure pattern %q: %w", override.pattern, err)
+		}
+		if override.config.FailFast && i.validators.customValidator {
+			return fmt.Errorf("procedure pattern %q: FailFast can't be combined with WithValidator", override.pattern)
+		}
+	}
+	return nil
+}
+
+// procedureConfig returns the configuration for a procedure, which is zero if
+// no pattern matches.
+func (i *Interceptor) procedureConfig(procedure string) ProcedureConfig {
+	for n := len(i.procedureConfigs) - 1; n >= 0; n-- {
+		if ok, _ := path.Match(i.procedureConfigs[n].pattern, procedure); ok {
+			return i.procedureConfigs[n].config
+		}
+	}
+	return ProcedureConfig{}
+}
+
+// lazyValidator is a validator constructed on first use.
+type lazyValidator struct {
+	once      sync.Once
+	validator protovalidate.Validator
+	err       error
+}
+
+// failFastValidator returns the validator for procedures configured with
+// FailFast: a default validator that stops at the first violation, which is
+// constructed on first use.
+func (i *Interceptor) failFastValidator() (protovalidate.Validator, error) {
+	if i.validators.failFast || i.validators.swapped.Load() != nil {
+		return i.defaultValidator()
+	}
+	i.validators.failFastDefault.once.Do(func() {
+		opts := append([]protovalidate.ValidatorOption{}, i.validators.validatorOptions...)
+		opts = append(opts, protovalidate.WithFailFast())
+		i.validators.failFastDefault.validator, i.validators.failFastDefault.err = newDefaultValidator(opts)
+	})
+	return i.validators.failFastDefault.validator, i.validators.failFastDefault.err
+}
@@ -0,0 +1,108 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	calculatorv1 "connectrpc.com/validate/internal/gen/example/calculator/v1"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestWithProcedureConfig(t *testing.T) {
+	t.Parallel()
+	t.Run("unary", func(t *testing.T) {
+		t.Parallel()
+		interceptor, err := validate.NewInterceptor(
+			validate.WithProcedureConfig("/example.user.v1.UserService/*", validate.ProcedureConfig{
+				Code:     connect.CodeFailedPrecondition,
+				FailFast: true,
+			}),
+			validate.WithProcedureConfig(userv1connect.UserServiceCreateUserProcedure, validate.ProcedureConfig{
+				SkipRequests: true,
+			}),
+		)
+		require.NoError(t, err)
+		client := newUserClient(t, interceptor)
+		user := &userv1.User{
+			Email:     "foo",
+			BirthDate: timestamppb.New(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+		}
+
+		// The later, more specific configuration wins.
+		_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: user}))
+		require.NoError(t, err)
+
+		// The message violates two constraints, but validation stops at the
+		// first.
+		_, err = client.UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{User: user}))
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+		requireSingleViolation(t, err)
+	})
+	t.Run("responses", func(t *testing.T) {
+		t.Parallel()
+		interceptor, err := validate.NewInterceptor(
+			validate.WithProcedureConfig(serverStreamProcedure, validate.ProcedureConfig{
+				SkipRequests:      true,
+				ValidateResponses: true,
+			}),
+		)
+		require.NoError(t, err)
+		mux := http.NewServeMux()
+		mux.Handle(serverStreamProcedure, connect.NewServerStreamHandler(
+			serverStreamProcedure,
+			func(_ context.Context, req *connect.Request[calculatorv1.CumSumRequest], stream *connect.ServerStream[calculatorv1.CumSumResponse]) error {
+				return stream.Send(&calculatorv1.CumSumResponse{Sum: req.Msg.Number})
+			},
+			connect.WithInterceptors(interceptor),
+		))
+		srv := httptest.NewUnstartedServer(mux)
+		srv.EnableHTTP2 = true
+		srv.StartTLS()
+		t.Cleanup(srv.Close)
+		client := connect.NewClient[calculatorv1.CumSumRequest, calculatorv1.CumSumResponse](
+			srv.Client(), srv.URL+serverStreamProcedure,
+		)
+
+		// The request is invalid, but only the response is validated.
+		stream, err := client.CallServerStream(context.Background(), connect.NewRequest(&calculatorv1.CumSumRequest{Number: -1}))
+		require.NoError(t, err)
+		assert.False(t, stream.Receive())
+		require.Error(t, stream.Err())
+		assert.Equal(t, "int64.gte", requireSingleViolation(t, stream.Err()).GetConstraintId())
+		require.NoError(t, stream.Close())
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		_, err := validate.NewInterceptor(validate.WithProcedureConfig("[", validate.ProcedureConfig{}))
+		require.Error(t, err)
+		_, err = validate.NewInterceptor(
+			validate.WithValidator(staticValidator{}),
+			validate.WithProcedureConfig("/a/b", validate.ProcedureConfig{FailFast: true}),
+		)
+		require.Error(t, err)
+	})
+}
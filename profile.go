@@ -0,0 +1,105 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// MessageCost summarizes the measured cost of validating one message type.
+type MessageCost struct {
+	MessageType protoreflect.FullName
+	Samples     int64         // number of validations measured
+	Total       time.Duration // total time spent in measured validations
+	Max         time.Duration // slowest measured validation
+}
+
+// Mean returns the average time spent validating a message of this type.
+func (c MessageCost) Mean() time.Duration {
+	if c.Samples == 0 {
+		return 0
+	}
+	return c.Total / time.Duration(c.Samples)
+}
+
+// WithCostProfiling configures the [Interceptor] to measure how long it spends
+// validating each message type. The sampleRate is the fraction of validations
+// to measure, from 0 to 1; a small rate keeps the overhead negligible in
+// production. Use [Interceptor.ExpensiveMessages] to find the types whose
+// constraints are most worth optimizing or skipping.
+//
+// Costs are measured per message type, including any messages it contains.
+// The validator doesn't expose the cost of individual constraints.
+func WithCostProfiling(sampleRate float64) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.telemetry.profiler = &costProfiler{
+			sampleRate: sampleRate,
+			costs:      make(map[protoreflect.FullName]*MessageCost),
+		}
+	})
+}
+
+// ExpensiveMessages returns the n message types with the highest total
+// measured validation time, most expensive first. If n is negative, it
+// returns all measured types. Without [WithCostProfiling], it returns nil.
+func (i *Interceptor) ExpensiveMessages(n int) []MessageCost {
+	if i.telemetry.profiler == nil {
+		return nil
+	}
+	return i.telemetry.profiler.top(n)
+}
+
+type costProfiler struct {
+	sampleRate float64
+
+	mu    sync.Mutex
+	costs map[protoreflect.FullName]*MessageCost
+}
+
+func (p *costProfiler) record(name protoreflect.FullName, elapsed time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cost, ok := p.costs[name]
+	if !ok {
+		cost = &MessageCost{MessageType: name}
+		p.costs[name] = cost
+	}
+	cost.Samples++
+	cost.Total += elapsed
+	cost.Max = max(cost.Max, elapsed)
+}
+
+func (p *costProfiler) top(n int) []MessageCost {
+	p.mu.Lock()
+	costs := make([]MessageCost, 0, len(p.costs))
+	for _, cost := range p.costs {
+		costs = append(costs, *cost)
+	}
+	p.mu.Unlock()
+	sort.Slice(costs, func(i, j int) bool {
+		if costs[i].Total != costs[j].Total {
+			return costs[i].Total > costs[j].Total
+		}
+		return costs[i].MessageType < costs[j].MessageType
+	})
+	if n >= 0 && n < len(costs) {
+		costs = costs[:n]
+	}
+	return costs
+}
@@ -0,0 +1,61 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCostProfiling(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithCostProfiling(1))
+	require.NoError(t, err)
+	next := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+	user := &userv1.User{Email: "someone@example.com"}
+	for _, req := range []connect.AnyRequest{
+		connect.NewRequest(&userv1.CreateUserRequest{User: user}),
+		connect.NewRequest(&userv1.CreateUserRequest{User: user}),
+		connect.NewRequest(&userv1.UpdateUserRequest{User: user}),
+	} {
+		_, err := next(context.Background(), req)
+		require.NoError(t, err)
+	}
+
+	costs := interceptor.ExpensiveMessages(-1)
+	require.Len(t, costs, 2)
+	samples := make(map[string]int64)
+	for _, cost := range costs {
+		samples[string(cost.MessageType)] = cost.Samples
+		assert.LessOrEqual(t, cost.Mean(), cost.Max)
+	}
+	assert.Equal(t, map[string]int64{
+		"example.user.v1.CreateUserRequest": 2,
+		"example.user.v1.UpdateUserRequest": 1,
+	}, samples)
+	assert.Len(t, interceptor.ExpensiveMessages(1), 1)
+
+	unprofiled, err := validate.NewInterceptor()
+	require.NoError(t, err)
+	assert.Nil(t, unprofiled.ExpensiveMessages(-1))
+}
@@ -0,0 +1,74 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithRecover configures the [Interceptor] to recover from panics while
+// validating a message, like those raised by custom CEL functions on
+// malformed input or by the hooks configured with [WithMessageHook]. The
+// panic is converted to a [*PanicError], and the message is rejected with
+// [connect.CodeInternal] rather than crashing the handler's goroutine. Since
+// the validator couldn't decide whether the message is valid, [WithFailOpen]
+// and [WithValidatorErrorFunc] apply to it too, and procedures in [ModeWarn],
+// including all of them with [WithDryRun], let the message through and report
+// the panic to the function configured with [WithWarnFunc].
+//
+// Panics in the functions that report invalid messages, like those
+// configured with [WithMessageTranslator], [WithErrorTransformer], and
+// [WithOnFailure], aren't recovered: the message is known to be invalid by
+// then, so it mustn't fail open.
+//
+// Handlers built with [connect.WithRecover] already recover from panics
+// anywhere in the handler, including its interceptors. Recovering
+// interceptors are different: they only see panics from the interceptors
+// they wrap, so a panic during validation escapes them if the Interceptor
+// runs first. With WithRecover, panics during validation never propagate,
+// regardless of how the interceptors are ordered.
+func WithRecover() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.validators.recoverPanics = true
+	})
+}
+
+// A PanicError records a panic recovered while validating a message. See
+// [WithRecover]. The panic's value may carry internal state, so it's left out
+// of the error's message, which reaches clients: read Value in logs and
+// hooks, like the function configured with [WithValidatorErrorFunc].
+type PanicError struct {
+	Value any // the value passed to panic
+}
+
+func (e *PanicError) Error() string {
+	return "panic during validation"
+}
+
+// runValidatorRecovering is like runValidator, but returns panics as a
+// PanicError if the Interceptor recovers from them.
+func (i *Interceptor) runValidatorRecovering(ctx context.Context, spec connect.Spec, msg proto.Message) (err error) {
+	if i.validators.recoverPanics {
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r}
+			}
+		}()
+	}
+	return i.runValidator(ctx, spec, msg)
+}
@@ -0,0 +1,168 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWithRecover(t *testing.T) {
+	t.Parallel()
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	req := connect.NewRequest(&userv1.CreateUserRequest{User: &userv1.User{Email: "someone@example.com"}})
+	t.Run("recovered", func(t *testing.T) {
+		t.Parallel()
+		interceptor, err := validate.NewInterceptor(
+			validate.WithValidator(panickingValidator{}),
+			validate.WithRecover(),
+		)
+		require.NoError(t, err)
+		_, err = interceptor.WrapUnary(next)(context.Background(), req)
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+		var panicErr *validate.PanicError
+		require.ErrorAs(t, err, &panicErr)
+		assert.Equal(t, "bad CEL function", panicErr.Value)
+		var connectErr *connect.Error
+		require.ErrorAs(t, err, &connectErr)
+		assert.Equal(t, "panic during validation", connectErr.Message())
+	})
+	t.Run("not_recovered", func(t *testing.T) {
+		t.Parallel()
+		interceptor, err := validate.NewInterceptor(validate.WithValidator(panickingValidator{}))
+		require.NoError(t, err)
+		assert.PanicsWithValue(t, "bad CEL function", func() {
+			_, _ = interceptor.WrapUnary(next)(context.Background(), req)
+		})
+	})
+}
+
+// panickingValidator panics like a buggy custom CEL function.
+type panickingValidator struct{}
+
+func (panickingValidator) Validate(proto.Message) error {
+	panic("bad CEL function")
+}
+
+func TestWithRecoverOrdering(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(
+		validate.WithRecover(),
+		validate.WithValidator(panickingValidator{}),
+	)
+	require.NoError(t, err)
+	// This recovering interceptor only sees panics from the interceptors and
+	// handler it wraps, so it can't catch panics during validation.
+	recovering := connect.UnaryInterceptorFunc(func(next connect.UnaryFunc) connect.UnaryFunc {
+		return func(ctx context.Context, req connect.AnyRequest) (_ connect.AnyResponse, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = connect.NewError(connect.CodeUnknown, nil)
+				}
+			}()
+			return next(ctx, req)
+		}
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor, recovering),
+	))
+	srv := startHTTPServer(t, mux)
+
+	_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+		CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "foo"},
+		}))
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+	// The panic's value isn't revealed to the client.
+	assert.Contains(t, err.Error(), "panic during validation")
+	assert.NotContains(t, err.Error(), "bad CEL function")
+
+	errs := interceptor.Batch(context.Background(), []proto.Message{&userv1.User{Email: "foo"}})
+	require.Len(t, errs, 1)
+	assert.Equal(t, connect.CodeInternal, connect.CodeOf(errs[0]))
+}
+
+func TestWithRecoverHookPanics(t *testing.T) {
+	t.Parallel()
+	// The message is known to be invalid when onFailure panics, so the panic
+	// isn't turned into a validator failure that fails open.
+	interceptor, err := validate.NewInterceptor(
+		validate.WithRecover(),
+		validate.WithFailOpen("*"),
+		validate.WithOnFailure(func(context.Context, connect.Spec, proto.Message, *protovalidate.ValidationError) {
+			panic("bad hook")
+		}),
+	)
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+	assert.PanicsWithValue(t, "bad hook", func() {
+		_, _ = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "foo"},
+		}))
+	})
+}
+
+func TestWithRecoverWarnMode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		opt  validate.Option
+	}{
+		{name: "dry_run", opt: validate.WithDryRun()},
+		{name: "warn", opt: validate.WithPolicy(validate.Policy{}.Warn(userv1connect.UserServiceCreateUserProcedure))},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			warnings := make(chan error, 1)
+			interceptor, err := validate.NewInterceptor(
+				test.opt,
+				validate.WithRecover(),
+				validate.WithValidator(panickingValidator{}),
+				validate.WithWarnFunc(func(_ context.Context, _ connect.Spec, err error) {
+					warnings <- err
+				}),
+			)
+			require.NoError(t, err)
+			_, err = newUserClient(t, interceptor).CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+				User: &userv1.User{Email: "someone@example.com"},
+			}))
+			require.NoError(t, err)
+			require.Len(t, warnings, 1)
+			require.ErrorAs(t, <-warnings, new(*validate.PanicError))
+		})
+	}
+}
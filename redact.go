@@ -0,0 +1,141 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const redactedValue = "[REDACTED]"
+
+// redactViolations masks the values of fields marked with the debug_redact
+// option, or nested within such fields, in the messages of violations. Since
+// messages are free-form text, masking replaces whole occurrences of the
+// field's value. The violations' FieldValues are cleared, so hooks further
+// along can't leak them either. Violations are modified in place.
+func redactViolations(root protoreflect.MessageDescriptor, err *protovalidate.ValidationError) {
+	for _, violation := range err.Violations {
+		if !isRedacted(violation.FieldDescriptor) && !isRedactedPath(root, violation.Proto.GetField()) {
+			continue
+		}
+		if violation.Proto != nil {
+			violation.Proto.Message = proto.String(redactValue(violation.Proto.GetMessage(), violation.FieldValue))
+		}
+		violation.FieldValue = protoreflect.Value{}
+	}
+}
+
+func isRedactedPath(root protoreflect.MessageDescriptor, path *validatepb.FieldPath) bool {
+	desc := root
+	for _, element := range path.GetElements() {
+		if desc == nil {
+			return false
+		}
+		field := desc.Fields().ByNumber(protoreflect.FieldNumber(element.GetFieldNumber()))
+		if field == nil {
+			return false
+		}
+		if isRedacted(field) {
+			return true
+		}
+		desc = field.Message()
+		if field.IsMap() {
+			desc = field.MapValue().Message()
+		}
+	}
+	return false
+}
+
+func isRedacted(field protoreflect.FieldDescriptor) bool {
+	if field == nil {
+		return false
+	}
+	options, ok := field.Options().(*descriptorpb.FieldOptions)
+	return ok && options.GetDebugRedact()
+}
+
+// redactValue replaces a scalar value in a violation message. Only whole
+// occurrences are replaced, so that masking "e" doesn't mangle "reserved",
+// and masking 0 doesn't mangle "10". Strings and bytes are also replaced
+// where they're quoted, with any escapes, as %q renders them.
+func redactValue(message string, value protoreflect.Value) string {
+	if !value.IsValid() {
+		return message
+	}
+	switch v := value.Interface().(type) {
+	case protoreflect.Message, protoreflect.List, protoreflect.Map:
+		return message
+	case string:
+		return redactString(message, v)
+	case []byte:
+		return redactString(message, string(v))
+	case protoreflect.EnumNumber:
+		return replaceDelimited(message, strconv.Itoa(int(v)))
+	default:
+		return replaceDelimited(message, fmt.Sprint(v))
+	}
+}
+
+func redactString(message, value string) string {
+	if value == "" {
+		return message
+	}
+	message = replaceDelimited(message, strconv.Quote(value))
+	return replaceDelimited(message, value)
+}
+
+// replaceDelimited replaces the occurrences of value in message that aren't
+// part of a longer word: if value starts or ends with a letter, digit, or
+// underscore, the neighboring character on that side mustn't be one too.
+func replaceDelimited(message, value string) string {
+	first, _ := utf8.DecodeRuneInString(value)
+	last, _ := utf8.DecodeLastRuneInString(value)
+	var out strings.Builder
+	done := 0
+	for start := 0; start < len(message); {
+		n := strings.Index(message[start:], value)
+		if n < 0 {
+			break
+		}
+		n += start
+		end := n + len(value)
+		before, _ := utf8.DecodeLastRuneInString(message[:n])
+		after, _ := utf8.DecodeRuneInString(message[end:])
+		if (!isWordRune(first) || !isWordRune(before)) && (!isWordRune(last) || !isWordRune(after)) {
+			out.WriteString(message[done:n])
+			out.WriteString(redactedValue)
+			done, start = end, end
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(message[n:])
+		start = n + size
+	}
+	out.WriteString(message[done:])
+	return out.String()
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,95 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestDebugRedact(t *testing.T) {
+	t.Parallel()
+	warnings := make(chan error, 1)
+	interceptor, err := validate.NewInterceptor(
+		validate.WithPolicy(validate.Policy{}.Warn("*")),
+		validate.WithWarnFunc(func(_ context.Context, _ connect.Spec, err error) {
+			warnings <- err
+		}),
+	)
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "someone@example.com", Handle: "admin-bob"},
+	}))
+	require.NoError(t, err)
+
+	require.Len(t, warnings, 1)
+	err = <-warnings
+	assert.NotContains(t, err.Error(), "admin-bob")
+	violation := requireSingleViolation(t, err)
+	assert.Equal(t, "user.handle", violation.GetConstraintId())
+	assert.Equal(t, "handle [REDACTED] is reserved", violation.GetMessage())
+	var validationErr *protovalidate.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.False(t, validationErr.Violations[0].FieldValue.IsValid())
+}
+
+func TestDebugRedactWholeValues(t *testing.T) {
+	t.Parallel()
+	handle := (&userv1.User{}).ProtoReflect().Descriptor().Fields().ByName("handle")
+	tests := []struct {
+		value   string
+		message string
+		want    string
+	}{
+		{value: "e", message: "handle e is reserved", want: "handle [REDACTED] is reserved"},
+		{value: "bob", message: "bobby isn't bob", want: "bobby isn't [REDACTED]"},
+		{value: `a"b`, message: `handle "a\"b" is reserved`, want: "handle [REDACTED] is reserved"},
+		{value: "-x-", message: "handle a-x-b is reserved", want: "handle a[REDACTED]b is reserved"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.value, func(t *testing.T) {
+			t.Parallel()
+			violation := newViolation("user.handle",
+				&validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("user")},
+				&validatepb.FieldPathElement{FieldNumber: proto.Int32(4), FieldName: proto.String("handle")},
+			)
+			violation.Proto.Message = proto.String(test.message)
+			violation.FieldDescriptor = handle
+			violation.FieldValue = protoreflect.ValueOfString(test.value)
+			interceptor, err := validate.NewInterceptor(validate.WithValidator(staticValidator{
+				err: &protovalidate.ValidationError{Violations: []*validate.Violation{violation}},
+			}))
+			require.NoError(t, err)
+			_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+			})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+			assert.Equal(t, test.want, requireSingleViolation(t, err).GetMessage())
+		})
+	}
+}
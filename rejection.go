@@ -0,0 +1,81 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// RejectionIDHeader is the error metadata key that carries rejection IDs. See
+// [WithRejectionIDs].
+const RejectionIDHeader = "Validate-Rejection-Id"
+
+type rejectionIDKey struct{}
+
+// WithRejectionIDs configures the [Interceptor] to assign a random UUID to
+// each invalid message. The ID is returned to the client in the
+// [RejectionIDHeader] error metadata, and it's available from the context
+// passed to hooks like [WithErrorTransformer] and [WithWarnFunc] via
+// [RejectionID]. Invalid request logs include it too. When a user reports
+// that a request was rejected, the ID matches their report to server-side
+// records.
+func WithRejectionIDs() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.rejectionIDs = true
+	})
+}
+
+// WithRejectionIDGenerator configures the [Interceptor] to generate the IDs
+// assigned with [WithRejectionIDs] with the given function, instead of as
+// random UUIDs. Applications can use it to match their existing ID schemes,
+// like ULIDs or Snowflake IDs, and tests can use it to produce deterministic
+// IDs. The function may be called concurrently.
+func WithRejectionIDGenerator(generate func() string) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.rejectionIDGenerator = generate
+	})
+}
+
+// RejectionID returns the rejection ID assigned to an invalid message, if
+// any. It's only set in the contexts passed to the Interceptor's hooks.
+func RejectionID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(rejectionIDKey{}).(string)
+	return id, ok
+}
+
+func withRejectionID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, rejectionIDKey{}, id)
+}
+
+// newRejectionID returns a new rejection ID.
+func (i *Interceptor) newRejectionID() string {
+	if i.reporting.rejectionIDGenerator != nil {
+		return i.reporting.rejectionIDGenerator()
+	}
+	return newUUID()
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var uuid [16]byte
+	// crypto/rand.Read only fails if the operating system's random number
+	// generator is broken, in which case an all-zero ID is still usable.
+	_, _ = rand.Read(uuid[:])
+	uuid[6] = (uuid[6] & 0x0f) | 0x40 // version 4
+	uuid[8] = (uuid[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16])
+}
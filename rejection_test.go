@@ -0,0 +1,96 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sync/atomic"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRejectionIDs(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	var transformerID string
+	interceptor, err := validate.NewInterceptor(
+		validate.WithRejectionIDs(),
+		validate.WithInvalidRequestLogger(slog.New(slog.NewTextHandler(&buf, nil)), 1),
+		validate.WithErrorTransformer(func(ctx context.Context, _ connect.Spec, _ *protovalidate.ValidationError) *connect.Error {
+			transformerID, _ = validate.RejectionID(ctx)
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "someone@example.com"},
+	}))
+	require.NoError(t, err)
+	assert.Empty(t, transformerID)
+
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	}))
+	require.Error(t, err)
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	id := connectErr.Meta().Get(validate.RejectionIDHeader)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+	assert.Equal(t, id, transformerID)
+	assert.Contains(t, buf.String(), "rejection_id="+id)
+	requireSingleViolation(t, err)
+
+	// Each rejection gets a new ID.
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	}))
+	require.ErrorAs(t, err, &connectErr)
+	assert.NotEqual(t, id, connectErr.Meta().Get(validate.RejectionIDHeader))
+}
+
+func TestWithRejectionIDGenerator(t *testing.T) {
+	t.Parallel()
+	var next atomic.Int64
+	interceptor, err := validate.NewInterceptor(
+		validate.WithRejectionIDs(),
+		validate.WithRejectionIDGenerator(func() string {
+			return fmt.Sprintf("rejection-%d", next.Add(1))
+		}),
+	)
+	require.NoError(t, err)
+	client := newUserClient(t, interceptor)
+	for _, want := range []string{"rejection-1", "rejection-2"} {
+		_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "foo"},
+		}))
+		var connectErr *connect.Error
+		require.ErrorAs(t, err, &connectErr)
+		assert.Equal(t, want, connectErr.Meta().Get(validate.RejectionIDHeader))
+	}
+}
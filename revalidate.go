@@ -0,0 +1,121 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"net/http"
+
+	"connectrpc.com/connect"
+)
+
+type mutatedKey struct{}
+
+// MarkMutated returns a copy of ctx that records that an interceptor changed
+// the request after it was validated. Interceptors that rewrite requests call
+// it before passing the request on, so that the [Interceptor.Revalidator]
+// placed after them checks the request again.
+func MarkMutated(ctx context.Context) context.Context {
+	return context.WithValue(ctx, mutatedKey{}, true)
+}
+
+func mutated(ctx context.Context) bool {
+	ok, _ := ctx.Value(mutatedKey{}).(bool)
+	return ok
+}
+
+// Revalidator returns a handler interceptor that validates requests again,
+// with the Interceptor's configuration, if an interceptor between the two
+// marked the request with [MarkMutated]. It closes the gap between validation
+// and the handler when other interceptors in the chain rewrite requests.
+// Apply it last, so that it runs immediately before the handler:
+//
+//	connect.WithInterceptors(interceptor, rewriter, interceptor.Revalidator())
+//
+// Unmarked requests pass through without being validated again, and so do
+// requests that the Interceptor doesn't validate, because of
+// [WithDirection], [WithHeaderBypass], or its [Policy]. Revalidation only
+// decides whether the mutated request is still valid: the Interceptor's
+// hooks, observers, rollups, and logs already saw the request, so they aren't
+// called again, and it takes no concurrency slots. Clients are unaffected.
+func (i *Interceptor) Revalidator() connect.Interceptor {
+	return &revalidator{interceptor: i}
+}
+
+type revalidator struct {
+	interceptor *Interceptor
+}
+
+// WrapUnary implements connect.Interceptor.
+func (r *revalidator) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		spec := req.Spec()
+		if r.applies(ctx, spec, req.Header(), req.Peer()) {
+			if err := r.interceptor.revalidate(ctx, spec, req.Any()); err != nil {
+				return nil, err
+			}
+		}
+		return next(ctx, req)
+	}
+}
+
+// applies reports whether the revalidator checks the requests of an RPC.
+func (r *revalidator) applies(ctx context.Context, spec connect.Spec, header http.Header, peer connect.Peer) bool {
+	i := r.interceptor
+	if spec.IsClient || !mutated(ctx) || i.nop || !i.direction.requests() || i.procedureConfig(spec.Procedure).SkipRequests {
+		return false
+	}
+	return i.scope.headerBypass == nil || !i.scope.headerBypass.bypassed(spec, header, peer)
+}
+
+// revalidate checks a request again after an interceptor mutated it. Unlike
+// validate, it has no side effects beyond those of check, and procedures in
+// ModeWarn let the request through silently, since the warning for it was
+// reported when it was first validated.
+func (i *Interceptor) revalidate(ctx context.Context, spec connect.Spec, msg any) error {
+	mode := i.mode(ctx, spec)
+	if mode == ModeSkip || i.skipsType(msg) {
+		return nil
+	}
+	if _, _, err := i.check(ctx, spec, msg, nil); err != nil && mode != ModeWarn {
+		return err
+	}
+	return nil
+}
+
+// WrapStreamingClient implements connect.Interceptor.
+func (r *revalidator) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler implements connect.Interceptor.
+func (r *revalidator) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		spec := conn.Spec()
+		if !r.applies(ctx, spec, conn.RequestHeader(), conn.Peer()) {
+			return next(ctx, conn)
+		}
+		wrapped := &streamingHandlerInterceptor{
+			StreamingHandlerConn: conn,
+			validate: func(msg any) error {
+				return r.interceptor.revalidate(ctx, spec, msg)
+			},
+		}
+		if err := next(ctx, wrapped); err != nil {
+			return err
+		}
+		return wrapped.err
+	}
+}
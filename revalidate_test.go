@@ -0,0 +1,125 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestRevalidator(t *testing.T) {
+	t.Parallel()
+	// The rewriter stands in for an interceptor that normalizes requests, but
+	// breaks them along the way.
+	rewriter := func(mark bool) connect.UnaryInterceptorFunc {
+		return func(next connect.UnaryFunc) connect.UnaryFunc {
+			return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+				if msg, ok := req.Any().(*userv1.CreateUserRequest); ok {
+					msg.User.Email = "not an email"
+					if mark {
+						ctx = validate.MarkMutated(ctx)
+					}
+				}
+				return next(ctx, req)
+			}
+		}
+	}
+	// The handler reports the email it received in a header, so that its
+	// response is valid even with response validation.
+	handler := func(_ context.Context, req *connect.Request[userv1.CreateUserRequest]) (*connect.Response[userv1.CreateUserResponse], error) {
+		res := connect.NewResponse(&userv1.CreateUserResponse{})
+		res.Header().Set("Email", req.Msg.GetUser().GetEmail())
+		return res, nil
+	}
+	allow := func(http.Header, connect.Peer) bool { return true }
+	tests := []struct {
+		name    string
+		mark    bool
+		opts    []validate.Option
+		header  string // value of X-Validate
+		wantErr bool
+	}{
+		{name: "marked", mark: true, wantErr: true},
+		{name: "unmarked"},
+		{name: "warn", mark: true, opts: []validate.Option{validate.WithDryRun()}},
+		{
+			name: "responses_only",
+			mark: true,
+			opts: []validate.Option{validate.WithDirection(validate.DirectionResponses)},
+		},
+		{
+			name:   "header_bypass",
+			mark:   true,
+			opts:   []validate.Option{validate.WithHeaderBypass("X-Validate", "off", allow)},
+			header: "off",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var failures, observed atomic.Int32
+			opts := append([]validate.Option{
+				validate.WithOnFailure(func(context.Context, connect.Spec, proto.Message, *protovalidate.ValidationError) {
+					failures.Add(1)
+				}),
+				validate.WithObserver(func(context.Context, connect.Spec, string, bool, time.Duration) {
+					observed.Add(1)
+				}),
+			}, test.opts...)
+			interceptor, err := validate.NewInterceptor(opts...)
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+				userv1connect.UserServiceCreateUserProcedure,
+				handler,
+				connect.WithInterceptors(interceptor, rewriter(test.mark), interceptor.Revalidator()),
+			))
+			srv := startHTTPServer(t, mux)
+
+			req := connect.NewRequest(&userv1.CreateUserRequest{
+				User: &userv1.User{Email: "someone@example.com"},
+			})
+			if test.header != "" {
+				req.Header().Set("X-Validate", test.header)
+			}
+			res, err := userv1connect.NewUserServiceClient(srv.Client(), srv.URL).CreateUser(context.Background(), req)
+			// Revalidation has no side effects: the first validation was
+			// observed, if it ran, and nothing reported a failure.
+			assert.Zero(t, failures.Load())
+			assert.LessOrEqual(t, observed.Load(), int32(1))
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, "string.email", requireSingleViolation(t, err).GetConstraintId())
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "not an email", res.Header().Get("Email"))
+			}
+		})
+	}
+}
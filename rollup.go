@@ -0,0 +1,228 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bufbuild/protovalidate-go"
+)
+
+// A Rollup aggregates the outcomes of validation over one window of time.
+// See [WithRollups].
+type Rollup struct {
+	Start      time.Time
+	End        time.Time
+	Procedures []ProcedureRollup // sorted by procedure
+}
+
+// ProcedureRollup aggregates the outcomes of validating one procedure's
+// messages.
+type ProcedureRollup struct {
+	Procedure      string
+	Total          int               // messages validated
+	Rejected       int               // messages rejected
+	Warned         int               // invalid messages let through in ModeWarn
+	TopConstraints []ConstraintCount // most violated first
+}
+
+// ConstraintCount is the number of violations of one constraint.
+type ConstraintCount struct {
+	ConstraintID string
+	Count        int
+}
+
+// WithRollups configures the [Interceptor] to aggregate the outcomes of
+// validation over windows of the given length, such as a minute or an hour,
+// and to pass each window's [Rollup] to report. For each procedure, a rollup
+// counts the messages validated, rejected, and let through in [ModeWarn], and
+// lists the most violated constraints, up to top of them; if top is negative,
+// it lists them all. Constraints are counted before errors are truncated by
+// [WithMaxViolations] or [WithViolationSummary]. It's meant for lightweight periodic reporting without a
+// metrics backend. Non-positive windows disable rollups.
+//
+// Windows are aligned to multiples of their length since the zero time, so
+// hourly windows start on the hour. Windows without any validations aren't
+// reported. A window is reported shortly after it ends, or with
+// [Interceptor.FlushRollups]. The report function is called from whichever
+// goroutine closes the window, so it should return quickly.
+func WithRollups(window time.Duration, top int, report func(Rollup)) Option {
+	return optionFunc(func(i *Interceptor) {
+		if window <= 0 {
+			i.telemetry.rollups = nil
+			return
+		}
+		i.telemetry.rollups = &rollupReporter{
+			window: window,
+			top:    top,
+			report: report,
+		}
+	})
+}
+
+// FlushRollups reports the current window immediately, even though it hasn't
+// ended, for example when the process is shutting down. Without
+// [WithRollups], or if the window has no validations, it does nothing.
+func (i *Interceptor) FlushRollups() {
+	if i.telemetry.rollups == nil {
+		return
+	}
+	i.telemetry.rollups.flush(time.Time{}, true)
+}
+
+type rollupReporter struct {
+	window time.Duration
+	top    int
+	report func(Rollup)
+	now    func() time.Time
+
+	mu         sync.Mutex
+	start      time.Time
+	procedures map[string]*procedureCounts // nil between windows
+}
+
+type procedureCounts struct {
+	total, rejected, warned int
+	constraints             map[string]int
+}
+
+// rolledUpViolationsKey is the context key for the violations of an invalid
+// message before they're truncated for reporting, so rollups count every
+// violation even if the error reports only some of them.
+type rolledUpViolationsKey struct{}
+
+func withRolledUpViolations(ctx context.Context, validationErr *protovalidate.ValidationError) context.Context {
+	return context.WithValue(ctx, rolledUpViolationsKey{}, validationErr)
+}
+
+func rolledUpViolations(ctx context.Context) *protovalidate.ValidationError {
+	validationErr, _ := ctx.Value(rolledUpViolationsKey{}).(*protovalidate.ValidationError)
+	return validationErr
+}
+
+// record counts the outcome of validating a message: err is the error the
+// Interceptor returned or would have returned, violations are all of the
+// message's violations, if any, and warned reports whether the procedure is
+// in ModeWarn.
+func (r *rollupReporter) record(procedure string, err error, violations *protovalidate.ValidationError, warned bool) {
+	now := r.now()
+	start := now.Truncate(r.window)
+	var done *Rollup
+	r.mu.Lock()
+	if r.procedures != nil && !r.start.Equal(start) {
+		rollup := r.takeLocked()
+		done = &rollup
+	}
+	if r.procedures == nil {
+		r.start = start
+		r.procedures = make(map[string]*procedureCounts)
+		time.AfterFunc(start.Add(r.window).Sub(now), func() {
+			r.flush(start, false)
+		})
+	}
+	counts, ok := r.procedures[procedure]
+	if !ok {
+		counts = &procedureCounts{constraints: make(map[string]int)}
+		r.procedures[procedure] = counts
+	}
+	counts.total++
+	switch {
+	case err == nil:
+	case warned:
+		counts.warned++
+	default:
+		counts.rejected++
+	}
+	if violations != nil {
+		for _, violation := range violations.Violations {
+			if id := violation.Proto.GetConstraintId(); id != "" {
+				counts.constraints[id]++
+			}
+		}
+	}
+	r.mu.Unlock()
+	if done != nil {
+		r.report(*done)
+	}
+}
+
+// flush reports the window that started at start, unless it was already
+// reported. If force is set, it reports the current window instead.
+func (r *rollupReporter) flush(start time.Time, force bool) {
+	r.mu.Lock()
+	if r.procedures == nil || (!force && !r.start.Equal(start)) {
+		r.mu.Unlock()
+		return
+	}
+	rollup := r.takeLocked()
+	r.mu.Unlock()
+	r.report(rollup)
+}
+
+// current returns the rollup of the current window, without ending it. It
+// returns nil if the window has no validations.
+func (r *rollupReporter) current() *Rollup {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.procedures == nil {
+		return nil
+	}
+	rollup := r.snapshotLocked()
+	return &rollup
+}
+
+// takeLocked ends the current window and returns its rollup.
+func (r *rollupReporter) takeLocked() Rollup {
+	rollup := r.snapshotLocked()
+	r.procedures = nil
+	return rollup
+}
+
+func (r *rollupReporter) snapshotLocked() Rollup {
+	rollup := Rollup{
+		Start:      r.start,
+		End:        r.start.Add(r.window),
+		Procedures: make([]ProcedureRollup, 0, len(r.procedures)),
+	}
+	for procedure, counts := range r.procedures {
+		constraints := make([]ConstraintCount, 0, len(counts.constraints))
+		for id, count := range counts.constraints {
+			constraints = append(constraints, ConstraintCount{ConstraintID: id, Count: count})
+		}
+		sort.Slice(constraints, func(i, j int) bool {
+			if constraints[i].Count != constraints[j].Count {
+				return constraints[i].Count > constraints[j].Count
+			}
+			return constraints[i].ConstraintID < constraints[j].ConstraintID
+		})
+		if r.top >= 0 && r.top < len(constraints) {
+			constraints = constraints[:r.top]
+		}
+		rollup.Procedures = append(rollup.Procedures, ProcedureRollup{
+			Procedure:      procedure,
+			Total:          counts.total,
+			Rejected:       counts.rejected,
+			Warned:         counts.warned,
+			TopConstraints: constraints,
+		})
+	}
+	sort.Slice(rollup.Procedures, func(i, j int) bool {
+		return rollup.Procedures[i].Procedure < rollup.Procedures[j].Procedure
+	})
+	return rollup
+}
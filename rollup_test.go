@@ -0,0 +1,146 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRollups(t *testing.T) {
+	t.Parallel()
+	t.Run("flush", func(t *testing.T) {
+		t.Parallel()
+		rollups := make(chan validate.Rollup, 2)
+		interceptor, err := validate.NewInterceptor(
+			validate.WithPolicy(validate.Policy{}.Warn(userv1connect.UserServiceUpdateUserProcedure)),
+			validate.WithRollups(time.Hour, 1, func(rollup validate.Rollup) {
+				rollups <- rollup
+			}),
+		)
+		require.NoError(t, err)
+		client := newUserClient(t, interceptor)
+
+		for _, user := range []*userv1.User{
+			{Email: "someone@example.com"},
+			{Email: "foo", Handle: "admin1"},
+			{Email: "bar"},
+		} {
+			_, _ = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: user}))
+		}
+		_, err = client.UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{
+			User: &userv1.User{Email: "foo"},
+		}))
+		require.NoError(t, err)
+		assert.Empty(t, rollups)
+
+		interceptor.FlushRollups()
+		require.Len(t, rollups, 1)
+		rollup := <-rollups
+		assert.Equal(t, time.Hour, rollup.End.Sub(rollup.Start))
+		assert.Equal(t, []validate.ProcedureRollup{
+			{
+				Procedure:      userv1connect.UserServiceCreateUserProcedure,
+				Total:          3,
+				Rejected:       2,
+				TopConstraints: []validate.ConstraintCount{{ConstraintID: "string.email", Count: 2}},
+			},
+			{
+				Procedure:      userv1connect.UserServiceUpdateUserProcedure,
+				Total:          1,
+				Warned:         1,
+				TopConstraints: []validate.ConstraintCount{{ConstraintID: "string.email", Count: 1}},
+			},
+		}, rollup.Procedures)
+
+		// The window was reported, so there's nothing left to flush.
+		interceptor.FlushRollups()
+		assert.Empty(t, rollups)
+	})
+	t.Run("window_end", func(t *testing.T) {
+		t.Parallel()
+		rollups := make(chan validate.Rollup, 2)
+		interceptor, err := validate.NewInterceptor(
+			validate.WithRollups(10*time.Millisecond, -1, func(rollup validate.Rollup) {
+				rollups <- rollup
+			}),
+		)
+		require.NoError(t, err)
+		client := newUserClient(t, interceptor)
+
+		_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "foo"},
+		}))
+		require.Error(t, err)
+		select {
+		case rollup := <-rollups:
+			require.Len(t, rollup.Procedures, 1)
+			assert.Equal(t, 1, rollup.Procedures[0].Rejected)
+		case <-time.After(time.Second):
+			t.Fatal("window wasn't reported after it ended")
+		}
+	})
+	t.Run("truncated", func(t *testing.T) {
+		t.Parallel()
+		rollups := make(chan validate.Rollup, 1)
+		interceptor, err := validate.NewInterceptor(
+			validate.WithMaxViolations(1),
+			validate.WithRollups(time.Hour, -1, func(rollup validate.Rollup) {
+				rollups <- rollup
+			}),
+		)
+		require.NoError(t, err)
+		_, err = newUserClient(t, interceptor).CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "foo", Handle: "admin1"},
+		}))
+		requireSingleViolation(t, err)
+
+		// Rollups count the violations that the error leaves out.
+		interceptor.FlushRollups()
+		require.Len(t, rollups, 1)
+		rollup := <-rollups
+		require.Len(t, rollup.Procedures, 1)
+		assert.Equal(t, []validate.ConstraintCount{
+			{ConstraintID: "string.email", Count: 1},
+			{ConstraintID: "user.handle", Count: 1},
+		}, rollup.Procedures[0].TopConstraints)
+	})
+}
+
+func newUserClient(tb testing.TB, interceptor *validate.Interceptor) userv1connect.UserServiceClient {
+	tb.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	mux.Handle(userv1connect.UserServiceUpdateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceUpdateUserProcedure,
+		updateUser,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(tb, mux)
+	return userv1connect.NewUserServiceClient(srv.Client(), srv.URL)
+}
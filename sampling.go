@@ -0,0 +1,59 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"hash/fnv"
+
+	"connectrpc.com/connect"
+)
+
+// WithSamplingKey configures the [Interceptor] to make its sampling decisions
+// by key rather than at random: [WithSampleRate], [WithInvalidRequestLogger],
+// and [WithCostProfiling] sample a message if the hash of its key falls
+// within their rate. Messages with the same key get the same decision, so
+// keying by, say, the caller's user ID validates or logs all of a user's
+// requests or none of them, and decisions align with business semantics
+// rather than with individual messages. A key sampled at one rate is also
+// sampled at every higher rate.
+//
+// The function is called with the context and [connect.Spec] of the RPC and
+// the message. If it returns an empty key, the message is sampled at random.
+func WithSamplingKey(key func(ctx context.Context, spec connect.Spec, msg any) string) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.scope.samplingKey = key
+	})
+}
+
+// sample reports whether to sample msg at the given rate.
+func (i *Interceptor) sample(ctx context.Context, spec connect.Spec, msg any, rate float64) bool {
+	if i.scope.samplingKey == nil || rate <= 0 || rate >= 1 {
+		return sampled(rate)
+	}
+	key := i.scope.samplingKey(ctx, spec, msg)
+	if key == "" {
+		return sampled(rate)
+	}
+	return keySampled(key, rate)
+}
+
+// keySampled maps key to a point in [0, 1) and reports whether it's below
+// rate.
+func keySampled(key string, rate float64) bool {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(key))
+	return float64(hash.Sum64()>>11)/(1<<53) < rate
+}
@@ -0,0 +1,108 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"fmt"
+
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+)
+
+// A Severity chooses how the [Interceptor] treats violations of a constraint.
+// See [WithRuleSeverity].
+type Severity int
+
+const (
+	// SeverityError rejects messages that violate the constraint. It's the
+	// default.
+	SeverityError Severity = iota
+	// SeverityWarn lets messages that violate the constraint through, and
+	// reports the violations as warnings, as if the procedure were in
+	// [ModeWarn].
+	SeverityWarn
+	// SeverityIgnore drops violations of the constraint.
+	SeverityIgnore
+)
+
+// String implements [fmt.Stringer].
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarn:
+		return "warn"
+	case SeverityIgnore:
+		return "ignore"
+	}
+	return fmt.Sprintf("severity_%d", int(s))
+}
+
+// WithRuleSeverity configures the [Interceptor] to treat violations of the
+// constraints with the given IDs, like "string.email", with the given
+// severities, while violations of other constraints are errors. It's meant
+// for adopting stricter schemas incrementally: new constraints can start as
+// warnings, and be promoted to errors once clients comply.
+//
+// Warnings go to the function configured with [WithWarnFunc], to
+// [WithWarningConverter] and [WithWarningTrailers], and to the logger
+// configured with [WithInvalidRequestLogger]. If a message also has errors,
+// it's rejected with only the errors. Calling WithRuleSeverity more than once
+// merges the severities.
+func WithRuleSeverity(severities map[string]Severity) Option {
+	return optionFunc(func(i *Interceptor) {
+		if i.violations.severities == nil {
+			i.violations.severities = make(map[string]Severity, len(severities))
+		}
+		for id, severity := range severities {
+			i.violations.severities[id] = severity
+		}
+	})
+}
+
+// splitSeverities splits the violations of err into errors and warnings,
+// dropping ignored violations. Either result is nil if it has no violations.
+func splitSeverities(err *protovalidate.ValidationError, severities map[string]Severity) (*protovalidate.ValidationError, *protovalidate.ValidationError) {
+	var errs, warnings []*Violation
+	for _, violation := range err.Violations {
+		switch severities[violation.Proto.GetConstraintId()] {
+		case SeverityError:
+			errs = append(errs, violation)
+		case SeverityWarn:
+			warnings = append(warnings, violation)
+		case SeverityIgnore:
+		}
+	}
+	var errorsErr, warningsErr *protovalidate.ValidationError
+	if len(errs) > 0 {
+		errorsErr = &protovalidate.ValidationError{Violations: errs}
+	}
+	if len(warnings) > 0 {
+		warningsErr = &protovalidate.ValidationError{Violations: warnings}
+	}
+	return errorsErr, warningsErr
+}
+
+// warnSeverity reports the violations of constraints with SeverityWarn. They
+// don't reject the message, so they aren't assigned a rejection ID.
+func (i *Interceptor) warnSeverity(ctx context.Context, spec connect.Spec, msg proto.Message, warnings *protovalidate.ValidationError) {
+	ctx, warnings, err := i.reportViolations(ctx, spec, msg, warnings, false)
+	if i.telemetry.requestLogger != nil && i.sample(ctx, spec, msg, i.telemetry.requestLogger.sampleRate) {
+		i.telemetry.requestLogger.log(ctx, spec, msg, warnings)
+	}
+	i.warn(ctx, spec, warnings, err)
+}
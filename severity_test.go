@@ -0,0 +1,85 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestWithRuleSeverity(t *testing.T) {
+	t.Parallel()
+	var warnings []error
+	var ids int
+	interceptor, err := validate.NewInterceptor(
+		validate.WithRejectionIDs(),
+		validate.WithRejectionIDGenerator(func() string {
+			ids++
+			return "rejection-" + strconv.Itoa(ids)
+		}),
+		validate.WithRuleSeverity(map[string]validate.Severity{"string.email": validate.SeverityWarn}),
+		validate.WithRuleSeverity(map[string]validate.Severity{"user.handle": validate.SeverityIgnore}),
+		validate.WithWarnFunc(func(_ context.Context, _ connect.Spec, err error) {
+			warnings = append(warnings, err)
+		}),
+	)
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+
+	// Warnings and ignored violations alone don't reject the message.
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo", Handle: "admin"},
+	}))
+	require.NoError(t, err)
+	require.Len(t, warnings, 1)
+	violation := requireSingleViolation(t, warnings[0])
+	assert.Equal(t, "string.email", violation.GetConstraintId())
+	assert.Zero(t, ids, "warnings don't reject the message")
+
+	// Errors still reject the message, and are reported without the warnings.
+	warnings = nil
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{
+			Email:     "foo",
+			BirthDate: timestamppb.New(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+		},
+	}))
+	require.Error(t, err)
+	violation = requireSingleViolation(t, err)
+	assert.Equal(t, "user.signup_date", violation.GetConstraintId())
+	require.Len(t, warnings, 1)
+	violation = requireSingleViolation(t, warnings[0])
+	assert.Equal(t, "string.email", violation.GetConstraintId())
+	// Only the rejection is assigned an ID.
+	assert.Equal(t, 1, ids)
+	var connectErr *connect.Error
+	require.ErrorAs(t, err, &connectErr)
+	assert.Equal(t, "rejection-1", connectErr.Meta().Get(validate.RejectionIDHeader))
+	require.ErrorAs(t, warnings[0], &connectErr)
+	assert.Empty(t, connectErr.Meta().Get(validate.RejectionIDHeader))
+
+	assert.Equal(t, map[string]string{"string.email": "warn", "user.handle": "ignore"}, interceptor.EffectiveConfig().Violations.RuleSeverities)
+}
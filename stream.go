@@ -0,0 +1,253 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const streamUniqueID = "stream.unique"
+
+// WithUniqueStreamField configures the [Interceptor] to reject streams in
+// which two messages of the given type have the same value in a field. The
+// path names a singular scalar field with dot-separated field names, as in
+// "item.sku". Repeated values are reported as violations of the
+// "stream.unique" constraint, with messages naming the indexes of both
+// messages in the stream. Indexes count every message received, and values
+// are remembered even for messages that aren't validated, like those left
+// out by [WithSampleRate], so later messages can't repeat them. Messages in
+// which the path isn't set aren't checked. Unary RPCs aren't affected.
+//
+// This is a common requirement for batch uploads that protovalidate can't
+// express, since its constraints apply to one message at a time. The
+// Interceptor remembers every value seen on a stream, so memory use grows
+// with the length of the stream.
+func WithUniqueStreamField(msg protoreflect.MessageDescriptor, path string) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.streams.uniqueFields = append(i.streams.uniqueFields, &uniqueField{msg: msg, path: path})
+	})
+}
+
+type uniqueField struct {
+	msg    protoreflect.MessageDescriptor
+	path   string
+	fields []protoreflect.FieldDescriptor // resolved from path
+}
+
+// resolve checks the field's path and caches its descriptors.
+func (u *uniqueField) resolve() error {
+	desc := u.msg
+	for _, name := range strings.Split(u.path, ".") {
+		if desc == nil {
+			return fmt.Errorf("unique stream field %q of %s: %q isn't a message field", u.path, u.msg.FullName(), u.fields[len(u.fields)-1].Name())
+		}
+		field := desc.Fields().ByName(protoreflect.Name(name))
+		if field == nil {
+			return fmt.Errorf("unique stream field %q of %s: no field %q in %s", u.path, u.msg.FullName(), name, desc.FullName())
+		}
+		if field.IsList() || field.IsMap() {
+			return fmt.Errorf("unique stream field %q of %s: %q isn't singular", u.path, u.msg.FullName(), name)
+		}
+		u.fields = append(u.fields, field)
+		desc = field.Message()
+	}
+	if desc != nil {
+		return fmt.Errorf("unique stream field %q of %s: must name a scalar field", u.path, u.msg.FullName())
+	}
+	return nil
+}
+
+// value returns the field's value in msg, if it's set.
+func (u *uniqueField) value(msg protoreflect.Message) (protoreflect.Value, bool) {
+	for _, field := range u.fields[:len(u.fields)-1] {
+		if !msg.Has(field) {
+			return protoreflect.Value{}, false
+		}
+		msg = msg.Get(field).Message()
+	}
+	field := u.fields[len(u.fields)-1]
+	if field.HasPresence() && !msg.Has(field) {
+		return protoreflect.Value{}, false
+	}
+	return msg.Get(field), true
+}
+
+// streamState tracks the messages on one stream.
+type streamState struct {
+	uniqueFields []*uniqueField
+	invariants   []*streamInvariant
+
+	received   int                        // number of messages received, validated or not
+	seen       []map[any]int              // for each unique field, the first index with each value
+	duplicates []*protovalidate.Violation // for the last message received
+	aggregates []invariantState
+}
+
+func (i *Interceptor) newStreamState() *streamState {
+	if len(i.streams.uniqueFields) == 0 && len(i.streams.invariants) == 0 {
+		return nil
+	}
+	seen := make([]map[any]int, len(i.streams.uniqueFields))
+	for index := range seen {
+		seen[index] = make(map[any]int)
+	}
+	return &streamState{
+		uniqueFields: i.streams.uniqueFields,
+		invariants:   i.streams.invariants,
+		seen:         seen,
+		aggregates:   make([]invariantState, len(i.streams.invariants)),
+	}
+}
+
+// receive records the next message on the stream. Every message counts,
+// including those that aren't validated because of sampling or their type, so
+// that indexes match the messages' positions on the stream and values of
+// unvalidated messages are still remembered. Violations for repeated values
+// are kept until the message is validated.
+func (s *streamState) receive(msg any) {
+	if s == nil {
+		return
+	}
+	index := s.received
+	s.received++
+	s.duplicates = nil
+	protoMsg, ok := msg.(proto.Message)
+	if !ok {
+		return
+	}
+	refl := protoMsg.ProtoReflect()
+	name := refl.Descriptor().FullName()
+	for n, unique := range s.uniqueFields {
+		if name != unique.msg.FullName() {
+			continue
+		}
+		value, ok := unique.value(refl)
+		if !ok {
+			continue
+		}
+		key := value.Interface()
+		if bytes, ok := key.([]byte); ok {
+			key = string(bytes)
+		}
+		first, ok := s.seen[n][key]
+		if !ok {
+			s.seen[n][key] = index
+			continue
+		}
+		s.duplicates = append(s.duplicates, newDuplicateViolation(unique, value, index, first))
+	}
+}
+
+// appendViolations runs the cross-message checks for the last message
+// received on the stream and merges any violations into err. Errors other
+// than a *protovalidate.ValidationError are returned unchanged.
+func (s *streamState) appendViolations(err error, msg proto.Message) error {
+	var validationErr *protovalidate.ValidationError
+	if err != nil && !errors.As(err, &validationErr) {
+		return err
+	}
+	index := s.received - 1
+	name := msg.ProtoReflect().Descriptor().FullName()
+	violations := s.duplicates
+	s.duplicates = nil
+	for n, invariant := range s.invariants {
+		if name != invariant.msg.FullName() {
+			continue
+		}
+		violation, err := invariant.check(&s.aggregates[n], msg, index)
+		if err != nil {
+			return err
+		}
+		if violation != nil {
+			violations = append(violations, violation)
+		}
+	}
+	if len(violations) == 0 {
+		return err
+	}
+	if validationErr == nil {
+		validationErr = &protovalidate.ValidationError{}
+	}
+	validationErr.Violations = append(validationErr.Violations, violations...)
+	return validationErr
+}
+
+func newDuplicateViolation(unique *uniqueField, value protoreflect.Value, index, first int) *protovalidate.Violation {
+	elements := make([]*validatepb.FieldPathElement, len(unique.fields))
+	for i, field := range unique.fields {
+		elements[i] = newFieldPathElement(field)
+	}
+	return &protovalidate.Violation{
+		Proto: &validatepb.Violation{
+			Field:        &validatepb.FieldPath{Elements: elements},
+			ConstraintId: proto.String(streamUniqueID),
+			Message: proto.String(fmt.Sprintf(
+				"value must be unique across the stream, but message %d repeats message %d",
+				index, first,
+			)),
+		},
+		FieldValue:      value,
+		FieldDescriptor: unique.fields[len(unique.fields)-1],
+	}
+}
+
+// validateStreamMessage validates a message on a stream, unless the stream's
+// context is done. Validators can't be interrupted, so the validator runs on
+// another goroutine, and the stream stops waiting for it and fails with the
+// context's error once the context is done, like a validation that times out
+// (see WithValidationTimeout). Only the validator is abandoned: the
+// Interceptor's hooks and the stream's state are only touched on the
+// stream's goroutine. Panics can't propagate from the other goroutine, so
+// they're returned as a PanicError, as with WithRecover.
+func (i *Interceptor) validateStreamMessage(ctx context.Context, spec connect.Spec, msg any, stream *streamState) error {
+	stream.receive(msg)
+	if err := ctx.Err(); err != nil {
+		return contextError(err)
+	}
+	err := i.validate(withInterruptible(ctx), spec, msg, stream)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return contextError(ctxErr)
+	}
+	return err
+}
+
+type interruptibleKey struct{}
+
+// withInterruptible marks ctx so that validators stop waiting for the
+// validator once ctx is done.
+func withInterruptible(ctx context.Context) context.Context {
+	return context.WithValue(ctx, interruptibleKey{}, true)
+}
+
+func interruptible(ctx context.Context) bool {
+	ok, _ := ctx.Value(interruptibleKey{}).(bool)
+	return ok
+}
+
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return connect.NewError(connect.CodeDeadlineExceeded, err)
+	}
+	return connect.NewError(connect.CodeCanceled, err)
+}
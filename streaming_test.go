@@ -0,0 +1,422 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	calculatorv1 "connectrpc.com/validate/internal/gen/example/calculator/v1"
+	"connectrpc.com/validate/internal/gen/example/calculator/v1/calculatorv1connect"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The calculator service only has a bidi method, so these tests register
+// generic handlers for the other stream types.
+const (
+	clientStreamProcedure = "/example.calculator.v1.CalculatorService/ClientSum"
+	serverStreamProcedure = "/example.calculator.v1.CalculatorService/ServerCount"
+	bidiStreamProcedure   = "/example.calculator.v1.CalculatorService/BidiSum"
+)
+
+// TestFinalMessageRejections checks that violations in the last message
+// before the client half-closes reach the client, even when the handler
+// doesn't return the error from Receive.
+func TestFinalMessageRejections(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor()
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(clientStreamProcedure, connect.NewClientStreamHandler(
+		clientStreamProcedure,
+		func(_ context.Context, stream *connect.ClientStream[calculatorv1.CumSumRequest]) (*connect.Response[calculatorv1.CumSumResponse], error) {
+			var sum int64
+			for stream.Receive() {
+				sum += stream.Msg().Number
+			}
+			// Deliberately ignores stream.Err().
+			return connect.NewResponse(&calculatorv1.CumSumResponse{Sum: sum}), nil
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	mux.Handle(serverStreamProcedure, connect.NewServerStreamHandler(
+		serverStreamProcedure,
+		func(_ context.Context, req *connect.Request[calculatorv1.CumSumRequest], stream *connect.ServerStream[calculatorv1.CumSumResponse]) error {
+			return stream.Send(&calculatorv1.CumSumResponse{Sum: req.Msg.Number})
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	mux.Handle(bidiStreamProcedure, connect.NewBidiStreamHandler(
+		bidiStreamProcedure,
+		func(_ context.Context, stream *connect.BidiStream[calculatorv1.CumSumRequest, calculatorv1.CumSumResponse]) error {
+			var sum int64
+			for {
+				req, err := stream.Receive()
+				if err != nil {
+					// Deliberately swallows the error.
+					return nil
+				}
+				sum += req.Number
+				if err := stream.Send(&calculatorv1.CumSumResponse{Sum: sum}); err != nil {
+					return err
+				}
+			}
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	srv := httptest.NewUnstartedServer(mux)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	t.Run("client_stream", func(t *testing.T) {
+		t.Parallel()
+		client := connect.NewClient[calculatorv1.CumSumRequest, calculatorv1.CumSumResponse](
+			srv.Client(), srv.URL+clientStreamProcedure,
+		)
+		stream := client.CallClientStream(context.Background())
+		require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: 1}))
+		require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: -1}))
+		_, err := stream.CloseAndReceive()
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		assert.Equal(t, "int64.gt", requireSingleViolation(t, err).GetConstraintId())
+	})
+	t.Run("server_stream", func(t *testing.T) {
+		t.Parallel()
+		client := connect.NewClient[calculatorv1.CumSumRequest, calculatorv1.CumSumResponse](
+			srv.Client(), srv.URL+serverStreamProcedure,
+		)
+		stream, err := client.CallServerStream(context.Background(), connect.NewRequest(&calculatorv1.CumSumRequest{Number: -1}))
+		require.NoError(t, err)
+		assert.False(t, stream.Receive())
+		err = stream.Err()
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		assert.Equal(t, "int64.gt", requireSingleViolation(t, err).GetConstraintId())
+		require.NoError(t, stream.Close())
+	})
+	t.Run("bidi_stream", func(t *testing.T) {
+		t.Parallel()
+		client := connect.NewClient[calculatorv1.CumSumRequest, calculatorv1.CumSumResponse](
+			srv.Client(), srv.URL+bidiStreamProcedure,
+		)
+		stream := client.CallBidiStream(context.Background())
+		require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: 2}))
+		require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: -1}))
+		require.NoError(t, stream.CloseRequest())
+		res, err := stream.Receive()
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), res.GetSum())
+		_, err = stream.Receive()
+		require.Error(t, err)
+		require.False(t, errors.Is(err, io.EOF), "violation lost")
+		assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+		assert.Equal(t, "int64.gt", requireSingleViolation(t, err).GetConstraintId())
+		require.NoError(t, stream.CloseResponse())
+	})
+}
+
+func TestWithStreamingResponses(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithStreamingResponses())
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(serverStreamProcedure, connect.NewServerStreamHandler(
+		serverStreamProcedure,
+		func(_ context.Context, req *connect.Request[calculatorv1.CumSumRequest], stream *connect.ServerStream[calculatorv1.CumSumResponse]) error {
+			// Counts down past zero, so small requests produce invalid
+			// responses.
+			for n := req.Msg.Number; n >= req.Msg.Number-3; n-- {
+				if err := stream.Send(&calculatorv1.CumSumResponse{Sum: n}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	srv := httptest.NewUnstartedServer(mux)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	client := connect.NewClient[calculatorv1.CumSumRequest, calculatorv1.CumSumResponse](
+		srv.Client(), srv.URL+serverStreamProcedure,
+	)
+
+	tests := []struct {
+		name   string
+		number int64
+		want   []int64
+		code   connect.Code
+	}{
+		{name: "valid", number: 5, want: []int64{5, 4, 3, 2}},
+		{name: "invalid", number: 1, want: []int64{1, 0}, code: connect.CodeInvalidArgument},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			stream, err := client.CallServerStream(context.Background(), connect.NewRequest(&calculatorv1.CumSumRequest{Number: test.number}))
+			require.NoError(t, err)
+			var got []int64
+			for stream.Receive() {
+				got = append(got, stream.Msg().GetSum())
+			}
+			assert.Equal(t, test.want, got)
+			if test.code == 0 {
+				require.NoError(t, stream.Err())
+			} else {
+				require.Error(t, stream.Err())
+				assert.Equal(t, test.code, connect.CodeOf(stream.Err()))
+				assert.Equal(t, "int64.gte", requireSingleViolation(t, stream.Err()).GetConstraintId())
+			}
+			require.NoError(t, stream.Close())
+		})
+	}
+}
+
+func TestWithUniqueStreamField(t *testing.T) {
+	t.Parallel()
+	desc := (&calculatorv1.CumSumRequest{}).ProtoReflect().Descriptor()
+	interceptor, err := validate.NewInterceptor(validate.WithUniqueStreamField(desc, "number"))
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(calculatorv1connect.CalculatorServiceCumSumProcedure, connect.NewBidiStreamHandler(
+		calculatorv1connect.CalculatorServiceCumSumProcedure,
+		cumSumSuccess,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := httptest.NewUnstartedServer(mux)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	stream := calculatorv1connect.NewCalculatorServiceClient(srv.Client(), srv.URL).CumSum(context.Background())
+	for _, number := range []int64{1, 2, 1} {
+		require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: number}))
+	}
+	require.NoError(t, stream.CloseRequest())
+	for _, want := range []int64{1, 3} {
+		res, err := stream.Receive()
+		require.NoError(t, err)
+		assert.Equal(t, want, res.GetSum())
+	}
+	_, err = stream.Receive()
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	violation := requireSingleViolation(t, err)
+	assert.Equal(t, "stream.unique", violation.GetConstraintId())
+	assert.Equal(t, "number", protovalidate.FieldPathString(violation.GetField()))
+	assert.Contains(t, violation.GetMessage(), "message 2 repeats message 0")
+	require.NoError(t, stream.CloseResponse())
+}
+
+func TestWithUniqueStreamFieldSampling(t *testing.T) {
+	t.Parallel()
+	// At a rate of 0.5, the key "yes" isn't sampled and "no" is. Only the
+	// first message is passed through unvalidated, but it still counts.
+	var calls atomic.Int32
+	desc := (&calculatorv1.CumSumRequest{}).ProtoReflect().Descriptor()
+	interceptor, err := validate.NewInterceptor(
+		validate.WithUniqueStreamField(desc, "number"),
+		validate.WithSampleRate(0.5),
+		validate.WithSamplingKey(func(context.Context, connect.Spec, any) string {
+			if calls.Add(1) == 1 {
+				return "yes"
+			}
+			return "no"
+		}),
+	)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(calculatorv1connect.CalculatorServiceCumSumProcedure, connect.NewBidiStreamHandler(
+		calculatorv1connect.CalculatorServiceCumSumProcedure,
+		cumSumSuccess,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := httptest.NewUnstartedServer(mux)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	stream := calculatorv1connect.NewCalculatorServiceClient(srv.Client(), srv.URL).CumSum(context.Background())
+	for _, number := range []int64{1, 2, 1} {
+		require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: number}))
+	}
+	require.NoError(t, stream.CloseRequest())
+	for _, want := range []int64{1, 3} {
+		res, err := stream.Receive()
+		require.NoError(t, err)
+		assert.Equal(t, want, res.GetSum())
+	}
+	_, err = stream.Receive()
+	violation := requireSingleViolation(t, err)
+	assert.Equal(t, "stream.unique", violation.GetConstraintId())
+	assert.Contains(t, violation.GetMessage(), "message 2 repeats message 0")
+	require.NoError(t, stream.CloseResponse())
+}
+
+func TestWithUniqueStreamFieldInvalidPath(t *testing.T) {
+	t.Parallel()
+	desc := (&userv1.CreateUserRequest{}).ProtoReflect().Descriptor()
+	for _, path := range []string{"nickname", "user", "user.email.length"} {
+		_, err := validate.NewInterceptor(validate.WithUniqueStreamField(desc, path))
+		assert.Error(t, err, path)
+	}
+	_, err := validate.NewInterceptor(validate.WithUniqueStreamField(desc, "user.email"))
+	assert.NoError(t, err)
+}
+
+func TestWithStreamInvariants(t *testing.T) {
+	t.Parallel()
+	desc := (&calculatorv1.CumSumRequest{}).ProtoReflect().Descriptor()
+	interceptor, err := validate.NewInterceptor(validate.WithStreamInvariants(
+		desc,
+		validate.StreamInvariant{
+			ID:         "cum_sum.increasing",
+			Message:    "numbers must increase",
+			Expression: "index == 0 || this.number > prev.number",
+		},
+		validate.StreamInvariant{
+			ID:         "cum_sum.total_size",
+			Expression: "total_size <= 8 ? '' : 'stream is ' + string(total_size) + ' bytes'",
+		},
+	))
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(calculatorv1connect.CalculatorServiceCumSumProcedure, connect.NewBidiStreamHandler(
+		calculatorv1connect.CalculatorServiceCumSumProcedure,
+		cumSumSuccess,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := httptest.NewUnstartedServer(mux)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	client := calculatorv1connect.NewCalculatorServiceClient(srv.Client(), srv.URL)
+
+	tests := []struct {
+		name      string
+		numbers   []int64
+		wantRule  string
+		wantValid int // number of messages accepted before the violation
+	}{
+		{name: "valid", numbers: []int64{1, 2, 3}, wantValid: 3},
+		{name: "decreasing", numbers: []int64{1, 3, 2}, wantRule: "cum_sum.increasing", wantValid: 2},
+		{name: "too_large", numbers: []int64{1, 2, 3, 4, 5}, wantRule: "cum_sum.total_size", wantValid: 4},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			stream := client.CumSum(context.Background())
+			for _, number := range test.numbers {
+				require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: number}))
+			}
+			require.NoError(t, stream.CloseRequest())
+			for i := 0; i < test.wantValid; i++ {
+				_, err := stream.Receive()
+				require.NoError(t, err)
+			}
+			_, err := stream.Receive()
+			if test.wantRule == "" {
+				assert.ErrorIs(t, err, io.EOF)
+			} else {
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+				violation := requireSingleViolation(t, err)
+				assert.Equal(t, test.wantRule, violation.GetConstraintId())
+				assert.Equal(t, fmt.Sprintf("stream[%d]", test.wantValid), protovalidate.FieldPathString(violation.GetField()))
+			}
+			require.NoError(t, stream.CloseResponse())
+		})
+	}
+}
+
+func TestWithStreamInvariantsInvalid(t *testing.T) {
+	t.Parallel()
+	desc := (&calculatorv1.CumSumRequest{}).ProtoReflect().Descriptor()
+	for _, expression := range []string{"this.missing > 0", "index", "this.number >"} {
+		_, err := validate.NewInterceptor(validate.WithStreamInvariants(
+			desc,
+			validate.StreamInvariant{ID: "test", Expression: expression},
+		))
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestStreamCancellation(t *testing.T) {
+	t.Parallel()
+	validator := newBlockingValidator()
+	t.Cleanup(func() { close(validator.release) })
+	interceptor, err := validate.NewInterceptor(validate.WithValidator(validator))
+	require.NoError(t, err)
+
+	handlerCtxs := make(chan context.Context, 1)
+	received := make(chan error, 1)
+	mux := http.NewServeMux()
+	mux.Handle(bidiStreamProcedure, connect.NewBidiStreamHandler(
+		bidiStreamProcedure,
+		func(ctx context.Context, stream *connect.BidiStream[calculatorv1.CumSumRequest, calculatorv1.CumSumResponse]) error {
+			handlerCtxs <- ctx
+			_, err := stream.Receive()
+			received <- err
+			return err
+		},
+		connect.WithInterceptors(interceptor),
+	))
+	srv := httptest.NewUnstartedServer(mux)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := connect.NewClient[calculatorv1.CumSumRequest, calculatorv1.CumSumResponse](
+		srv.Client(), srv.URL+bidiStreamProcedure,
+	)
+	stream := client.CallBidiStream(ctx)
+	require.NoError(t, stream.Send(&calculatorv1.CumSumRequest{Number: 1}))
+	<-handlerCtxs
+	<-validator.entered
+	cancel()
+	// The validator is still blocked, but the stream stops waiting for it.
+	select {
+	case err := <-received:
+		require.Error(t, err)
+		assert.Equal(t, connect.CodeCanceled, connect.CodeOf(err))
+	case <-time.After(5 * time.Second):
+		t.Fatal("validation wasn't interrupted")
+	}
+	_ = stream.CloseRequest()
+	_ = stream.CloseResponse()
+}
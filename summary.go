@@ -0,0 +1,133 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"strconv"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+)
+
+// SummaryReason is the reason of the [google.rpc.ErrorInfo] detail that
+// summarizes large sets of violations. See [WithViolationSummary].
+//
+// [google.rpc.ErrorInfo]: https://pkg.go.dev/google.golang.org/genproto/googleapis/rpc/errdetails#ErrorInfo
+const SummaryReason = "VIOLATIONS_SUMMARIZED"
+
+// summaryDomain is the domain of the summary detail.
+const summaryDomain = "connectrpc.com/validate"
+
+// WithViolationSummary configures the [Interceptor] to keep errors for
+// pathological messages bounded: if a message has more than threshold
+// violations, the error reports only the first keep of them, along with a
+// [google.rpc.ErrorInfo] detail summarizing the rest. The detail's reason is
+// [SummaryReason], and its metadata holds the "total" number of violations
+// and numbered groups of violations of the same field and constraint, in the
+// order of their first violation: "field_0" holds the first group's field
+// path, "rule_0" its constraint ID, and "count_0" its number of violations,
+// then "field_1", "rule_1", and "count_1", and so on. The keys conform to
+// ErrorInfo's rules for metadata keys, so gateways don't drop them. List
+// indexes and map keys are dropped from the field paths, so that every
+// element of a list is counted together, as in "items.sku". If the error
+// reveals only field paths (see [WithDetailLevel]), violations are grouped by
+// field alone, and there are no "rule_" keys.
+//
+// [WithMaxViolations] still limits the violations that are reported, and
+// terse errors (see [WithoutErrorDetails]) have no summary. Non-positive
+// thresholds disable summaries.
+//
+// [google.rpc.ErrorInfo]: https://pkg.go.dev/google.golang.org/genproto/googleapis/rpc/errdetails#ErrorInfo
+func WithViolationSummary(threshold, keep int) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.violations.summaryThreshold = threshold
+		i.violations.summaryKeep = max(keep, 0)
+	})
+}
+
+// violationSummary counts the violations of a message, by field and
+// constraint. Keys holds the counted keys in the order of their first
+// violation.
+type violationSummary struct {
+	total  int
+	keys   []summaryKey
+	counts map[summaryKey]int
+}
+
+type summaryKey struct {
+	field        string
+	constraintID string
+}
+
+func summarize(err *protovalidate.ValidationError) *violationSummary {
+	summary := &violationSummary{
+		total:  len(err.Violations),
+		counts: make(map[summaryKey]int),
+	}
+	for _, violation := range err.Violations {
+		key := summaryKey{
+			field:        fieldPathWithoutSubscripts(violation.Proto.GetField()),
+			constraintID: violation.Proto.GetConstraintId(),
+		}
+		if summary.counts[key] == 0 {
+			summary.keys = append(summary.keys, key)
+		}
+		summary.counts[key]++
+	}
+	return summary
+}
+
+// toErrorInfo renders the summary. If pathsOnly is set, it counts violations
+// by field path only.
+func (s *violationSummary) toErrorInfo(pathsOnly bool) *errdetails.ErrorInfo {
+	keys, counts := s.keys, s.counts
+	if pathsOnly {
+		keys, counts = nil, make(map[summaryKey]int, len(s.counts))
+		for _, key := range s.keys {
+			field := summaryKey{field: key.field}
+			if counts[field] == 0 {
+				keys = append(keys, field)
+			}
+			counts[field] += s.counts[key]
+		}
+	}
+	metadata := map[string]string{"total": strconv.Itoa(s.total)}
+	for n, key := range keys {
+		suffix := strconv.Itoa(n)
+		metadata["field_"+suffix] = key.field
+		if !pathsOnly {
+			metadata["rule_"+suffix] = key.constraintID
+		}
+		metadata["count_"+suffix] = strconv.Itoa(counts[key])
+	}
+	return &errdetails.ErrorInfo{
+		Reason:   SummaryReason,
+		Domain:   summaryDomain,
+		Metadata: metadata,
+	}
+}
+
+func fieldPathWithoutSubscripts(path *validatepb.FieldPath) string {
+	elements := make([]*validatepb.FieldPathElement, len(path.GetElements()))
+	for i, element := range path.GetElements() {
+		elements[i] = &validatepb.FieldPathElement{
+			FieldNumber: element.FieldNumber,
+			FieldName:   proto.String(element.GetFieldName()),
+		}
+	}
+	return protovalidate.FieldPathString(&validatepb.FieldPath{Elements: elements})
+}
@@ -0,0 +1,161 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"testing"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWithViolationSummary(t *testing.T) {
+	t.Parallel()
+	violations := []*validate.Violation{
+		// A field named total doesn't collide with the total count.
+		newViolation("required", &validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("total")}),
+	}
+	for n := uint64(0); n < 5; n++ {
+		violations = append(violations, newViolation("string.min_len",
+			&validatepb.FieldPathElement{
+				FieldNumber: proto.Int32(2),
+				FieldName:   proto.String("items"),
+				Subscript:   &validatepb.FieldPathElement_Index{Index: n},
+			},
+			&validatepb.FieldPathElement{FieldNumber: proto.Int32(1), FieldName: proto.String("sku")},
+		))
+	}
+	validator := staticValidator{err: &protovalidate.ValidationError{Violations: violations}}
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	req := connect.NewRequest(&userv1.CreateUserRequest{})
+
+	tests := []struct {
+		name         string
+		opts         []validate.Option
+		wantReported int
+		wantMetadata map[string]string // nil if there's no summary
+	}{
+		{
+			name:         "under_threshold",
+			opts:         []validate.Option{validate.WithViolationSummary(6, 2)},
+			wantReported: 6,
+		},
+		{
+			name:         "summarized",
+			opts:         []validate.Option{validate.WithViolationSummary(3, 2)},
+			wantReported: 2,
+			wantMetadata: map[string]string{
+				"total":   "6",
+				"field_0": "total",
+				"rule_0":  "required",
+				"count_0": "1",
+				"field_1": "items.sku",
+				"rule_1":  "string.min_len",
+				"count_1": "5",
+			},
+		},
+		{
+			name: "field_paths",
+			opts: []validate.Option{
+				validate.WithViolationSummary(3, 2),
+				validate.WithDetailLevel(func(context.Context, connect.Spec) validate.DetailLevel {
+					return validate.DetailFieldPaths
+				}),
+			},
+			wantReported: 2,
+			wantMetadata: map[string]string{
+				"total":   "6",
+				"field_0": "total",
+				"count_0": "1",
+				"field_1": "items.sku",
+				"count_1": "5",
+			},
+		},
+		{
+			name:         "max_violations",
+			opts:         []validate.Option{validate.WithViolationSummary(3, 2), validate.WithMaxViolations(1)},
+			wantReported: 1,
+			wantMetadata: map[string]string{
+				"total":   "6",
+				"field_0": "total",
+				"rule_0":  "required",
+				"count_0": "1",
+				"field_1": "items.sku",
+				"rule_1":  "string.min_len",
+				"count_1": "5",
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			opts := append([]validate.Option{validate.WithValidator(validator)}, test.opts...)
+			interceptor, err := validate.NewInterceptor(opts...)
+			require.NoError(t, err)
+			_, err = interceptor.WrapUnary(next)(context.Background(), req)
+			require.Error(t, err)
+			var connectErr *connect.Error
+			require.ErrorAs(t, err, &connectErr)
+			details := connectErr.Details()
+			if test.wantMetadata == nil {
+				require.Len(t, details, 1)
+			} else {
+				require.Len(t, details, 2)
+				last, err := details[1].Value()
+				require.NoError(t, err)
+				info, ok := last.(*errdetails.ErrorInfo)
+				require.True(t, ok)
+				assert.Equal(t, validate.SummaryReason, info.GetReason())
+				assert.Equal(t, test.wantMetadata, info.GetMetadata())
+				for key := range info.GetMetadata() {
+					assert.Regexp(t, `^[a-z][a-zA-Z0-9-_]{1,63}$`, key)
+				}
+			}
+			first, err := details[0].Value()
+			require.NoError(t, err)
+			reported, ok := first.(*validatepb.Violations)
+			require.True(t, ok)
+			assert.Len(t, reported.GetViolations(), test.wantReported)
+		})
+	}
+}
+
+func newViolation(constraintID string, elements ...*validatepb.FieldPathElement) *validate.Violation {
+	return &validate.Violation{Proto: &validatepb.Violation{
+		Field:        &validatepb.FieldPath{Elements: elements},
+		ConstraintId: proto.String(constraintID),
+		Message:      proto.String("invalid"),
+	}}
+}
+
+// staticValidator returns the same error for every message.
+type staticValidator struct {
+	err error
+}
+
+func (v staticValidator) Validate(proto.Message) error {
+	return v.err
+}
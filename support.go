@@ -0,0 +1,176 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+)
+
+// WithRecentRejections configures the [Interceptor] to remember its n most
+// recent rejections of invalid messages, so that they're included in
+// [Interceptor.SupportBundle]. Each rejection is remembered with its time,
+// procedure, message type, code, rejection ID (see [WithRejectionIDs]), and
+// violations, which are reduced to field paths and constraint IDs, since
+// their messages may quote field values. Non-positive n disables it.
+func WithRecentRejections(n int) Option {
+	return optionFunc(func(i *Interceptor) {
+		if n <= 0 {
+			i.telemetry.recentRejections = nil
+			return
+		}
+		i.telemetry.recentRejections = &rejectionRing{entries: make([]recentRejection, 0, n)}
+	})
+}
+
+// SupportBundle writes a snapshot of the Interceptor's state to w as a single
+// JSON document, meant to be attached to bug reports. The document holds:
+//
+//   - "config": the [Interceptor.EffectiveConfig]
+//   - "diagnostics": the module version, the Go version, and the state of
+//     the default validator
+//   - "stats": the most expensive messages, with [WithCostProfiling], the
+//     current window, with [WithRollups], and the validations in flight,
+//     with concurrency limits
+//   - "recent_rejections": the remembered rejections, oldest first, with
+//     [WithRecentRejections]
+//
+// It never includes field values.
+func (i *Interceptor) SupportBundle(w io.Writer) error {
+	bundle := supportBundle{
+		GeneratedAt: i.now(),
+		Config:      i.EffectiveConfig(),
+		Diagnostics: supportDiagnostics{
+			Validator:        validatorIdentity(),
+			GoVersion:        runtime.Version(),
+			ValidatorSwapped: i.validators.swapped.Load() != nil,
+		},
+		Stats: supportStats{
+			ExpensiveMessages: i.ExpensiveMessages(-1),
+		},
+		RecentRejections: []recentRejection{},
+	}
+	if !i.nop {
+		_, err := i.defaultValidator()
+		bundle.Diagnostics.ValidatorReady = err == nil
+		if err != nil {
+			bundle.Diagnostics.ValidatorError = err.Error()
+		}
+	}
+	if i.telemetry.rollups != nil {
+		bundle.Stats.CurrentRollup = i.telemetry.rollups.current()
+	}
+	if i.limits.procedureLimiter != nil {
+		bundle.Stats.InFlight = make(map[string]int)
+		i.limits.procedureLimiter.mu.Lock()
+		for procedure, semaphore := range i.limits.procedureLimiter.semaphores {
+			if n := len(semaphore); n > 0 {
+				bundle.Stats.InFlight[procedure] = n
+			}
+		}
+		i.limits.procedureLimiter.mu.Unlock()
+	}
+	if i.limits.concurrencyLimiter != nil {
+		total := len(i.limits.concurrencyLimiter.slots)
+		bundle.Stats.InFlightTotal = &total
+	}
+	if i.telemetry.recentRejections != nil {
+		bundle.RecentRejections = i.telemetry.recentRejections.list()
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(bundle); err != nil {
+		return fmt.Errorf("write support bundle: %w", err)
+	}
+	return nil
+}
+
+type supportBundle struct {
+	GeneratedAt      time.Time          `json:"generated_at"`
+	Config           Config             `json:"config"`
+	Diagnostics      supportDiagnostics `json:"diagnostics"`
+	Stats            supportStats       `json:"stats"`
+	RecentRejections []recentRejection  `json:"recent_rejections"`
+}
+
+type supportDiagnostics struct {
+	Validator        string `json:"validator"`
+	GoVersion        string `json:"go_version"`
+	ValidatorReady   bool   `json:"validator_ready"`
+	ValidatorError   string `json:"validator_error,omitempty"`
+	ValidatorSwapped bool   `json:"validator_swapped"`
+}
+
+type supportStats struct {
+	ExpensiveMessages []MessageCost  `json:"expensive_messages"`
+	CurrentRollup     *Rollup        `json:"current_rollup,omitempty"`
+	InFlight          map[string]int `json:"in_flight,omitempty"`
+	InFlightTotal     *int           `json:"in_flight_total,omitempty"`
+}
+
+type recentRejection struct {
+	Time        time.Time `json:"time"`
+	Procedure   string    `json:"procedure"`
+	MessageType string    `json:"message_type"`
+	Code        string    `json:"code"`
+	RejectionID string    `json:"rejection_id,omitempty"`
+	Violations  []string  `json:"violations"`
+}
+
+// rejectionRing remembers the most recent rejections, up to its capacity.
+type rejectionRing struct {
+	mu      sync.Mutex
+	entries []recentRejection
+	next    int // index of the oldest entry, once the ring is full
+}
+
+func (r *rejectionRing) add(rejection recentRejection) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) < cap(r.entries) {
+		r.entries = append(r.entries, rejection)
+		return
+	}
+	r.entries[r.next] = rejection
+	r.next = (r.next + 1) % len(r.entries)
+}
+
+// list returns the remembered rejections, oldest first.
+func (r *rejectionRing) list() []recentRejection {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append(append([]recentRejection{}, r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// rememberRejection adds a rejected message to the recent rejections.
+func (i *Interceptor) rememberRejection(ctx context.Context, spec connect.Spec, msg any, validationErr *protovalidate.ValidationError, err error) {
+	rejection := recentRejection{
+		Time:        i.now(),
+		Procedure:   spec.Procedure,
+		MessageType: messageType(msg),
+		Code:        connect.CodeOf(err).String(),
+		Violations:  compactViolations(validationErr),
+	}
+	rejection.RejectionID, _ = RejectionID(ctx)
+	i.telemetry.recentRejections.add(rejection)
+}
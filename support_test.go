@@ -0,0 +1,77 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/internal/gen/example/user/v1/userv1connect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupportBundle(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	interceptor, err := validate.NewInterceptor(
+		validate.WithClock(func() time.Time { return now }),
+		validate.WithRecentRejections(1),
+		validate.WithRejectionIDs(),
+		validate.WithRejectionIDGenerator(func() string { return "rejection" }),
+		validate.WithRollups(time.Hour, -1, func(validate.Rollup) {}),
+	)
+	require.NoError(t, err)
+	client := newUserClient(t, interceptor)
+	for _, user := range []*userv1.User{{Email: "foo"}, {Email: "someone@example.com", Handle: "admin"}} {
+		_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: user}))
+		require.Error(t, err)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, interceptor.SupportBundle(&buf))
+	assert.NotContains(t, buf.String(), "admin")
+	var bundle struct {
+		Config      validate.Config `json:"config"`
+		Diagnostics struct {
+			ValidatorReady bool `json:"validator_ready"`
+		} `json:"diagnostics"`
+		Stats struct {
+			CurrentRollup *validate.Rollup `json:"current_rollup"`
+		} `json:"stats"`
+		RecentRejections []map[string]any `json:"recent_rejections"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &bundle))
+	assert.Equal(t, 1, bundle.Config.Telemetry.RecentRejections)
+	assert.True(t, bundle.Diagnostics.ValidatorReady)
+	require.NotNil(t, bundle.Stats.CurrentRollup)
+	require.Len(t, bundle.Stats.CurrentRollup.Procedures, 1)
+	assert.Equal(t, 2, bundle.Stats.CurrentRollup.Procedures[0].Rejected)
+	// Only the latest rejection is remembered, without its message.
+	assert.Equal(t, []map[string]any{{
+		"time":         "2030-01-01T00:00:00Z",
+		"procedure":    userv1connect.UserServiceCreateUserProcedure,
+		"message_type": "example.user.v1.CreateUserRequest",
+		"code":         "invalid_argument",
+		"rejection_id": "rejection",
+		"violations":   []any{"user.handle [user.handle]"},
+	}}, bundle.RecentRejections)
+}
@@ -0,0 +1,115 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithValidationTimeout configures the [Interceptor] to stop waiting for the
+// validator after d, so that a single pathological message, like one with
+// huge repeated fields or expensive regular expressions, can't stall an RPC.
+// Messages that time out are rejected with a [*TimeoutError], coded as
+// configured with [WithValidationTimeoutCode]. They're never let through,
+// even with [WithFailOpen], because the abandoned validation keeps reading
+// the message in the background until it finishes: handlers and clients must
+// not modify the message afterwards. Until then, the abandoned validation
+// also keeps its slots of [WithProcedureConcurrencyLimit] and
+// [WithConcurrencyLimit], so timeouts can't push the number of validations
+// running at once past the limits. The function configured
+// with [WithValidatorErrorFunc] is called for them too. Procedures exempted
+// with [WithLimitExemptions] have no timeout. Non-positive durations disable
+// the timeout, which is the default.
+func WithValidationTimeout(d time.Duration) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.limits.validationTimeout = d
+	})
+}
+
+// WithValidationTimeoutCode configures the [Interceptor] to reject messages
+// that time out with the given code. The default is
+// [connect.CodeResourceExhausted]; [connect.CodeInternal] is another typical
+// choice.
+func WithValidationTimeoutCode(code connect.Code) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.limits.timeoutCode = code
+	})
+}
+
+// A TimeoutError reports that validating a message took longer than the
+// timeout configured with [WithValidationTimeout].
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("validation timed out after %v", e.Timeout)
+}
+
+// validateWithTimeout runs the validator on another goroutine, returning a
+// TimeoutError if it doesn't finish in time and timeout is set, and the
+// context's error if ctx is interruptible and done first. Panics can't
+// propagate from the other goroutine, so they're returned as a PanicError. An
+// abandoned validation keeps the concurrency slots held in ctx until it
+// finishes.
+func (i *Interceptor) validateWithTimeout(ctx context.Context, validator protovalidate.Validator, msg proto.Message, timeout bool) error {
+	done := make(chan error, 1)
+	go func() {
+		var err error
+		defer func() { done <- err }()
+		defer func() {
+			if r := recover(); r != nil {
+				err = &PanicError{Value: r}
+			}
+		}()
+		err = validator.Validate(msg)
+	}()
+	var expired <-chan time.Time
+	if timeout {
+		timer := time.NewTimer(i.limits.validationTimeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var canceled <-chan struct{}
+	if interruptible(ctx) {
+		canceled = ctx.Done()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-expired:
+		abandon(ctx, done)
+		return &TimeoutError{Timeout: i.limits.validationTimeout}
+	case <-canceled:
+		abandon(ctx, done)
+		return contextError(ctx.Err())
+	}
+}
+
+// abandon releases the concurrency slots held in ctx once the validation
+// that reports to done finishes.
+func abandon(ctx context.Context, done <-chan error) {
+	release := detachSlots(ctx)
+	go func() {
+		<-done
+		release()
+	}()
+}
@@ -0,0 +1,122 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithValidationTimeout(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		opts     []validate.Option
+		wantCode connect.Code
+	}{
+		{
+			name:     "default_code",
+			wantCode: connect.CodeResourceExhausted,
+		},
+		{
+			name:     "custom_code",
+			opts:     []validate.Option{validate.WithValidationTimeoutCode(connect.CodeInternal)},
+			wantCode: connect.CodeInternal,
+		},
+		{
+			name:     "never_fails_open",
+			opts:     []validate.Option{validate.WithFailOpen("*")},
+			wantCode: connect.CodeResourceExhausted,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			validator := newBlockingValidator()
+			t.Cleanup(func() { close(validator.release) })
+			var failures []error
+			opts := append([]validate.Option{
+				validate.WithValidator(validator),
+				validate.WithValidationTimeout(10 * time.Millisecond),
+				validate.WithValidatorErrorFunc(func(_ context.Context, _ connect.Spec, err error, _ bool) {
+					failures = append(failures, err)
+				}),
+			}, test.opts...)
+			interceptor, err := validate.NewInterceptor(opts...)
+			require.NoError(t, err)
+			_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+			})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+			assert.Equal(t, test.wantCode, connect.CodeOf(err))
+			var timeoutErr *validate.TimeoutError
+			require.ErrorAs(t, err, &timeoutErr)
+			assert.Equal(t, 10*time.Millisecond, timeoutErr.Timeout)
+			assert.Len(t, failures, 1)
+		})
+	}
+}
+
+func TestWithValidationTimeoutFast(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithValidationTimeout(time.Minute))
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	requireSingleViolation(t, err)
+}
+
+func TestValidationTimeoutHoldsSlots(t *testing.T) {
+	t.Parallel()
+	validator := newBlockingValidator()
+	interceptor, err := validate.NewInterceptor(
+		validate.WithValidator(validator),
+		validate.WithValidationTimeout(10*time.Millisecond),
+		validate.WithConcurrencyLimit(1, validate.OverflowReject),
+	)
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+	assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+	require.ErrorAs(t, err, new(*validate.TimeoutError))
+
+	// The abandoned validation still holds the only slot.
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+	assert.Equal(t, connect.CodeResourceExhausted, connect.CodeOf(err))
+	assert.False(t, errors.As(err, new(*validate.TimeoutError)))
+	assert.Equal(t, int64(1), validator.calls.Load())
+
+	// Once it finishes, the slot is released.
+	close(validator.release)
+	assert.Eventually(t, func() bool {
+		_, err := call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{}))
+		return err == nil
+	}, 5*time.Second, time.Millisecond)
+}
@@ -0,0 +1,200 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// UpstreamResultHeader is the request header that carries the result of an
+// earlier validation layer, encoded with [EncodeUpstreamResult]. See
+// [WithUpstreamResults].
+const UpstreamResultHeader = "Validate-Upstream-Result"
+
+type upstreamResultKey struct{}
+
+// MarkValidatedUpstream returns a copy of ctx that records the result of an
+// earlier validation layer, like a validating codec: the violations it found
+// in the request, or none if the request is valid. It only has an effect on
+// Interceptors configured with [WithUpstreamResults].
+func MarkValidatedUpstream(ctx context.Context, violations *validatepb.Violations) context.Context {
+	if violations == nil {
+		violations = &validatepb.Violations{}
+	}
+	return context.WithValue(ctx, upstreamResultKey{}, violations)
+}
+
+// EncodeUpstreamResult encodes the result of a validation layer for the
+// [UpstreamResultHeader]: the violations reported by err, or none if err is
+// nil. Validating proxies use it to pass their result along with the
+// requests they forward. Errors that don't report violations encode as a
+// valid result.
+func EncodeUpstreamResult(err error) string {
+	violations := &validatepb.Violations{}
+	if validationErr := new(protovalidate.ValidationError); errors.As(err, &validationErr) {
+		violations = validationErr.ToProto()
+	}
+	// Marshaling Violations can't fail.
+	data, _ := proto.Marshal(violations)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// WithUpstreamResults configures the [Interceptor] to trust the results of an
+// earlier validation layer in layered deployments, rather than validating
+// unary requests again. Results are taken from the context, if it was marked
+// with [MarkValidatedUpstream], or from the [UpstreamResultHeader] if allow
+// returns true. Requests without a trusted result are validated as usual.
+//
+// Violations found upstream are reported as if the Interceptor had found
+// them, so the RPC's mode, error details, rejection IDs, and hooks apply to
+// them consistently. Streams are always validated, since a header can't vouch
+// for every message.
+//
+// Clients can set any header, so allow must only trust requests from the
+// validating layer, for example by checking a shared secret or the peer's
+// address. If allow is nil, the header is ignored.
+func WithUpstreamResults(allow func(http.Header, connect.Peer) bool) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.scope.upstreamResults = true
+		i.scope.upstreamAllow = allow
+	})
+}
+
+// upstreamResult returns the trusted result of an earlier validation layer
+// for a unary request, if any.
+func (i *Interceptor) upstreamResult(ctx context.Context, req connect.AnyRequest) (*validatepb.Violations, bool) {
+	if !i.scope.upstreamResults || req.Spec().IsClient {
+		return nil, false
+	}
+	if violations, ok := ctx.Value(upstreamResultKey{}).(*validatepb.Violations); ok {
+		return violations, true
+	}
+	values := req.Header().Values(UpstreamResultHeader)
+	if len(values) != 1 || i.scope.upstreamAllow == nil || !i.scope.upstreamAllow(req.Header(), req.Peer()) {
+		return nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(values[0])
+	if err != nil {
+		return nil, false
+	}
+	violations := &validatepb.Violations{}
+	if err := proto.Unmarshal(data, violations); err != nil {
+		return nil, false
+	}
+	return violations, true
+}
+
+// withUpstreamValidation returns a copy of ctx that makes the validator
+// report the upstream violations instead of validating.
+func withUpstreamValidation(ctx context.Context, violations *validatepb.Violations) context.Context {
+	return context.WithValue(ctx, upstreamValidationKey{}, violations)
+}
+
+type upstreamValidationKey struct{}
+
+func upstreamValidated(ctx context.Context) (*validatepb.Violations, bool) {
+	violations, ok := ctx.Value(upstreamValidationKey{}).(*validatepb.Violations)
+	return violations, ok
+}
+
+// upstreamError returns the error for violations found upstream in msg, or
+// nil if there are none. The violations' fields and values are resolved
+// against msg, so that they're redacted like the validator's own.
+func upstreamError(violations *validatepb.Violations, msg proto.Message) error {
+	if len(violations.GetViolations()) == 0 {
+		return nil
+	}
+	err := &protovalidate.ValidationError{Violations: make([]*Violation, len(violations.GetViolations()))}
+	for n, violation := range violations.GetViolations() {
+		// Violations are modified in place, by redaction for example.
+		violation, _ := proto.Clone(violation).(*validatepb.Violation)
+		field, value := resolveFieldPath(msg.ProtoReflect(), violation.GetField())
+		err.Violations[n] = &Violation{Proto: violation, FieldDescriptor: field, FieldValue: value}
+	}
+	return err
+}
+
+// resolveFieldPath returns the field at the end of path in msg and its value.
+// If the path doesn't resolve, the value is invalid.
+func resolveFieldPath(msg protoreflect.Message, path *validatepb.FieldPath) (protoreflect.FieldDescriptor, protoreflect.Value) {
+	var field protoreflect.FieldDescriptor
+	var value protoreflect.Value
+	for n, element := range path.GetElements() {
+		if n > 0 {
+			nested, ok := value.Interface().(protoreflect.Message)
+			if !value.IsValid() || !ok {
+				return nil, protoreflect.Value{}
+			}
+			msg = nested
+		}
+		field = msg.Descriptor().Fields().ByNumber(protoreflect.FieldNumber(element.GetFieldNumber()))
+		if field == nil {
+			return nil, protoreflect.Value{}
+		}
+		if !msg.Has(field) {
+			return field, protoreflect.Value{}
+		}
+		value = msg.Get(field)
+		switch subscript := element.GetSubscript().(type) {
+		case *validatepb.FieldPathElement_Index:
+			list, ok := value.Interface().(protoreflect.List)
+			if !ok || subscript.Index >= uint64(list.Len()) {
+				return field, protoreflect.Value{}
+			}
+			value = list.Get(int(subscript.Index))
+		case nil:
+		default:
+			mapValue, ok := value.Interface().(protoreflect.Map)
+			key, keyOK := mapKey(field, element)
+			if !ok || !keyOK || !mapValue.Has(key) {
+				return field, protoreflect.Value{}
+			}
+			value = mapValue.Get(key)
+		}
+	}
+	return field, value
+}
+
+// mapKey converts the subscript of element to a key of the map field.
+func mapKey(field protoreflect.FieldDescriptor, element *validatepb.FieldPathElement) (protoreflect.MapKey, bool) {
+	if !field.IsMap() {
+		return protoreflect.MapKey{}, false
+	}
+	switch field.MapKey().Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(element.GetBoolKey()).MapKey(), true
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(element.GetIntKey())).MapKey(), true
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(element.GetIntKey()).MapKey(), true
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(element.GetUintKey())).MapKey(), true
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(element.GetUintKey()).MapKey(), true
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(element.GetStringKey()).MapKey(), true
+	default:
+		return protoreflect.MapKey{}, false
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithUpstreamResults(t *testing.T) {
+	t.Parallel()
+	validator, err := protovalidate.New()
+	require.NoError(t, err)
+	invalidUpstream := validate.EncodeUpstreamResult(validator.Validate(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "someone@example.com", Handle: "admin1"},
+	}))
+	validUpstream := validate.EncodeUpstreamResult(nil)
+	interceptor, err := validate.NewInterceptor(
+		validate.WithUpstreamResults(func(header http.Header, _ connect.Peer) bool {
+			return header.Get("Proxy-Secret") == "hunter2"
+		}),
+	)
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+
+	tests := []struct {
+		name         string
+		email        string
+		handle       string
+		header       map[string]string
+		ctx          func(context.Context) context.Context
+		wantViolated string // constraint ID, or empty if valid
+	}{
+		{
+			name:         "upstream_violations",
+			email:        "someone@example.com",
+			handle:       "admin1",
+			header:       map[string]string{validate.UpstreamResultHeader: invalidUpstream, "Proxy-Secret": "hunter2"},
+			wantViolated: "user.handle",
+		},
+		{
+			name:   "upstream_valid",
+			email:  "foo",
+			header: map[string]string{validate.UpstreamResultHeader: validUpstream, "Proxy-Secret": "hunter2"},
+		},
+		{
+			name:         "untrusted",
+			email:        "foo",
+			header:       map[string]string{validate.UpstreamResultHeader: validUpstream},
+			wantViolated: "string.email",
+		},
+		{
+			name:         "malformed",
+			email:        "foo",
+			header:       map[string]string{validate.UpstreamResultHeader: "!", "Proxy-Secret": "hunter2"},
+			wantViolated: "string.email",
+		},
+		{
+			name:  "context",
+			email: "foo",
+			ctx: func(ctx context.Context) context.Context {
+				return validate.MarkValidatedUpstream(ctx, nil)
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			if test.ctx != nil {
+				ctx = test.ctx(ctx)
+			}
+			req := connect.NewRequest(&userv1.CreateUserRequest{User: &userv1.User{Email: test.email, Handle: test.handle}})
+			for key, value := range test.header {
+				req.Header().Set(key, value)
+			}
+			_, err := call(ctx, req)
+			if test.wantViolated == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			violation := requireSingleViolation(t, err)
+			assert.Equal(t, test.wantViolated, violation.GetConstraintId())
+			// Values of debug_redact fields are masked, like the validator's
+			// own violations.
+			assert.NotContains(t, violation.GetMessage(), "admin1")
+		})
+	}
+}
@@ -23,10 +23,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"connectrpc.com/connect"
 	"github.com/bufbuild/protovalidate-go"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
 )
 
 // An Option configures an [Interceptor].
@@ -39,13 +46,224 @@ type Option interface {
 // of available customizations.
 func WithValidator(validator protovalidate.Validator) Option {
 	return optionFunc(func(i *Interceptor) {
-		i.validator = validator
+		i.validators.validator = validator
 	})
 }
 
-// Interceptor is a [connect.Interceptor] that ensures that RPC request
-// messages match the constraints expressed in their Protobuf schemas. It does
-// not validate response messages.
+// WithValidatorSelector configures the [Interceptor] to choose a validator
+// for each message, so a single Interceptor can serve tenants with different
+// CEL extensions or strictness, selected by headers or context values. If the
+// selector returns nil, the Interceptor uses its own validator, configured
+// with [WithValidator] or the other validator options.
+func WithValidatorSelector(selector func(context.Context, connect.Spec) protovalidate.Validator) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.validators.validatorSelector = selector
+	})
+}
+
+// WithProtovalidateOptions configures the [Interceptor]'s default validator
+// with [protovalidate.ValidatorOption] values, like
+// [protovalidate.WithDisableLazy] and [protovalidate.WithMessages], without
+// constructing a validator with [WithValidator] just to change one setting.
+// Like the other options for the default validator, it can't be combined with
+// WithValidator.
+func WithProtovalidateOptions(opts ...protovalidate.ValidatorOption) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.validators.validatorOptions = append(i.validators.validatorOptions, opts...)
+		i.validators.protovalidateOptions += len(opts)
+	})
+}
+
+// WithExtensionTypeResolver configures the [Interceptor]'s default validator
+// to resolve extensions of the buf.validate rule messages, like predefined
+// rules, with the given resolver rather than [protoregistry.GlobalTypes].
+// Proxies and gateways that validate [dynamicpb] messages with descriptors
+// loaded at runtime use it to find extensions that aren't linked into the
+// binary. Like the other options for the default validator, it can't be
+// combined with [WithValidator].
+//
+// [dynamicpb]: https://pkg.go.dev/google.golang.org/protobuf/types/dynamicpb
+func WithExtensionTypeResolver(resolver protoregistry.ExtensionTypeResolver) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.validators.validatorOptions = append(i.validators.validatorOptions, protovalidate.WithExtensionTypeResolver(resolver))
+		i.validators.extensionTypeResolver = resolver
+	})
+}
+
+// WithLazyInit configures the [Interceptor] to construct its default
+// validator when it first validates a message, rather than in
+// [NewInterceptor]. Constructing the validator is relatively expensive, so
+// lazy construction lets binaries that may never call a validated procedure
+// start faster. If construction fails, every message fails as if its
+// constraints didn't compile: see [WithFailOpen]. Lazy construction has no
+// effect with [WithValidator].
+func WithLazyInit() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.validators.lazyInit = true
+	})
+}
+
+// WithFailFast configures the [Interceptor]'s default validator to stop at the
+// first constraint violation, rather than reporting every violation in the
+// message. It can't be combined with [WithValidator]: to stop at the first
+// violation with a custom validator, construct it with
+// [protovalidate.WithFailFast].
+func WithFailFast() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.validators.validatorOptions = append(i.validators.validatorOptions, protovalidate.WithFailFast())
+		i.validators.failFast = true
+	})
+}
+
+// A Violation is a single constraint violation within a
+// [protovalidate.ValidationError].
+type Violation = protovalidate.Violation
+
+// WithViolationFilter configures the [Interceptor] to report only the
+// violations for which the filter returns true. Dropped violations don't
+// appear in errors, logs, or warnings, so the filter can hide failures that
+// clients shouldn't see, like pattern violations on internal-only fields. If
+// the filter drops every violation in a message, the message is treated as
+// valid.
+func WithViolationFilter(filter func(*Violation) bool) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.violations.violationFilter = filter
+	})
+}
+
+// WithMessageTranslator configures the [Interceptor] to replace the message of
+// each reported violation with the translator's result, before the message is
+// packed into the error. Translators can use the constraint ID and the rule
+// and field values to write their own copy, and the context to choose a
+// locale. If the translator returns an empty string, the violation keeps its
+// original message. Values of fields marked with the debug_redact option are
+// masked after translation.
+func WithMessageTranslator(translator func(context.Context, *Violation) string) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.violations.messageTranslator = translator
+	})
+}
+
+// WithMaxViolations configures the [Interceptor] to report at most n
+// violations in each error, which bounds the size of errors caused by large
+// messages with many invalid elements. Unlike [WithFailFast], the message is
+// still fully validated, so the code mapper configured with [WithCodeMapper]
+// sees every violation. Non-positive values of n don't limit violations.
+func WithMaxViolations(n int) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.violations.maxViolations = n
+	})
+}
+
+// WithSampleRate configures the [Interceptor] to validate only a random
+// fraction of messages, from 0 to 1, trading per-request guarantees for
+// statistical coverage of procedures with expensive constraints. Unsampled
+// messages pass through unvalidated. Sampling is independent for each
+// message, so every procedure is validated at the same rate; to sample by
+// key instead, use [WithSamplingKey]. Rates of 1 or more validate every
+// message, which is the default.
+func WithSampleRate(rate float64) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.scope.sampleRate = &rate
+	})
+}
+
+// WithOnFailure configures the [Interceptor] to call a function whenever it
+// rejects an invalid message, which is convenient for emitting metrics and
+// structured logs. The function sees the rejected message and the violations
+// reported to the client. It isn't called for procedures in [ModeWarn]; see
+// [WithWarnFunc] instead.
+func WithOnFailure(onFailure func(context.Context, connect.Spec, proto.Message, *protovalidate.ValidationError)) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.telemetry.onFailure = onFailure
+	})
+}
+
+// WithObserver configures the [Interceptor] to call a function after every
+// message it validates, whether the message is valid or not, with the time
+// spent validating it. The message type is the Protobuf full name of the
+// message. Messages that aren't validated, because of the [Policy],
+// [WithSkipMessageTypes], or sampling, aren't observed. It's a minimal
+// integration point for telemetry systems.
+func WithObserver(observer func(ctx context.Context, spec connect.Spec, msgType string, valid bool, duration time.Duration)) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.telemetry.observer = observer
+	})
+}
+
+// WithNonProtoFallback configures the [Interceptor] to validate messages that
+// don't implement [proto.Message], like the wrapper types produced by some
+// custom codecs, with a fallback function. By default, such messages fail
+// with an error. If the fallback returns an error, the RPC fails with
+// [connect.CodeInvalidArgument], unless the error is already a
+// [*connect.Error].
+func WithNonProtoFallback(fallback func(context.Context, any) error) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.messages.nonProtoFallback = fallback
+	})
+}
+
+// WithStreamingResponses configures the [Interceptor] to also validate the
+// messages that handlers send on server and bidirectional streams, catching
+// invalid responses before they leave the process. An invalid response isn't
+// sent: Send returns the same error an invalid request would produce, and the
+// handler decides how to end the stream. Only Send is affected; clients and
+// unary handlers are unchanged. To validate every response, use
+// [WithDirection].
+func WithStreamingResponses() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.streamingResponses = true
+	})
+}
+
+// WithSkipNonProtoMessages configures the [Interceptor] to pass messages that
+// don't implement [proto.Message] through without validation.
+func WithSkipNonProtoMessages() Option {
+	return WithNonProtoFallback(func(context.Context, any) error { return nil })
+}
+
+// WithCodeMapper configures the [Interceptor] to choose the [connect.Code] of
+// the error returned for invalid messages. The mapper is called with the RPC's
+// [connect.Spec] and the validation error, so it may vary the code by
+// procedure or by the IDs of the violated constraints. By default, invalid
+// messages produce errors with [connect.CodeInvalidArgument].
+func WithCodeMapper(mapper func(connect.Spec, *protovalidate.ValidationError) connect.Code) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.codeMapper = mapper
+	})
+}
+
+// WithErrorTransformer configures the [Interceptor] to build the error
+// returned for invalid messages itself, taking full control of its code,
+// message, and details. The transformer sees the violations that would be
+// reported, after any limit set by [WithMaxViolations]. If it returns nil, the
+// Interceptor falls back to its default error.
+//
+// Errors built by the transformer are returned as-is, so [WithCodeMapper] has
+// no effect on them. Transformers that want to keep the code the Interceptor
+// would have used can get it from their context with [ErrorCode].
+func WithErrorTransformer(transformer func(context.Context, connect.Spec, *protovalidate.ValidationError) *connect.Error) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.errorTransformer = transformer
+	})
+}
+
+type errorCodeKey struct{}
+
+// ErrorCode returns the code the Interceptor resolved for an invalid message,
+// after [WithCodeMapper] and [ProcedureConfig] overrides. It's only set in the
+// context passed to [WithErrorTransformer].
+func ErrorCode(ctx context.Context) (connect.Code, bool) {
+	code, ok := ctx.Value(errorCodeKey{}).(connect.Code)
+	return code, ok
+}
+
+// Interceptor is a [connect.Interceptor] that ensures that RPC messages match
+// the constraints expressed in their Protobuf schemas. By default, it
+// validates request messages only. To validate responses too, or instead, use
+// [WithDirection]; [WithStreamingResponses] and the ValidateResponses field of
+// [ProcedureConfig] validate the messages handlers send on streams, for every
+// procedure or only for matching ones.
 //
 // By default, Interceptors use a validator that lazily compiles constraints
 // and works with any Protobuf message. This is a simple, widely-applicable
@@ -53,9 +271,20 @@ func WithValidator(validator protovalidate.Validator) Option {
 // message type once, validation is very efficient. To customize the validator,
 // use [WithValidator] and [protovalidate.ValidatorOption].
 //
-// RPCs with invalid request messages short-circuit with an error. The error
-// always uses [connect.CodeInvalidArgument] and has a [detailed representation
-// of the error] attached as a [connect.ErrorDetail].
+// RPCs with invalid request messages short-circuit with an error. By default,
+// the error uses [connect.CodeInvalidArgument] (see [WithCodeMapper]) and has a
+// [detailed representation of the error] attached as a [connect.ErrorDetail].
+// To replace the error entirely, use [WithErrorTransformer]. Values of fields
+// marked with the debug_redact option are masked in violation messages.
+//
+// On streaming RPCs, the Interceptor validates each message after connect has
+// decompressed and unmarshaled it, and before connect marshals and compresses
+// it for sending. Validation never decodes or copies messages, so its cost
+// doesn't depend on the compression in use.
+//
+// By default, every procedure is validated. To skip validation for some
+// procedures, or to report rather than reject invalid messages, use
+// [WithPolicy].
 //
 // This interceptor is primarily intended for use on handlers. Client-side use
 // is possible, but discouraged unless the client always has an up-to-date
@@ -63,7 +292,150 @@ func WithValidator(validator protovalidate.Validator) Option {
 //
 // [detailed representation of the error]: https://pkg.go.dev/buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate#Violations
 type Interceptor struct {
-	validator protovalidate.Validator
+	nop                bool // see NopInterceptor
+	direction          Direction
+	streamingResponses bool
+	policy             Policy
+	procedureConfigs   []procedureConfig
+
+	validators validatorSettings
+	scope      scopeSettings
+	messages   messageSettings
+	violations violationSettings
+	reporting  reportingSettings
+	failures   failureSettings
+	warnings   warningSettings
+	limits     limitSettings
+	streams    streamSettings
+	telemetry  telemetrySettings
+}
+
+// validatorSettings configure the validators and how they're built.
+type validatorSettings struct {
+	validator             protovalidate.Validator
+	validatorOptions      []protovalidate.ValidatorOption
+	protovalidateOptions  int // passed with WithProtovalidateOptions
+	extensionTypeResolver protoregistry.ExtensionTypeResolver
+	customValidator       bool
+	lazyInit              bool
+	swapped               atomic.Pointer[swappedValidator]
+	lazyOnce              sync.Once
+	lazyErr               error // from lazily constructing the default validator
+	warmup                []protoreflect.MessageDescriptor
+	warmupServices        []string
+	validatorSelector     func(context.Context, connect.Spec) protovalidate.Validator
+	failFast              bool
+	failFastDefault       lazyValidator // for procedures configured with FailFast
+	schemaVersions        map[string]protovalidate.Validator
+	recoverPanics         bool
+}
+
+// scopeSettings decide which messages are validated at all.
+type scopeSettings struct {
+	skipProcedures  map[string]struct{}
+	matcher         func(connect.Spec) bool
+	upstreamResults bool
+	upstreamAllow   func(http.Header, connect.Peer) bool
+	contextBypass   bool
+	headerBypass    *headerBypass
+	disableOption   *disableOption
+	skipTypes       map[protoreflect.FullName]struct{}
+	onlyTypes       map[protoreflect.FullName]struct{} // nil unless WithOnlyMessageTypes is used
+	sampleRate      *float64                           // nil validates every message
+	samplingKey     func(context.Context, connect.Spec, any) string
+}
+
+// messageSettings add checks of messages beyond their constraints.
+type messageSettings struct {
+	emptyMessages     EmptyMessagePolicy
+	messageHooks      []messageHook
+	hooksByMessage    map[protoreflect.FullName][]messageHook
+	nonProtoFallback  func(context.Context, any) error
+	fieldMaskResolver FieldMaskResolver
+	maskedValidation  bool
+	maskedResolver    FieldMaskResolver
+}
+
+// violationSettings select, order, and bound the reported violations.
+type violationSettings struct {
+	severities        map[string]Severity
+	violationFilter   func(*Violation) bool
+	messageTranslator func(context.Context, *Violation) string
+	sortViolations    bool
+	maxViolations     int
+	summaryThreshold  int
+	summaryKeep       int
+}
+
+// reportingSettings shape the errors returned for invalid messages.
+type reportingSettings struct {
+	codeMapper           func(connect.Spec, *protovalidate.ValidationError) connect.Code
+	errorTransformer     func(context.Context, connect.Spec, *protovalidate.ValidationError) *connect.Error
+	errorContract        *ErrorContract
+	errorMessage         string
+	withoutDetails       bool
+	withoutRules         bool
+	detailLevel          func(context.Context, connect.Spec) DetailLevel
+	peerDetailLevel      func(connect.Peer) DetailLevel
+	badRequestDetails    bool
+	groupedViolations    bool
+	maxDetailBytes       int
+	failedRulesHeader    bool
+	rejectionIDs         bool
+	rejectionIDGenerator func() string
+	versionHeaders       *versionHeaders
+}
+
+// failureSettings handle validators that fail, rather than report violations.
+type failureSettings struct {
+	failOpen             []string
+	failOpenCompilation  bool
+	validatorErrorCode   connect.Code
+	compilationErrorCode connect.Code
+	runtimeErrorCode     connect.Code
+	validatorErrorFunc   func(context.Context, connect.Spec, error, bool)
+}
+
+// warningSettings report the invalid messages that are let through.
+type warningSettings struct {
+	dryRun           bool
+	warnFunc         func(context.Context, connect.Spec, error)
+	warningTrailers  bool
+	warningConverter func(*protovalidate.ValidationError) proto.Message
+	promotionLead    time.Duration
+	promotionHook    func(context.Context, Promotion)
+	promotions       []promotionState // indexed like policy.rules
+}
+
+// limitSettings bound the resources that validation uses.
+type limitSettings struct {
+	collectionBudget   int
+	maxMessageSize     int
+	oversizePolicy     OversizePolicy
+	validationTimeout  time.Duration
+	timeoutCode        connect.Code
+	procedureLimiter   *procedureLimiter
+	concurrencyLimiter *concurrencyLimiter
+	limitExemptions    []string
+	batchConcurrency   int
+}
+
+// streamSettings check the messages of a stream against each other.
+type streamSettings struct {
+	uniqueFields []*uniqueField
+	invariants   []*streamInvariant
+}
+
+// telemetrySettings record the outcomes of validation.
+type telemetrySettings struct {
+	clock             func() time.Time
+	observer          func(context.Context, connect.Spec, string, bool, time.Duration)
+	onFailure         func(context.Context, connect.Spec, proto.Message, *protovalidate.ValidationError)
+	rollups           *rollupReporter
+	requestLogger     *requestLogger
+	payloadSerializer PayloadSerializer
+	recentRejections  *rejectionRing
+	profiler          *costProfiler
 }
 
 // NewInterceptor builds an Interceptor. The default configuration is
@@ -74,91 +446,631 @@ func NewInterceptor(opts ...Option) (*Interceptor, error) {
 		opt.apply(&interceptor)
 	}
 
-	if interceptor.validator == nil {
-		validator, err := protovalidate.New()
+	if err := interceptor.policy.check(); err != nil {
+		return nil, err
+	}
+	if chain, ok := interceptor.validators.validator.(chainValidator); ok {
+		if err := chain.check(); err != nil {
+			return nil, err
+		}
+	}
+	if interceptor.scope.headerBypass != nil {
+		if err := interceptor.scope.headerBypass.check(); err != nil {
+			return nil, err
+		}
+	}
+	if interceptor.scope.disableOption != nil {
+		if err := interceptor.scope.disableOption.check(); err != nil {
+			return nil, err
+		}
+	}
+	for _, unique := range interceptor.streams.uniqueFields {
+		if err := unique.resolve(); err != nil {
+			return nil, err
+		}
+	}
+	for _, invariant := range interceptor.streams.invariants {
+		if err := invariant.compile(); err != nil {
+			return nil, err
+		}
+	}
+	if interceptor.telemetry.requestLogger != nil {
+		interceptor.telemetry.requestLogger.serializer = interceptor.telemetry.payloadSerializer
+		if interceptor.telemetry.requestLogger.serializer == nil {
+			interceptor.telemetry.requestLogger.serializer = SkeletonPayloads()
+		}
+	}
+	if interceptor.telemetry.rollups != nil {
+		interceptor.telemetry.rollups.now = interceptor.now
+	}
+	if interceptor.warnings.promotionHook != nil {
+		interceptor.warnings.promotions = make([]promotionState, len(interceptor.policy.rules))
+	}
+	if err := interceptor.resolveMessageHooks(); err != nil {
+		return nil, err
+	}
+	if err := interceptor.resolveWarmup(); err != nil {
+		return nil, err
+	}
+	if len(interceptor.validators.warmup) > 0 {
+		interceptor.validators.validatorOptions = append(interceptor.validators.validatorOptions,
+			protovalidate.WithMessageDescriptors(interceptor.validators.warmup...))
+	}
+	interceptor.validators.customValidator = interceptor.validators.validator != nil
+	if err := interceptor.checkProcedureConfigs(); err != nil {
+		return nil, err
+	}
+	if interceptor.validators.customValidator {
+		if len(interceptor.validators.validatorOptions) > 0 {
+			return nil, errors.New("options for the default validator can't be combined with WithValidator")
+		}
+	} else if !interceptor.validators.lazyInit {
+		validator, err := newDefaultValidator(interceptor.validators.validatorOptions)
 		if err != nil {
-			return nil, fmt.Errorf("construct validator: %w", err)
+			return nil, err
 		}
-		interceptor.validator = validator
+		interceptor.validators.validator = validator
 	}
 
 	return &interceptor, nil
 }
 
+// Validator returns the Interceptor's validator: the one set with
+// [Interceptor.SetValidator], the one configured with [WithValidator], or the
+// default validator. Applications can use it to
+// validate messages out of band, for example in background jobs or queue
+// consumers, sharing the constraints the Interceptor has already compiled
+// rather than compiling them again. Validators chosen by the function
+// configured with [WithValidatorSelector] aren't considered.
+//
+// With [WithLazyInit], the first call constructs the default validator, and
+// it returns an error if construction fails.
+func (i *Interceptor) Validator() (protovalidate.Validator, error) {
+	return i.defaultValidator()
+}
+
+// SetValidator replaces the Interceptor's validator while it's in use, so
+// long-running servers can pick up new CEL extensions or descriptors without
+// restarting or rebuilding their handlers. The replacement is atomic: each
+// message is validated entirely by either the old or the new validator. Passing
+// nil restores the validator the Interceptor was constructed with. Validators
+// chosen by the function configured with [WithValidatorSelector] still take
+// precedence.
+func (i *Interceptor) SetValidator(validator protovalidate.Validator) {
+	if validator == nil {
+		i.validators.swapped.Store(nil)
+		return
+	}
+	i.validators.swapped.Store(&swappedValidator{validator: validator})
+}
+
+// swappedValidator holds a validator set with SetValidator.
+type swappedValidator struct {
+	validator protovalidate.Validator
+}
+
 // WrapUnary implements connect.Interceptor.
 func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	if i.nop {
+		return next
+	}
+	if i.reporting.errorContract != nil {
+		next = i.wrapUnaryContract(next)
+	}
 	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
-		if err := validate(i.validator, req.Any()); err != nil {
-			return nil, err
+		spec := req.Spec()
+		if i.scope.headerBypass != nil && i.scope.headerBypass.bypassed(spec, req.Header(), req.Peer()) {
+			return next(ctx, req)
+		}
+		skipRequest := !i.direction.requests() || i.procedureConfig(spec.Procedure).SkipRequests
+		if skipRequest && !i.direction.responses() {
+			return next(ctx, req)
+		}
+		ctx = i.withWarnings(ctx, spec)
+		ctx = i.withSchemaVersion(ctx, spec, req.Header)
+		ctx = i.withPeer(ctx, spec, req.Peer())
+		if !skipRequest {
+			validateCtx := ctx
+			if violations, ok := i.upstreamResult(ctx, req); ok {
+				validateCtx = withUpstreamValidation(ctx, violations)
+			}
+			if err := i.validate(validateCtx, spec, req.Any(), nil); err != nil {
+				return nil, err
+			}
+		}
+		if !i.direction.responses() && (!i.warnings.warningTrailers || spec.IsClient) {
+			return next(ctx, req)
+		}
+		res, err := next(ctx, req)
+		if err == nil && res != nil && i.direction.responses() {
+			if err = i.validate(ctx, spec, res.Any(), nil); err != nil {
+				res = nil
+			}
+		}
+		if !i.warnings.warningTrailers || spec.IsClient {
+			return res, err
 		}
-		return next(ctx, req)
+		var connectErr *connect.Error
+		if errors.As(err, &connectErr) {
+			writeWarningTrailers(ctx, connectErr.Meta())
+		} else if err == nil && res != nil {
+			writeWarningTrailers(ctx, res.Trailer())
+		}
+		return res, err
 	}
 }
 
 // WrapStreamingClient implements connect.Interceptor.
 func (i *Interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	if i.nop {
+		return next
+	}
 	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
-		return &streamingClientInterceptor{
-			StreamingClientConn: next(ctx, spec),
-			validator:           i.validator,
+		conn := next(ctx, spec)
+		skipRequests := !i.direction.requests() || i.procedureConfig(spec.Procedure).SkipRequests
+		if i.mode(ctx, spec) == ModeSkip || (skipRequests && !i.direction.responses()) {
+			return conn
+		}
+		wrapped := &streamingClientInterceptor{StreamingClientConn: conn}
+		if !skipRequests {
+			stream := i.newStreamState()
+			wrapped.validate = func(msg any) error {
+				return i.validateStreamMessage(ctx, spec, msg, stream)
+			}
 		}
+		if i.direction.responses() {
+			wrapped.validateReceive = func(msg any) error {
+				return i.validateStreamMessage(ctx, spec, msg, nil)
+			}
+		}
+		return wrapped
 	}
 }
 
 // WrapStreamingHandler implements connect.Interceptor.
 func (i *Interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	if i.nop {
+		return next
+	}
+	if i.reporting.errorContract != nil {
+		next = i.wrapStreamingContract(next)
+	}
 	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
-		return next(ctx, &streamingHandlerInterceptor{
+		spec := conn.Spec()
+		if i.mode(ctx, spec) == ModeSkip || (i.scope.headerBypass != nil && i.scope.headerBypass.bypassed(spec, conn.RequestHeader(), conn.Peer())) {
+			return next(ctx, conn)
+		}
+		config := i.procedureConfig(spec.Procedure)
+		skipRequests := !i.direction.requests() || config.SkipRequests
+		validateResponses := i.direction.responses() || i.streamingResponses || config.ValidateResponses
+		if skipRequests && !validateResponses {
+			return next(ctx, conn)
+		}
+		ctx = i.withWarnings(ctx, spec)
+		ctx = i.withSchemaVersion(ctx, spec, conn.RequestHeader)
+		ctx = i.withPeer(ctx, spec, conn.Peer())
+		stream := i.newStreamState()
+		wrapped := &streamingHandlerInterceptor{
 			StreamingHandlerConn: conn,
-			validator:            i.validator,
-		})
+			validate: func(msg any) error {
+				if skipRequests {
+					return nil
+				}
+				return i.validateStreamMessage(ctx, spec, msg, stream)
+			},
+		}
+		if validateResponses {
+			// Responses aren't part of the stream's requests, so they're
+			// checked without its uniqueness and invariant state.
+			wrapped.validateSend = func(msg any) error {
+				return i.validateStreamMessage(ctx, spec, msg, nil)
+			}
+		}
+		if i.warnings.warningTrailers {
+			defer writeWarningTrailers(ctx, conn.ResponseTrailer())
+		}
+		if err := next(ctx, wrapped); err != nil {
+			return err
+		}
+		// Handlers often stop receiving at the first error without returning
+		// it, especially when it arrives with the last message before the
+		// client half-closes. Report the violation anyway, so the client
+		// learns why its message was rejected.
+		return wrapped.err
 	}
 }
 
 type streamingClientInterceptor struct {
 	connect.StreamingClientConn
 
-	validator protovalidate.Validator
+	validate        func(any) error // nil unless requests are validated
+	validateReceive func(any) error // nil unless responses are validated
 }
 
 func (s *streamingClientInterceptor) Send(msg any) error {
-	if err := validate(s.validator, msg); err != nil {
-		return err
+	if s.validate != nil {
+		if err := s.validate(msg); err != nil {
+			return err
+		}
 	}
 	return s.StreamingClientConn.Send(msg)
 }
 
+func (s *streamingClientInterceptor) Receive(msg any) error {
+	if err := s.StreamingClientConn.Receive(msg); err != nil {
+		return err
+	}
+	if s.validateReceive != nil {
+		return s.validateReceive(msg)
+	}
+	return nil
+}
+
 type streamingHandlerInterceptor struct {
 	connect.StreamingHandlerConn
 
-	validator protovalidate.Validator
+	validate     func(any) error
+	validateSend func(any) error // nil unless responses are validated
+	err          error           // first validation error, if any
 }
 
 func (s *streamingHandlerInterceptor) Receive(msg any) error {
 	if err := s.StreamingHandlerConn.Receive(msg); err != nil {
 		return err
 	}
-	return validate(s.validator, msg)
+	err := s.validate(msg)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	return err
+}
+
+func (s *streamingHandlerInterceptor) Send(msg any) error {
+	if s.validateSend != nil {
+		if err := s.validateSend(msg); err != nil {
+			return err
+		}
+	}
+	return s.StreamingHandlerConn.Send(msg)
 }
 
 type optionFunc func(*Interceptor)
 
 func (f optionFunc) apply(i *Interceptor) { f(i) }
 
-func validate(validator protovalidate.Validator, msg any) error {
+// validate validates a message, applying the procedure's mode. For streaming
+// RPCs, stream tracks the messages on the stream; it's nil for unary RPCs and
+// when no checks need it.
+func (i *Interceptor) validate(ctx context.Context, spec connect.Spec, msg any, stream *streamState) error {
+	if i.nop {
+		return nil
+	}
+	mode := i.mode(ctx, spec)
+	if mode == ModeSkip {
+		return nil
+	}
+	if i.scope.sampleRate != nil && !i.sample(ctx, spec, msg, *i.scope.sampleRate) {
+		return nil
+	}
+	if i.skipsType(msg) {
+		return nil
+	}
+	protoMsg, isProto := msg.(proto.Message)
+	exempt := i.limitExempt(spec.Procedure)
+	if i.limits.maxMessageSize > 0 && isProto && !exempt {
+		if ok, err := i.checkSize(mode, protoMsg); !ok {
+			return err
+		}
+	}
+	slots := &heldSlots{}
+	defer slots.release()
+	if i.limits.procedureLimiter != nil && !exempt {
+		semaphore := i.limits.procedureLimiter.semaphore(spec.Procedure)
+		release, ok, err := reserve(ctx, mode, semaphore, i.limits.procedureLimiter.overflow, "for "+spec.Procedure)
+		if !ok {
+			return err
+		}
+		slots.releases = append(slots.releases, release)
+	}
+	if i.limits.concurrencyLimiter != nil && !exempt {
+		release, ok, err := reserve(ctx, mode, i.limits.concurrencyLimiter.slots, i.limits.concurrencyLimiter.overflow, "in total")
+		if !ok {
+			return err
+		}
+		slots.releases = append(slots.releases, release)
+	}
+	if len(slots.releases) > 0 && (i.limits.validationTimeout > 0 || interruptible(ctx)) {
+		ctx = context.WithValue(ctx, heldSlotsKey{}, slots)
+	}
+	var start time.Time
+	if i.telemetry.observer != nil {
+		start = time.Now()
+	}
+	ctx, validationErr, err := i.check(ctx, spec, msg, stream)
+	if i.telemetry.observer != nil {
+		i.telemetry.observer(ctx, spec, messageType(msg), err == nil, time.Since(start))
+	}
+	if i.telemetry.rollups != nil {
+		i.telemetry.rollups.record(spec.Procedure, err, rolledUpViolations(ctx), mode == ModeWarn)
+	}
+	if isProto && err != nil && i.telemetry.requestLogger != nil && i.sample(ctx, spec, msg, i.telemetry.requestLogger.sampleRate) {
+		i.telemetry.requestLogger.log(ctx, spec, protoMsg, validationErr)
+	}
+	if err != nil && mode == ModeWarn {
+		i.warn(ctx, spec, validationErr, err)
+		return nil
+	}
+	if validationErr != nil && i.telemetry.recentRejections != nil {
+		i.rememberRejection(ctx, spec, msg, validationErr, err)
+	}
+	if validationErr != nil && i.telemetry.onFailure != nil {
+		i.telemetry.onFailure(ctx, spec, protoMsg, validationErr)
+	}
+	return err
+}
+
+// skipsType reports whether msg is passed through because of its type, as
+// configured with WithSkipMessageTypes and WithOnlyMessageTypes.
+func (i *Interceptor) skipsType(msg any) bool {
 	protoMsg, ok := msg.(proto.Message)
 	if !ok {
+		return i.scope.onlyTypes != nil
+	}
+	name := protoMsg.ProtoReflect().Descriptor().FullName()
+	if _, ok := i.scope.skipTypes[name]; ok {
+		return true
+	}
+	_, ok = i.scope.onlyTypes[name]
+	return i.scope.onlyTypes != nil && !ok
+}
+
+// warn reports an invalid message that's let through, with the violations and
+// the error the Interceptor would have returned.
+func (i *Interceptor) warn(ctx context.Context, spec connect.Spec, validationErr *protovalidate.ValidationError, err error) {
+	if i.warnings.warnFunc != nil {
+		i.warnings.warnFunc(ctx, spec, err)
+	}
+	if i.warnings.warningConverter != nil || i.warnings.warningTrailers {
+		i.addWarning(ctx, spec, validationErr)
+	}
+}
+
+// check validates msg and maps failures to a connect error. The returned
+// context carries the rejection ID, if one was assigned, and the returned
+// ValidationError holds the violations reported to the client, if any.
+func (i *Interceptor) check(ctx context.Context, spec connect.Spec, msg any, stream *streamState) (context.Context, *protovalidate.ValidationError, error) {
+	protoMsg, ok := msg.(proto.Message)
+	if !ok {
+		return ctx, nil, i.checkNonProto(ctx, msg)
+	}
+	err := i.runValidatorRecovering(ctx, spec, protoMsg)
+	if stream != nil {
+		err = stream.appendViolations(err, protoMsg)
+	}
+	if err == nil {
+		return ctx, nil, nil
+	}
+	if connectErr := new(connect.Error); errors.As(err, &connectErr) {
+		return ctx, nil, err
+	}
+	validationErr := new(protovalidate.ValidationError)
+	if !errors.As(err, &validationErr) {
+		return ctx, nil, i.validatorFailure(ctx, spec, err)
+	}
+	if i.violations.violationFilter != nil {
+		validationErr = filterViolations(validationErr, i.violations.violationFilter)
+		if validationErr == nil {
+			return ctx, nil, nil
+		}
+	}
+	if len(i.violations.severities) > 0 {
+		var warned *protovalidate.ValidationError
+		validationErr, warned = splitSeverities(validationErr, i.violations.severities)
+		if warned != nil {
+			i.warnSeverity(ctx, spec, protoMsg, warned)
+		}
+		if validationErr == nil {
+			return ctx, nil, nil
+		}
+	}
+	return i.reportViolations(ctx, spec, protoMsg, validationErr, true)
+}
+
+// reportViolations prepares the violations of an invalid message for
+// reporting, and maps them to a connect error. Unless reject is false, as for
+// violations that are only warned about, a rejection ID is assigned if
+// configured, and the returned context carries it.
+func (i *Interceptor) reportViolations(ctx context.Context, spec connect.Spec, protoMsg proto.Message, validationErr *protovalidate.ValidationError, reject bool) (context.Context, *protovalidate.ValidationError, error) {
+	if i.violations.sortViolations {
+		sortViolations(validationErr)
+	}
+	if i.violations.messageTranslator != nil {
+		translateViolations(ctx, validationErr, i.violations.messageTranslator)
+	}
+	redactViolations(protoMsg.ProtoReflect().Descriptor(), validationErr)
+	var rejectionID string
+	if i.reporting.rejectionIDs && reject {
+		rejectionID = i.newRejectionID()
+		ctx = withRejectionID(ctx, rejectionID)
+	}
+	code := connect.CodeInvalidArgument
+	if i.reporting.codeMapper != nil {
+		code = i.reporting.codeMapper(spec, validationErr)
+	}
+	if override := i.procedureConfig(spec.Procedure).Code; override != 0 {
+		code = override
+	}
+	if i.telemetry.rollups != nil && reject {
+		ctx = withRolledUpViolations(ctx, validationErr)
+	}
+	var summary *violationSummary
+	if i.violations.summaryThreshold > 0 && len(validationErr.Violations) > i.violations.summaryThreshold {
+		summary = summarize(validationErr)
+		validationErr = &protovalidate.ValidationError{
+			Violations: validationErr.Violations[:min(i.violations.summaryKeep, len(validationErr.Violations))],
+		}
+	}
+	if i.violations.maxViolations > 0 && len(validationErr.Violations) > i.violations.maxViolations {
+		validationErr = &protovalidate.ValidationError{
+			Violations: validationErr.Violations[:i.violations.maxViolations],
+		}
+	}
+	var connectErr *connect.Error
+	if i.reporting.errorTransformer != nil {
+		connectErr = i.reporting.errorTransformer(context.WithValue(ctx, errorCodeKey{}, code), spec, validationErr)
+	}
+	if connectErr == nil {
+		connectErr = i.newError(ctx, spec, code, validationErr, summary)
+	}
+	if rejectionID != "" {
+		connectErr.Meta().Set(RejectionIDHeader, rejectionID)
+	}
+	if i.reporting.versionHeaders != nil {
+		connectErr.Meta().Set(ValidatorHeader, i.reporting.versionHeaders.validator)
+		if i.reporting.versionHeaders.schemaVersion != "" {
+			connectErr.Meta().Set(SchemaVersionHeader, i.reporting.versionHeaders.schemaVersion)
+		}
+	}
+	if version, ok := negotiatedSchemaVersion(ctx); ok {
+		connectErr.Meta().Set(SchemaVersionHeader, version)
+	}
+	return ctx, validationErr, connectErr
+}
+
+func newDefaultValidator(opts []protovalidate.ValidatorOption) (protovalidate.Validator, error) {
+	validator, err := protovalidate.New(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("construct validator: %w", err)
+	}
+	return validator, nil
+}
+
+// defaultValidator returns the validator set with SetValidator, if any, and
+// otherwise the validator configured with WithValidator or the default
+// validator, constructing the latter if it's built lazily.
+func (i *Interceptor) defaultValidator() (protovalidate.Validator, error) {
+	if swapped := i.validators.swapped.Load(); swapped != nil {
+		return swapped.validator, nil
+	}
+	if !i.validators.lazyInit || i.validators.customValidator {
+		return i.validators.validator, nil
+	}
+	i.validators.lazyOnce.Do(func() {
+		i.validators.validator, i.validators.lazyErr = newDefaultValidator(i.validators.validatorOptions)
+	})
+	return i.validators.validator, i.validators.lazyErr
+}
+
+// runValidator validates msg, without mapping the result to a connect error.
+func (i *Interceptor) runValidator(ctx context.Context, spec connect.Spec, msg proto.Message) error {
+	if violations, ok := upstreamValidated(ctx); ok {
+		return upstreamError(violations, msg)
+	}
+	if i.limits.collectionBudget > 0 && !i.limitExempt(spec.Procedure) {
+		if violation := checkCollectionBudget(msg.ProtoReflect(), i.limits.collectionBudget); violation != nil {
+			return &protovalidate.ValidationError{Violations: []*Violation{violation}}
+		}
+	}
+	empty := i.messages.emptyMessages != EmptyValidate && isEmptyMessage(msg)
+	if empty && i.messages.emptyMessages == EmptyReject {
+		return emptyMessageError()
+	}
+	profile := i.telemetry.profiler != nil && i.sample(ctx, spec, msg, i.telemetry.profiler.sampleRate)
+	var start time.Time
+	if profile {
+		start = time.Now()
+	}
+	var validator protovalidate.Validator
+	if i.validators.validatorSelector != nil {
+		validator = i.validators.validatorSelector(ctx, spec)
+	}
+	if version, ok := negotiatedSchemaVersion(ctx); ok && validator == nil {
+		validator = i.validators.schemaVersions[version]
+	}
+	if validator == nil {
+		var err error
+		if i.procedureConfig(spec.Procedure).FailFast {
+			validator, err = i.failFastValidator()
+		} else {
+			validator, err = i.defaultValidator()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	var err error
+	if timeout := i.limits.validationTimeout > 0 && !i.limitExempt(spec.Procedure); timeout || interruptible(ctx) {
+		err = i.validateWithTimeout(ctx, validator, msg, timeout)
+	} else {
+		err = validator.Validate(msg)
+	}
+	if profile {
+		i.telemetry.profiler.record(msg.ProtoReflect().Descriptor().FullName(), time.Since(start))
+	}
+	if i.messages.maskedValidation {
+		resolver := i.messages.maskedResolver
+		if resolver == nil {
+			resolver = i.messages.fieldMaskResolver
+		}
+		if resolver == nil {
+			resolver = resolveFieldMaskTarget
+		}
+		err = dropUnmaskedViolations(err, msg, resolver)
+	}
+	if i.messages.fieldMaskResolver != nil {
+		err = appendFieldMaskViolations(err, msg, i.messages.fieldMaskResolver)
+	}
+	err = i.runMessageHooks(ctx, msg, err)
+	if empty {
+		return collapseEmptyMessage(err)
+	}
+	return err
+}
+
+func (i *Interceptor) checkNonProto(ctx context.Context, msg any) error {
+	if i.messages.nonProtoFallback == nil {
 		return fmt.Errorf("expected proto.Message, got %T", msg)
 	}
-	err := validator.Validate(protoMsg)
+	err := i.messages.nonProtoFallback(ctx, msg)
 	if err == nil {
 		return nil
 	}
-	connectErr := connect.NewError(connect.CodeInvalidArgument, err)
-	if validationErr := new(protovalidate.ValidationError); errors.As(err, &validationErr) {
-		if detail, err := connect.NewErrorDetail(validationErr.ToProto()); err == nil {
-			connectErr.AddDetail(detail)
+	if connectErr := new(connect.Error); errors.As(err, &connectErr) {
+		return err
+	}
+	return connect.NewError(connect.CodeInvalidArgument, err)
+}
+
+// messageType names the type of a message, preferring its Protobuf name.
+func messageType(msg any) string {
+	if protoMsg, ok := msg.(proto.Message); ok {
+		return string(protoMsg.ProtoReflect().Descriptor().FullName())
+	}
+	return fmt.Sprintf("%T", msg)
+}
+
+// sampled reports whether an event should be sampled at the given rate.
+func sampled(rate float64) bool {
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+}
+
+func translateViolations(ctx context.Context, err *protovalidate.ValidationError, translate func(context.Context, *Violation) string) {
+	for _, violation := range err.Violations {
+		if message := translate(ctx, violation); message != "" && violation.Proto != nil {
+			violation.Proto.Message = proto.String(message)
+		}
+	}
+}
+
+func filterViolations(err *protovalidate.ValidationError, keep func(*Violation) bool) *protovalidate.ValidationError {
+	violations := make([]*Violation, 0, len(err.Violations))
+	for _, violation := range err.Violations {
+		if keep(violation) {
+			violations = append(violations, violation)
 		}
 	}
-	return connectErr
+	if len(violations) == 0 {
+		return nil
+	}
+	return &protovalidate.ValidationError{Violations: violations}
 }
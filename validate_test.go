@@ -17,9 +17,12 @@ package validate_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -33,6 +36,9 @@ import (
 	"github.com/bufbuild/protovalidate-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func TestInterceptorUnary(t *testing.T) {
@@ -312,7 +318,726 @@ func TestWithValidator(t *testing.T) {
 	_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
 		CreateUser(context.Background(), req)
 	require.Error(t, err)
-	require.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	// Without lazy loading, the validator can't compile the constraints of
+	// unknown messages.
+	require.Equal(t, connect.CodeInternal, connect.CodeOf(err))
+}
+
+func TestWithValidatorSelector(t *testing.T) {
+	t.Parallel()
+	type tenantKey struct{}
+	interceptor, err := validate.NewInterceptor(
+		validate.WithValidatorSelector(func(ctx context.Context, _ connect.Spec) protovalidate.Validator {
+			if ctx.Value(tenantKey{}) == "legacy" {
+				return failingValidator{}
+			}
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	req := connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	})
+
+	_, err = interceptor.WrapUnary(next)(context.Background(), req)
+	require.Error(t, err)
+	assert.Equal(t, "string.email", requireSingleViolation(t, err).GetConstraintId())
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "legacy")
+	_, err = interceptor.WrapUnary(next)(ctx, req)
+	require.Error(t, err)
+	assert.ErrorAs(t, err, new(*protovalidate.CompilationError))
+}
+
+func TestWithProtovalidateOptions(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(
+		validate.WithProtovalidateOptions(
+			protovalidate.WithDisableLazy(),
+			protovalidate.WithMessages(&userv1.CreateUserRequest{}),
+		),
+	)
+	require.NoError(t, err)
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	invalid := &userv1.User{Email: "foo"}
+	_, err = interceptor.WrapUnary(next)(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: invalid}))
+	assert.Equal(t, "string.email", requireSingleViolation(t, err).GetConstraintId())
+	// With lazy compilation disabled, unlisted messages can't be validated.
+	_, err = interceptor.WrapUnary(next)(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{User: invalid}))
+	require.Error(t, err)
+	assert.ErrorAs(t, err, new(*protovalidate.CompilationError))
+
+	validator, err := protovalidate.New()
+	require.NoError(t, err)
+	_, err = validate.NewInterceptor(
+		validate.WithValidator(validator),
+		validate.WithProtovalidateOptions(protovalidate.WithDisableLazy()),
+	)
+	require.Error(t, err)
+}
+
+func TestWithExtensionTypeResolver(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithExtensionTypeResolver(new(protoregistry.Types)))
+	require.NoError(t, err)
+	assert.Contains(t, interceptor.EffectiveConfig().Hooks, "extension_type_resolver")
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	// Standard rules don't need the resolver.
+	_, err = interceptor.WrapUnary(next)(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	}))
+	assert.Equal(t, "string.email", requireSingleViolation(t, err).GetConstraintId())
+
+	validator, err := protovalidate.New()
+	require.NoError(t, err)
+	_, err = validate.NewInterceptor(
+		validate.WithValidator(validator),
+		validate.WithExtensionTypeResolver(protoregistry.GlobalTypes),
+	)
+	require.Error(t, err)
+}
+
+func TestWithLazyInit(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithLazyInit(), validate.WithFailFast())
+	require.NoError(t, err)
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	req := connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{
+			Email:      "foo",
+			BirthDate:  timestamppb.New(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+			SignupDate: timestamppb.New(time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)),
+		},
+	})
+	// Concurrent first uses share one validator, configured with the other
+	// options.
+	errs := make(chan error, 4)
+	for n := 0; n < cap(errs); n++ {
+		go func() {
+			_, err := interceptor.WrapUnary(next)(context.Background(), req)
+			errs <- err
+		}()
+	}
+	for n := 0; n < cap(errs); n++ {
+		err := <-errs
+		require.Error(t, err)
+		assert.Len(t, violationsOf(t, err), 1)
+	}
+}
+
+func TestWithWarmup(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(
+		validate.WithWarmupMessages(&userv1.CreateUserRequest{}),
+		validate.WithWarmupServices("example.calculator.v1.CalculatorService"),
+	)
+	require.NoError(t, err)
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	_, err = interceptor.WrapUnary(next)(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	}))
+	assert.Equal(t, "string.email", requireSingleViolation(t, err).GetConstraintId())
+
+	_, err = validate.NewInterceptor(validate.WithWarmupServices("example.calculator.v1.NoSuchService"))
+	require.Error(t, err)
+	_, err = validate.NewInterceptor(validate.WithWarmupServices("example.calculator.v1.CumSumRequest"))
+	require.Error(t, err)
+	validator, err := protovalidate.New()
+	require.NoError(t, err)
+	_, err = validate.NewInterceptor(
+		validate.WithValidator(validator),
+		validate.WithWarmupMessages(&userv1.CreateUserRequest{}),
+	)
+	require.Error(t, err)
+}
+
+func TestWithFailFast(t *testing.T) {
+	t.Parallel()
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	req := connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{
+			Email:     "foo",
+			BirthDate: timestamppb.New(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+		},
+	})
+
+	interceptor, err := validate.NewInterceptor()
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(next)(context.Background(), req)
+	require.Error(t, err)
+	assert.Len(t, violationsOf(t, err), 2)
+
+	interceptor, err = validate.NewInterceptor(validate.WithFailFast())
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(next)(context.Background(), req)
+	require.Error(t, err)
+	assert.Len(t, violationsOf(t, err), 1)
+
+	validator, err := protovalidate.New()
+	require.NoError(t, err)
+	_, err = validate.NewInterceptor(validate.WithValidator(validator), validate.WithFailFast())
+	require.Error(t, err)
+}
+
+func TestWithMaxViolations(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithMaxViolations(1))
+	require.NoError(t, err)
+	_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{
+			Email:     "foo",
+			BirthDate: timestamppb.New(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+		},
+	}))
+	require.Error(t, err)
+	violation := requireSingleViolation(t, err)
+	assert.Contains(t, err.Error(), violation.GetConstraintId())
+	assert.Equal(t, 1, strings.Count(err.Error(), "\n - "))
+}
+
+func TestWithViolationFilter(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithViolationFilter(
+		func(violation *validate.Violation) bool {
+			return violation.Proto.GetConstraintId() != "string.email"
+		},
+	))
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+
+	// The message violates two constraints, and only the email violation is
+	// filtered out.
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{
+			Email:     "foo",
+			BirthDate: timestamppb.New(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+		},
+	}))
+	require.Error(t, err)
+	violation := requireSingleViolation(t, err)
+	assert.Equal(t, "user.signup_date", violation.GetConstraintId())
+
+	// When every violation is filtered out, the message is valid.
+	_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	}))
+	assert.NoError(t, err)
+}
+
+func TestWithMessageTranslator(t *testing.T) {
+	t.Parallel()
+	type localeKey struct{}
+	interceptor, err := validate.NewInterceptor(validate.WithMessageTranslator(
+		func(ctx context.Context, violation *validate.Violation) string {
+			if ctx.Value(localeKey{}) != "fr" {
+				return ""
+			}
+			switch violation.Proto.GetConstraintId() {
+			case "string.email":
+				return "adresse e-mail invalide"
+			case "user.handle":
+				return "le pseudo " + violation.FieldValue.String() + " est réservé"
+			}
+			return ""
+		},
+	))
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+	req := connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo", Handle: "admin-bob"},
+	})
+
+	_, err = call(context.WithValue(context.Background(), localeKey{}, "fr"), req)
+	require.Error(t, err)
+	violations := violationsOf(t, err)
+	require.Len(t, violations, 2)
+	assert.Equal(t, "adresse e-mail invalide", violations[0].GetMessage())
+	// Translations are redacted too.
+	assert.Equal(t, "le pseudo [REDACTED] est réservé", violations[1].GetMessage())
+
+	_, err = call(context.Background(), req)
+	require.Error(t, err)
+	violations = violationsOf(t, err)
+	require.Len(t, violations, 2)
+	assert.NotEqual(t, "adresse e-mail invalide", violations[0].GetMessage())
+}
+
+func TestWithSampleRate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		rate     float64
+		wantCode connect.Code
+	}{
+		{name: "never", rate: 0},
+		{name: "always", rate: 1, wantCode: connect.CodeInvalidArgument},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithSampleRate(test.rate))
+			require.NoError(t, err)
+			call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+			})
+			for i := 0; i < 10; i++ {
+				_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+					User: &userv1.User{Email: "foo"},
+				}))
+				if test.wantCode > 0 {
+					assert.Equal(t, test.wantCode, connect.CodeOf(err))
+				} else {
+					assert.NoError(t, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithSamplingKey(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(
+		validate.WithSampleRate(0.5),
+		validate.WithSamplingKey(func(_ context.Context, _ connect.Spec, msg any) string {
+			req, _ := msg.(*userv1.CreateUserRequest)
+			return req.GetUser().GetHandle()
+		}),
+	)
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+	var validated int
+	for n := 0; n < 100; n++ {
+		handle := fmt.Sprintf("user%d", n)
+		_, first := call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+			User: &userv1.User{Email: "foo", Handle: handle},
+		}))
+		// Every message with the same key gets the same decision.
+		for i := 0; i < 5; i++ {
+			_, err := call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+				User: &userv1.User{Email: "foo", Handle: handle},
+			}))
+			assert.Equal(t, connect.CodeOf(first), connect.CodeOf(err))
+		}
+		if first != nil {
+			validated++
+		}
+	}
+	assert.Greater(t, validated, 0)
+	assert.Less(t, validated, 100)
+}
+
+func TestInterceptorValidator(t *testing.T) {
+	t.Parallel()
+	custom, err := protovalidate.New()
+	require.NoError(t, err)
+	tests := []struct {
+		name string
+		opts []validate.Option
+		want protovalidate.Validator
+	}{
+		{name: "default"},
+		{name: "lazy", opts: []validate.Option{validate.WithLazyInit()}},
+		{name: "custom", opts: []validate.Option{validate.WithValidator(custom)}, want: custom},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(test.opts...)
+			require.NoError(t, err)
+			validator, err := interceptor.Validator()
+			require.NoError(t, err)
+			require.NotNil(t, validator)
+			if test.want != nil {
+				assert.Same(t, test.want, validator)
+			}
+			again, err := interceptor.Validator()
+			require.NoError(t, err)
+			assert.Same(t, validator, again)
+			err = validator.Validate(&userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}})
+			assert.ErrorAs(t, err, new(*protovalidate.ValidationError))
+		})
+	}
+}
+
+func TestSetValidator(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor()
+	require.NoError(t, err)
+	original, err := interceptor.Validator()
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+	valid := connect.NewRequest(&userv1.CreateUserRequest{User: &userv1.User{Email: "someone@example.com"}})
+	_, err = call(context.Background(), valid)
+	require.NoError(t, err)
+
+	replacement := staticValidator{err: &protovalidate.ValidationError{
+		Violations: []*validate.Violation{newViolation("string.email")},
+	}}
+	interceptor.SetValidator(replacement)
+	got, err := interceptor.Validator()
+	require.NoError(t, err)
+	assert.Equal(t, replacement, got)
+	_, err = call(context.Background(), valid)
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+
+	interceptor.SetValidator(nil)
+	got, err = interceptor.Validator()
+	require.NoError(t, err)
+	assert.Same(t, original, got)
+	_, err = call(context.Background(), valid)
+	require.NoError(t, err)
+}
+
+func TestSetValidatorConcurrently(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor()
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+	replacement, err := protovalidate.New()
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for n := 0; n < 4; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				_, err := call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+					User: &userv1.User{Email: "foo"},
+				}))
+				assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if i%2 == 0 {
+			interceptor.SetValidator(replacement)
+		} else {
+			interceptor.SetValidator(nil)
+		}
+	}
+	wg.Wait()
+}
+
+func TestWithOnFailure(t *testing.T) {
+	t.Parallel()
+	type failure struct {
+		procedure string
+		msg       proto.Message
+		err       *protovalidate.ValidationError
+	}
+	failures := make(chan failure, 2)
+	interceptor, err := validate.NewInterceptor(
+		validate.WithPolicy(validate.Policy{}.Warn(userv1connect.UserServiceUpdateUserProcedure)),
+		validate.WithOnFailure(func(_ context.Context, spec connect.Spec, msg proto.Message, err *protovalidate.ValidationError) {
+			failures <- failure{procedure: spec.Procedure, msg: msg, err: err}
+		}),
+		// Hooks see the violations even when the error doesn't carry them.
+		validate.WithoutErrorDetails(),
+	)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	mux.Handle(userv1connect.UserServiceUpdateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceUpdateUserProcedure,
+		updateUser,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(t, mux)
+	client := userv1connect.NewUserServiceClient(srv.Client(), srv.URL)
+
+	invalid := &userv1.User{Email: "foo"}
+	_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{User: invalid}))
+	assert.Equal(t, connect.CodeInvalidArgument, connect.CodeOf(err))
+	// Warnings aren't failures.
+	_, err = client.UpdateUser(context.Background(), connect.NewRequest(&userv1.UpdateUserRequest{User: invalid}))
+	require.NoError(t, err)
+	_, err = client.CreateUser(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "someone@example.com"},
+	}))
+	require.NoError(t, err)
+
+	require.Len(t, failures, 1)
+	got := <-failures
+	assert.Equal(t, userv1connect.UserServiceCreateUserProcedure, got.procedure)
+	assert.True(t, proto.Equal(&userv1.CreateUserRequest{User: invalid}, got.msg))
+	require.Len(t, got.err.Violations, 1)
+	assert.Equal(t, "string.email", got.err.Violations[0].Proto.GetConstraintId())
+}
+
+func TestWithObserver(t *testing.T) {
+	t.Parallel()
+	type observation struct {
+		msgType string
+		valid   bool
+	}
+	var observations []observation
+	interceptor, err := validate.NewInterceptor(
+		validate.WithObserver(func(_ context.Context, _ connect.Spec, msgType string, valid bool, duration time.Duration) {
+			assert.Positive(t, duration)
+			observations = append(observations, observation{msgType: msgType, valid: valid})
+		}),
+		validate.WithSkipMessageTypes("example.user.v1.UpdateUserRequest"),
+	)
+	require.NoError(t, err)
+	call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	})
+
+	for _, req := range []connect.AnyRequest{
+		connect.NewRequest(&userv1.CreateUserRequest{User: &userv1.User{Email: "someone@example.com"}}),
+		connect.NewRequest(&userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}}),
+		connect.NewRequest(&userv1.UpdateUserRequest{User: &userv1.User{Email: "foo"}}),
+	} {
+		_, _ = call(context.Background(), req)
+	}
+	assert.Equal(t, []observation{
+		{msgType: "example.user.v1.CreateUserRequest", valid: true},
+		{msgType: "example.user.v1.CreateUserRequest", valid: false},
+	}, observations)
+}
+
+func TestNonProtoMessages(t *testing.T) {
+	t.Parallel()
+	type wrapper struct{ name string }
+	tests := []struct {
+		name     string
+		opts     []validate.Option
+		msg      *wrapper
+		wantErr  bool
+		wantCode connect.Code
+	}{
+		{
+			name:     "default",
+			msg:      &wrapper{name: "foo"},
+			wantErr:  true,
+			wantCode: connect.CodeUnknown,
+		},
+		{
+			name: "skip",
+			opts: []validate.Option{validate.WithSkipNonProtoMessages()},
+			msg:  &wrapper{},
+		},
+		{
+			name: "fallback_invalid",
+			opts: []validate.Option{validate.WithNonProtoFallback(func(_ context.Context, msg any) error {
+				if w, ok := msg.(*wrapper); ok && w.name == "" {
+					return errors.New("name is required")
+				}
+				return nil
+			})},
+			msg:      &wrapper{},
+			wantErr:  true,
+			wantCode: connect.CodeInvalidArgument,
+		},
+		{
+			name: "fallback_code",
+			opts: []validate.Option{validate.WithNonProtoFallback(func(context.Context, any) error {
+				return connect.NewError(connect.CodeFailedPrecondition, errors.New("not yet"))
+			})},
+			msg:      &wrapper{},
+			wantErr:  true,
+			wantCode: connect.CodeFailedPrecondition,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(test.opts...)
+			require.NoError(t, err)
+			_, err = interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+			})(context.Background(), connect.NewRequest(test.msg))
+			if !test.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, test.wantCode, connect.CodeOf(err))
+		})
+	}
+}
+
+func TestWithCodeMapper(t *testing.T) {
+	t.Parallel()
+	interceptor, err := validate.NewInterceptor(validate.WithCodeMapper(
+		func(spec connect.Spec, err *protovalidate.ValidationError) connect.Code {
+			assert.Equal(t, userv1connect.UserServiceCreateUserProcedure, spec.Procedure)
+			if err.Violations[0].Proto.GetConstraintId() == "string.email" {
+				return connect.CodeFailedPrecondition
+			}
+			return connect.CodeInvalidArgument
+		},
+	))
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+		userv1connect.UserServiceCreateUserProcedure,
+		createUser,
+		connect.WithInterceptors(interceptor),
+	))
+	srv := startHTTPServer(t, mux)
+
+	req := connect.NewRequest(&userv1.CreateUserRequest{
+		User: &userv1.User{Email: "foo"},
+	})
+	_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+		CreateUser(context.Background(), req)
+	require.Error(t, err)
+	assert.Equal(t, connect.CodeFailedPrecondition, connect.CodeOf(err))
+	violation := requireSingleViolation(t, err)
+	assert.Equal(t, "string.email", violation.GetConstraintId())
+}
+
+func TestWithErrorTransformer(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		user        *userv1.User
+		wantCode    connect.Code
+		wantMessage string
+	}{
+		{
+			name:        "transformed",
+			user:        &userv1.User{Email: "foo"},
+			wantCode:    connect.CodeFailedPrecondition,
+			wantMessage: "failed_precondition: 1 invalid field",
+		},
+		{
+			name: "fallback",
+			user: &userv1.User{
+				Email:      "someone@example.com",
+				BirthDate:  timestamppb.New(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)),
+				SignupDate: timestamppb.New(time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)),
+			},
+			wantCode: connect.CodeInvalidArgument,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithErrorTransformer(
+				func(_ context.Context, spec connect.Spec, err *protovalidate.ValidationError) *connect.Error {
+					assert.Equal(t, userv1connect.UserServiceCreateUserProcedure, spec.Procedure)
+					if err.Violations[0].Proto.GetConstraintId() != "string.email" {
+						return nil
+					}
+					return connect.NewError(
+						connect.CodeFailedPrecondition,
+						fmt.Errorf("%d invalid field", len(err.Violations)),
+					)
+				},
+			))
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			mux.Handle(userv1connect.UserServiceCreateUserProcedure, connect.NewUnaryHandler(
+				userv1connect.UserServiceCreateUserProcedure,
+				createUser,
+				connect.WithInterceptors(interceptor),
+			))
+			srv := startHTTPServer(t, mux)
+
+			req := connect.NewRequest(&userv1.CreateUserRequest{User: test.user})
+			_, err = userv1connect.NewUserServiceClient(srv.Client(), srv.URL).
+				CreateUser(context.Background(), req)
+			require.Error(t, err)
+			assert.Equal(t, test.wantCode, connect.CodeOf(err))
+			if test.wantMessage != "" {
+				assert.Equal(t, test.wantMessage, err.Error())
+				var connectErr *connect.Error
+				require.ErrorAs(t, err, &connectErr)
+				assert.Empty(t, connectErr.Details())
+				return
+			}
+			requireSingleViolation(t, err)
+		})
+	}
+}
+
+func BenchmarkInterceptorStreamingHandler(b *testing.B) {
+	for _, compression := range []string{"identity", "gzip"} {
+		for _, validated := range []bool{false, true} {
+			name := compression + "/baseline"
+			if validated {
+				name = compression + "/validated"
+			}
+			b.Run(name, func(b *testing.B) {
+				var handlerOpts []connect.HandlerOption
+				if validated {
+					interceptor, err := validate.NewInterceptor()
+					require.NoError(b, err)
+					handlerOpts = append(handlerOpts, connect.WithInterceptors(interceptor))
+				}
+				mux := http.NewServeMux()
+				mux.Handle(calculatorv1connect.CalculatorServiceCumSumProcedure, connect.NewBidiStreamHandler(
+					calculatorv1connect.CalculatorServiceCumSumProcedure,
+					cumSumSuccess,
+					handlerOpts...,
+				))
+				srv := httptest.NewUnstartedServer(mux)
+				srv.EnableHTTP2 = true
+				srv.StartTLS()
+				b.Cleanup(srv.Close)
+
+				var clientOpts []connect.ClientOption
+				if compression == "gzip" {
+					clientOpts = append(clientOpts, connect.WithSendGzip())
+				}
+				client := calculatorv1connect.NewCalculatorServiceClient(srv.Client(), srv.URL, clientOpts...)
+				stream := client.CumSum(context.Background())
+				b.Cleanup(func() {
+					assert.NoError(b, stream.CloseRequest())
+					assert.NoError(b, stream.CloseResponse())
+				})
+				req := &calculatorv1.CumSumRequest{Number: 1}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := stream.Send(req); err != nil {
+						b.Fatal(err)
+					}
+					if _, err := stream.Receive(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
 }
 
 func startHTTPServer(tb testing.TB, h http.Handler) *httptest.Server {
@@ -324,6 +1049,27 @@ func startHTTPServer(tb testing.TB, h http.Handler) *httptest.Server {
 	return srv
 }
 
+func requireSingleViolation(tb testing.TB, err error) *validatepb.Violation {
+	tb.Helper()
+	violations := violationsOf(tb, err)
+	require.Len(tb, violations, 1)
+	return violations[0]
+}
+
+// violationsOf returns the violations attached to an error as details.
+func violationsOf(tb testing.TB, err error) []*validatepb.Violation {
+	tb.Helper()
+	var connectErr *connect.Error
+	require.ErrorAs(tb, err, &connectErr)
+	details := connectErr.Details()
+	require.Len(tb, details, 1)
+	detail, err := details[0].Value()
+	require.NoError(tb, err)
+	violations, ok := detail.(*validatepb.Violations)
+	require.True(tb, ok)
+	return violations.GetViolations()
+}
+
 func createUser(_ context.Context, req *connect.Request[userv1.CreateUserRequest]) (*connect.Response[userv1.CreateUserResponse], error) {
 	return connect.NewResponse(&userv1.CreateUserResponse{User: req.Msg.User}), nil
 }
@@ -0,0 +1,245 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validatetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"path"
+	"strings"
+	"text/template"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ContractTestOptions configure [WriteContractTests].
+type ContractTestOptions struct {
+	// Package is the name of the generated file's package.
+	Package string
+	// Builder is the client's request builder, like "NewCreateUserRequest"
+	// or "client.NewCreateUserRequest". It's called without arguments. If
+	// it's empty, cases start from an empty message.
+	Builder string
+}
+
+// WriteContractTests writes a Go test file to w with a table-driven test that
+// checks a client's request builder against the constraints of a message
+// type: it has one case for each constraint, which must build a valid
+// message. Client teams use it to keep their builders in sync with the
+// server's rules.
+//
+// The cases are skeletons. Each one notes the constraint it covers, and it
+// has to be edited to pass the builder the input that exercises the
+// constraint. Constraints on singular message fields and on the elements of
+// lists and maps are included, but not those inside messages that are
+// elements of lists and maps. The message's Go package is taken from the
+// go_package option of its file.
+func WriteContractTests(w io.Writer, desc protoreflect.MessageDescriptor, opts ContractTestOptions) error {
+	if opts.Package == "" {
+		return errors.New("contract tests need a package name")
+	}
+	importPath, alias, err := goPackage(desc.ParentFile())
+	if err != nil {
+		return err
+	}
+	var cases []Violation
+	collectConstraints(desc, "", map[protoreflect.FullName]bool{}, &cases)
+	data := contractTestData{
+		Options:    opts,
+		ImportPath: importPath,
+		Alias:      alias,
+		TypeName:   goTypeName(desc),
+		FullName:   string(desc.FullName()),
+		Cases:      make([]contractTestCase, len(cases)),
+	}
+	for n, violation := range cases {
+		name, field := "["+violation.ConstraintID+"]", "the message"
+		if violation.Field != "" {
+			name, field = violation.Field+" "+name, violation.Field
+		}
+		data.Cases[n] = contractTestCase{Name: name, Field: field}
+	}
+	tmpl, err := template.New("contract").Parse(contractTestTemplate)
+	if err != nil {
+		return fmt.Errorf("parse contract test template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return fmt.Errorf("generate contract tests: %w", err)
+	}
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("format contract tests: %w", err)
+	}
+	_, err = w.Write(source)
+	return err
+}
+
+type contractTestData struct {
+	Options    ContractTestOptions
+	ImportPath string
+	Alias      string
+	TypeName   string
+	FullName   string
+	Cases      []contractTestCase
+}
+
+type contractTestCase struct {
+	Name  string
+	Field string
+}
+
+const contractTestTemplate = `// Contract tests for {{.FullName}}, generated by validate-contract-tests.
+// Edit each case to pass the builder the input that exercises its
+// constraint, and regenerate when the constraints change.
+
+package {{.Options.Package}}
+
+import (
+	"testing"
+
+	"connectrpc.com/validate/validatetest"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/require"
+	{{.Alias}} "{{.ImportPath}}"
+)
+
+func Test{{.TypeName}}Contract(t *testing.T) {
+	t.Parallel()
+	validator, err := protovalidate.New()
+	require.NoError(t, err)
+	tests := []struct {
+		constraint string
+		build      func() *{{.Alias}}.{{.TypeName}}
+	}{
+{{- range .Cases}}
+		{
+			constraint: {{printf "%q" .Name}},
+			build: func() *{{$.Alias}}.{{$.TypeName}} {
+				// TODO: exercise {{.Field}}.
+				return {{if $.Options.Builder}}{{$.Options.Builder}}(){{else}}&{{$.Alias}}.{{$.TypeName}}{}{{end}}
+			},
+		},
+{{- end}}
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.constraint, func(t *testing.T) {
+			t.Parallel()
+			validatetest.RequireViolations(t, validator.Validate(test.build()))
+		})
+	}
+}
+`
+
+// collectConstraints appends the constraints of desc, and of the messages in
+// its singular message fields, to cases. The prefix is the path to desc.
+func collectConstraints(desc protoreflect.MessageDescriptor, prefix string, visited map[protoreflect.FullName]bool, cases *[]Violation) {
+	if visited[desc.FullName()] {
+		return
+	}
+	visited[desc.FullName()] = true
+	defer delete(visited, desc.FullName())
+	if constraints, ok := proto.GetExtension(desc.Options(), validatepb.E_Message).(*validatepb.MessageConstraints); ok {
+		for _, constraint := range constraints.GetCel() {
+			*cases = append(*cases, Violation{Field: prefix, ConstraintID: constraint.GetId()})
+		}
+	}
+	fields := desc.Fields()
+	for n := 0; n < fields.Len(); n++ {
+		field := fields.Get(n)
+		fieldPath := string(field.Name())
+		if prefix != "" {
+			fieldPath = prefix + "." + fieldPath
+		}
+		constraints, _ := proto.GetExtension(field.Options(), validatepb.E_Field).(*validatepb.FieldConstraints)
+		if constraints.GetIgnore() == validatepb.Ignore_IGNORE_ALWAYS {
+			continue
+		}
+		collectFieldConstraints(constraints, fieldPath, cases)
+		if field.Message() != nil && !field.IsList() && !field.IsMap() {
+			collectConstraints(field.Message(), fieldPath, visited, cases)
+		}
+	}
+}
+
+// collectFieldConstraints appends the constraints of a field to cases. The
+// rules for the elements of lists and the keys and values of maps are nested
+// field constraints rather than constraints of their own, so they're
+// collected with fieldPath+"[*]" as the path, and " key" appended for keys.
+func collectFieldConstraints(constraints *validatepb.FieldConstraints, fieldPath string, cases *[]Violation) {
+	if constraints == nil || constraints.GetIgnore() == validatepb.Ignore_IGNORE_ALWAYS {
+		return
+	}
+	if constraints.GetRequired() {
+		*cases = append(*cases, Violation{Field: fieldPath, ConstraintID: "required"})
+	}
+	for _, constraint := range constraints.GetCel() {
+		*cases = append(*cases, Violation{Field: fieldPath, ConstraintID: constraint.GetId()})
+	}
+	switch rules := constraints.GetType().(type) {
+	case *validatepb.FieldConstraints_Repeated:
+		collectRules(rules.Repeated, "repeated", fieldPath, cases)
+		collectFieldConstraints(rules.Repeated.GetItems(), fieldPath+"[*]", cases)
+	case *validatepb.FieldConstraints_Map:
+		collectRules(rules.Map, "map", fieldPath, cases)
+		collectFieldConstraints(rules.Map.GetKeys(), fieldPath+"[*] key", cases)
+		collectFieldConstraints(rules.Map.GetValues(), fieldPath+"[*]", cases)
+	case nil:
+	default:
+		reflected := constraints.ProtoReflect()
+		typeField := reflected.WhichOneof(reflected.Descriptor().Oneofs().ByName("type"))
+		collectRules(reflected.Get(typeField).Message().Interface(), string(typeField.Name()), fieldPath, cases)
+	}
+}
+
+// collectRules appends a case for each rule set in rules, a message like
+// buf.validate.StringRules, to cases. Examples and nested field constraints
+// aren't rules, so they're skipped.
+func collectRules(rules proto.Message, kind, fieldPath string, cases *[]Violation) {
+	rules.ProtoReflect().Range(func(rule protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if rule.Name() != "example" && (rule.Message() == nil || rule.Message().FullName() != "buf.validate.FieldConstraints") {
+			*cases = append(*cases, Violation{Field: fieldPath, ConstraintID: kind + "." + string(rule.Name())})
+		}
+		return true
+	})
+}
+
+// goPackage returns the import path and name of the Go package generated for
+// file, from its go_package option.
+func goPackage(file protoreflect.FileDescriptor) (string, string, error) {
+	opts, _ := file.Options().(*descriptorpb.FileOptions)
+	goPackage := opts.GetGoPackage()
+	if goPackage == "" {
+		return "", "", fmt.Errorf("%s has no go_package option", file.Path())
+	}
+	importPath, name, found := strings.Cut(goPackage, ";")
+	if !found {
+		name = path.Base(importPath)
+	}
+	return importPath, name, nil
+}
+
+// goTypeName returns the name of the Go type generated for desc.
+func goTypeName(desc protoreflect.MessageDescriptor) string {
+	name := strings.TrimPrefix(string(desc.FullName()), string(desc.ParentFile().Package())+".")
+	return strings.ReplaceAll(name, ".", "_")
+}
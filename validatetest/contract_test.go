@@ -0,0 +1,156 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validatetest_test
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/validatetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestWriteContractTests(t *testing.T) {
+	t.Parallel()
+	desc := (&userv1.CreateUserRequest{}).ProtoReflect().Descriptor()
+	var out strings.Builder
+	err := validatetest.WriteContractTests(&out, desc, validatetest.ContractTestOptions{
+		Package: "userclient",
+		Builder: "NewCreateUserRequest",
+	})
+	require.NoError(t, err)
+	source := out.String()
+	_, err = parser.ParseFile(token.NewFileSet(), "contract_test.go", source, parser.AllErrors)
+	require.NoError(t, err, source)
+	assert.Contains(t, source, "package userclient\n")
+	assert.Contains(t, source, `userv1 "connectrpc.com/validate/internal/gen/example/user/v1"`)
+	assert.Contains(t, source, "func TestCreateUserRequestContract(t *testing.T) {")
+	assert.Contains(t, source, "return NewCreateUserRequest()")
+	assert.Equal(t, []string{
+		`"user [user.signup_date]",`,
+		`"user.email [string.email]",`,
+		`"user.handle [user.handle]",`,
+	}, contractCases(source))
+
+	err = validatetest.WriteContractTests(&out, desc, validatetest.ContractTestOptions{})
+	require.Error(t, err)
+}
+
+func TestWriteContractTestsNestedRules(t *testing.T) {
+	t.Parallel()
+	tagsOptions := &descriptorpb.FieldOptions{}
+	proto.SetExtension(tagsOptions, validatepb.E_Field, &validatepb.FieldConstraints{
+		Type: &validatepb.FieldConstraints_Repeated{Repeated: &validatepb.RepeatedRules{
+			MinItems: proto.Uint64(1),
+			Items: &validatepb.FieldConstraints{Type: &validatepb.FieldConstraints_String_{
+				String_: &validatepb.StringRules{MinLen: proto.Uint64(1)},
+			}},
+		}},
+	})
+	labelsOptions := &descriptorpb.FieldOptions{}
+	proto.SetExtension(labelsOptions, validatepb.E_Field, &validatepb.FieldConstraints{
+		Type: &validatepb.FieldConstraints_Map{Map: &validatepb.MapRules{
+			MaxPairs: proto.Uint64(8),
+			Keys: &validatepb.FieldConstraints{Type: &validatepb.FieldConstraints_String_{
+				String_: &validatepb.StringRules{MaxLen: proto.Uint64(16)},
+			}},
+			Values: &validatepb.FieldConstraints{Type: &validatepb.FieldConstraints_Int32{
+				Int32: &validatepb.Int32Rules{GreaterThan: &validatepb.Int32Rules_Gt{Gt: 0}},
+			}},
+		}},
+	})
+	file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("example/tag/v1/tag.proto"),
+		Package:    proto.String("example.tag.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"buf/validate/validate.proto"},
+		Options:    &descriptorpb.FileOptions{GoPackage: proto.String("example.com/tag/v1;tagv1")},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("TagRequest"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{
+					Name:     proto.String("tags"),
+					JsonName: proto.String("tags"),
+					Number:   proto.Int32(1),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					Options:  tagsOptions,
+				},
+				{
+					Name:     proto.String("labels"),
+					JsonName: proto.String("labels"),
+					Number:   proto.Int32(2),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(".example.tag.v1.TagRequest.LabelsEntry"),
+					Options:  labelsOptions,
+				},
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("LabelsEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{
+						Name:     proto.String("key"),
+						JsonName: proto.String("key"),
+						Number:   proto.Int32(1),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+					},
+					{
+						Name:     proto.String("value"),
+						JsonName: proto.String("value"),
+						Number:   proto.Int32(2),
+						Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+						Type:     descriptorpb.FieldDescriptorProto_TYPE_INT32.Enum(),
+					},
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+	}, protoregistry.GlobalFiles)
+	require.NoError(t, err)
+	var out strings.Builder
+	err = validatetest.WriteContractTests(&out, file.Messages().Get(0), validatetest.ContractTestOptions{
+		Package: "tagclient",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`"tags [repeated.min_items]",`,
+		`"tags[*] [string.min_len]",`,
+		`"labels [map.max_pairs]",`,
+		`"labels[*] key [string.max_len]",`,
+		`"labels[*] [int32.gt]",`,
+	}, contractCases(out.String()))
+}
+
+// contractCases returns the constraint lines of generated contract tests.
+func contractCases(source string) []string {
+	var cases []string
+	for _, line := range strings.Split(source, "\n") {
+		if constraint, ok := strings.CutPrefix(strings.TrimSpace(line), "constraint: "); ok {
+			cases = append(cases, constraint)
+		}
+	}
+	return cases
+}
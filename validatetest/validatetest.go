@@ -0,0 +1,147 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validatetest helps tests assert the violations reported by
+// validation errors, whether they come from a [validate.Interceptor] or
+// directly from [protovalidate].
+//
+// [validate.Interceptor]: https://pkg.go.dev/connectrpc.com/validate#Interceptor
+package validatetest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+)
+
+// A Violation is an expected violation, identified like the expected results
+// of the protovalidate conformance suite.
+type Violation struct {
+	// Field is the path to the invalid field, like "user.email" or
+	// "tags[2]". It's empty for message-level constraints.
+	Field string
+	// ConstraintID is the ID of the violated constraint, like
+	// "string.email".
+	ConstraintID string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("field: %q constraint_id: %q", v.Field, v.ConstraintID)
+}
+
+// Violations returns the violations reported by err. It understands
+// [*connect.Error] values with a buf.validate.Violations detail and
+// [*protovalidate.ValidationError] values, including wrapped ones. If err
+// reports no violations, Violations returns false.
+func Violations(err error) ([]Violation, bool) {
+	if validationErr := new(protovalidate.ValidationError); errors.As(err, &validationErr) {
+		return fromProto(validationErr.ToProto()), true
+	}
+	if connectErr := new(connect.Error); errors.As(err, &connectErr) {
+		for _, detail := range connectErr.Details() {
+			msg, valueErr := detail.Value()
+			if valueErr != nil {
+				continue
+			}
+			if violations, ok := msg.(*validatepb.Violations); ok {
+				return fromProto(violations), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// AssertViolations checks that err reports exactly the wanted violations, in
+// any order. A nil err reports no violations. If the violations differ, it
+// marks the test as failed, lists the wanted and reported violations like
+// the conformance suite, and returns false.
+func AssertViolations(tb testing.TB, err error, want ...Violation) bool {
+	tb.Helper()
+	got, ok := Violations(err)
+	if !ok && err != nil {
+		tb.Errorf("validation error: want %s, got unrelated error: %v", describe(want), err)
+		return false
+	}
+	if equal(got, want) {
+		return true
+	}
+	tb.Errorf("validation error mismatch\n     want: %s\n      got: %s", describe(want), describe(got))
+	return false
+}
+
+// RequireViolations is like [AssertViolations], but stops the test if the
+// violations differ.
+func RequireViolations(tb testing.TB, err error, want ...Violation) {
+	tb.Helper()
+	if !AssertViolations(tb, err, want...) {
+		tb.FailNow()
+	}
+}
+
+func fromProto(violations *validatepb.Violations) []Violation {
+	result := make([]Violation, len(violations.GetViolations()))
+	for n, violation := range violations.GetViolations() {
+		result[n] = Violation{
+			Field:        protovalidate.FieldPathString(violation.GetField()),
+			ConstraintID: violation.GetConstraintId(),
+		}
+	}
+	return result
+}
+
+func equal(got, want []Violation) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	got, want = sorted(got), sorted(want)
+	for n := range got {
+		if got[n] != want[n] {
+			return false
+		}
+	}
+	return true
+}
+
+func sorted(violations []Violation) []Violation {
+	result := append([]Violation(nil), violations...)
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Field != result[b].Field {
+			return result[a].Field < result[b].Field
+		}
+		return result[a].ConstraintID < result[b].ConstraintID
+	})
+	return result
+}
+
+func describe(violations []Violation) string {
+	if len(violations) == 0 {
+		return "valid"
+	}
+	var builder strings.Builder
+	noun := "violations"
+	if len(violations) == 1 {
+		noun = "violation"
+	}
+	fmt.Fprintf(&builder, "validation error (%d %s)", len(violations), noun)
+	for n, violation := range sorted(violations) {
+		fmt.Fprintf(&builder, "\n           %d. %s", n+1, violation)
+	}
+	return builder.String()
+}
@@ -0,0 +1,101 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validatetest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"connectrpc.com/validate/validatetest"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssertViolations(t *testing.T) {
+	t.Parallel()
+	invalid := &userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}}
+	validationErr := protovalidate.Validate(invalid)
+	require.Error(t, validationErr)
+	interceptor, err := validate.NewInterceptor()
+	require.NoError(t, err)
+	next := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+	}
+	_, connectErr := interceptor.WrapUnary(next)(context.Background(), connect.NewRequest(invalid))
+	require.Error(t, connectErr)
+
+	email := validatetest.Violation{Field: "user.email", ConstraintID: "string.email"}
+	tests := []struct {
+		name   string
+		err    error
+		want   []validatetest.Violation
+		failed bool
+	}{
+		{name: "validation_error", err: validationErr, want: []validatetest.Violation{email}},
+		{name: "connect_error", err: connectErr, want: []validatetest.Violation{email}},
+		{name: "wrapped", err: fmt.Errorf("create user: %w", connectErr), want: []validatetest.Violation{email}},
+		{name: "valid", err: nil},
+		{name: "missing", err: nil, want: []validatetest.Violation{email}, failed: true},
+		{name: "unexpected", err: connectErr, failed: true},
+		{name: "unrelated", err: errors.New("oh no"), want: []validatetest.Violation{email}, failed: true},
+		{
+			name:   "different",
+			err:    connectErr,
+			want:   []validatetest.Violation{{Field: "user.email", ConstraintID: "string.min_len"}},
+			failed: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			recorder := &recordingTB{TB: t}
+			assert.Equal(t, !test.failed, validatetest.AssertViolations(recorder, test.err, test.want...))
+			assert.Equal(t, test.failed, recorder.failed)
+		})
+	}
+}
+
+func TestAssertViolationsOutput(t *testing.T) {
+	t.Parallel()
+	validationErr := protovalidate.Validate(&userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}})
+	recorder := &recordingTB{TB: t}
+	validatetest.AssertViolations(recorder, validationErr)
+	assert.Equal(t, `validation error mismatch
+     want: valid
+      got: validation error (1 violation)
+           1. field: "user.email" constraint_id: "string.email"`, recorder.message)
+}
+
+// recordingTB records failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+
+	failed  bool
+	message string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failed = true
+	r.message = fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,125 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+)
+
+const (
+	// ValidatorHeader is the error metadata key that identifies the
+	// validation layer. See [WithVersionHeaders].
+	ValidatorHeader = "Validate-Validator"
+	// SchemaVersionHeader is the error metadata key that carries the version
+	// of the constraint schema. See [WithVersionHeaders].
+	SchemaVersionHeader = "Validate-Schema-Version"
+	// ClientSchemaVersionHeader is the request header in which clients
+	// advertise the version of the constraint schema they were built
+	// against. See [WithSchemaVersions].
+	ClientSchemaVersionHeader = "Validate-Client-Schema-Version"
+)
+
+// modulePath is the path of this module, which identifies the validation
+// layer.
+const modulePath = "connectrpc.com/validate"
+
+// WithVersionHeaders configures the [Interceptor] to identify itself in the
+// error metadata of rejected messages, so that fleet-wide scanners can
+// inventory which services enforce validation. The [ValidatorHeader] holds
+// this module's path and, if the binary's build info records it, its
+// version, as in "connectrpc.com/validate@v0.2.0". The [SchemaVersionHeader]
+// holds schemaVersion, which identifies the revision of the constraints: for
+// example, the commit of the Buf module the Protobuf files were generated
+// from. If schemaVersion is empty, it's omitted.
+func WithVersionHeaders(schemaVersion string) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.reporting.versionHeaders = &versionHeaders{
+			validator:     validatorIdentity(),
+			schemaVersion: schemaVersion,
+		}
+	})
+}
+
+type versionHeaders struct {
+	validator     string
+	schemaVersion string
+}
+
+// validatorIdentity returns the module path, with the module's version if the
+// build info records it.
+func validatorIdentity() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return modulePath
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath && dep.Version != "" {
+			return modulePath + "@" + dep.Version
+		}
+	}
+	return modulePath
+}
+
+// WithSchemaVersions configures the [Interceptor] to validate requests from
+// clients built against older revisions of the constraints with the rules of
+// those revisions, so that servers keep accepting old clients through long
+// deprecation windows. The validators are keyed by schema version. If a
+// client advertises a registered version in the [ClientSchemaVersionHeader]
+// request header, its requests are validated with that version's validator,
+// and errors for invalid requests carry the version in the
+// [SchemaVersionHeader] metadata, replacing the one configured with
+// [WithVersionHeaders]. Requests from other clients are validated as usual.
+//
+// Only handlers negotiate versions. The validator chosen with
+// [WithValidatorSelector], if any, takes precedence. Calling
+// WithSchemaVersions more than once merges the versions.
+func WithSchemaVersions(validators map[string]protovalidate.Validator) Option {
+	return optionFunc(func(i *Interceptor) {
+		if i.validators.schemaVersions == nil {
+			i.validators.schemaVersions = make(map[string]protovalidate.Validator, len(validators))
+		}
+		for version, validator := range validators {
+			i.validators.schemaVersions[version] = validator
+		}
+	})
+}
+
+type schemaVersionKey struct{}
+
+// withSchemaVersion returns a copy of ctx that records the schema version the
+// client advertised in its headers, if it's registered. The headers are only
+// read if schema versions are configured, since reading a request's headers
+// may initialize them, racing with concurrent RPCs that share the request.
+func (i *Interceptor) withSchemaVersion(ctx context.Context, spec connect.Spec, header func() http.Header) context.Context {
+	if i.validators.schemaVersions == nil || spec.IsClient {
+		return ctx
+	}
+	version := header().Get(ClientSchemaVersionHeader)
+	if _, ok := i.validators.schemaVersions[version]; !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, schemaVersionKey{}, version)
+}
+
+// negotiatedSchemaVersion returns the schema version recorded in ctx, if any.
+func negotiatedSchemaVersion(ctx context.Context) (string, bool) {
+	version, ok := ctx.Value(schemaVersionKey{}).(string)
+	return version, ok
+}
@@ -0,0 +1,94 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"connectrpc.com/validate"
+	userv1 "connectrpc.com/validate/internal/gen/example/user/v1"
+	"github.com/bufbuild/protovalidate-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithVersionHeaders(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		schemaVersion string
+	}{
+		{name: "schema_version", schemaVersion: "5b0a2c7"},
+		{name: "no_schema_version"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			interceptor, err := validate.NewInterceptor(validate.WithVersionHeaders(test.schemaVersion))
+			require.NoError(t, err)
+			call := interceptor.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return connect.NewResponse(&userv1.CreateUserResponse{}), nil
+			})
+			_, err = call(context.Background(), connect.NewRequest(&userv1.CreateUserRequest{
+				User: &userv1.User{Email: "foo"},
+			}))
+			var connectErr *connect.Error
+			require.ErrorAs(t, err, &connectErr)
+			assert.True(t, strings.HasPrefix(connectErr.Meta().Get(validate.ValidatorHeader), "connectrpc.com/validate"))
+			if test.schemaVersion == "" {
+				assert.Empty(t, connectErr.Meta().Values(validate.SchemaVersionHeader))
+			} else {
+				assert.Equal(t, test.schemaVersion, connectErr.Meta().Get(validate.SchemaVersionHeader))
+			}
+		})
+	}
+}
+
+func TestWithSchemaVersions(t *testing.T) {
+	t.Parallel()
+	legacy := staticValidator{err: &protovalidate.ValidationError{Violations: []*validate.Violation{
+		newViolation("legacy.rule"),
+	}}}
+	interceptor, err := validate.NewInterceptor(
+		validate.WithVersionHeaders("v2"),
+		validate.WithSchemaVersions(map[string]protovalidate.Validator{"v1": legacy}),
+	)
+	require.NoError(t, err)
+	client := newUserClient(t, interceptor)
+	tests := []struct {
+		advertised  string
+		wantRule    string
+		wantVersion string
+	}{
+		{advertised: "v1", wantRule: "legacy.rule", wantVersion: "v1"},
+		{advertised: "v0", wantRule: "string.email", wantVersion: "v2"},
+		{wantRule: "string.email", wantVersion: "v2"},
+	}
+	for _, test := range tests {
+		req := connect.NewRequest(&userv1.CreateUserRequest{User: &userv1.User{Email: "foo"}})
+		if test.advertised != "" {
+			req.Header().Set(validate.ClientSchemaVersionHeader, test.advertised)
+		}
+		_, err := client.CreateUser(context.Background(), req)
+		var connectErr *connect.Error
+		require.ErrorAs(t, err, &connectErr)
+		assert.Equal(t, test.wantRule, requireSingleViolation(t, err).GetConstraintId(), test.advertised)
+		assert.Equal(t, test.wantVersion, connectErr.Meta().Get(validate.SchemaVersionHeader), test.advertised)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// WithWarmupMessages configures the [Interceptor]'s default validator to
+// compile the constraints for the messages' types in [NewInterceptor], rather
+// than when it first sees each type, which removes the latency spike of the
+// first requests after a deploy. With [WithLazyInit], compilation happens
+// when the validator is constructed instead. Like the other options for the
+// default validator, it can't be combined with [WithValidator]: construct
+// the validator with [protovalidate.WithMessages] instead.
+func WithWarmupMessages(msgs ...proto.Message) Option {
+	return optionFunc(func(i *Interceptor) {
+		for _, msg := range msgs {
+			i.validators.warmup = append(i.validators.warmup, msg.ProtoReflect().Descriptor())
+		}
+	})
+}
+
+// WithWarmupServices is like [WithWarmupMessages], but compiles the
+// constraints for the requests of every method of the named services, like
+// "acme.user.v1.UserService". With [WithStreamingResponses], it compiles the
+// constraints for responses too. The services must be registered in
+// [protoregistry.GlobalFiles], which generated code does automatically.
+func WithWarmupServices(serviceNames ...string) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.validators.warmupServices = append(i.validators.warmupServices, serviceNames...)
+	})
+}
+
+// resolveWarmup adds the messages of the warmup services to i.validators.warmup.
+func (i *Interceptor) resolveWarmup() error {
+	for _, name := range i.validators.warmupServices {
+		desc, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(name))
+		if err != nil {
+			return fmt.Errorf("warm up service %q: %w", name, err)
+		}
+		service, ok := desc.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return fmt.Errorf("warm up service %q: not a service", name)
+		}
+		methods := service.Methods()
+		for n := 0; n < methods.Len(); n++ {
+			i.validators.warmup = append(i.validators.warmup, methods.Get(n).Input())
+			if i.direction.responses() || (i.streamingResponses && methods.Get(n).IsStreamingServer()) {
+				i.validators.warmup = append(i.validators.warmup, methods.Get(n).Output())
+			}
+		}
+	}
+	return nil
+}
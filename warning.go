@@ -0,0 +1,165 @@
+// Copyright 2023-2024 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	validatepb "buf.build/gen/go/bufbuild/protovalidate/protocolbuffers/go/buf/validate"
+	"connectrpc.com/connect"
+	"github.com/bufbuild/protovalidate-go"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithWarningConverter configures the [Interceptor] to surface warnings to
+// handlers. Whenever a handler receives an invalid message on a procedure in
+// [ModeWarn], the converter turns the violations into a message of the
+// caller's choosing, and the handler can retrieve it with [Warnings]. This
+// lets handlers embed warnings in existing response envelopes. To surface
+// protovalidate's own representation, convert with
+// [protovalidate.ValidationError.ToProto].
+//
+// Warnings are only surfaced to handlers, not to clients.
+func WithWarningConverter(convert func(*protovalidate.ValidationError) proto.Message) Option {
+	return optionFunc(func(i *Interceptor) {
+		i.warnings.warningConverter = convert
+	})
+}
+
+// WarningsTrailer is the response trailer that lists the violations a
+// handler's messages would have caused if they were enforced. See
+// [WithWarningTrailers].
+const WarningsTrailer = "Validate-Warnings"
+
+// WithWarningTrailers configures the [Interceptor] to report the violations of
+// messages received on procedures in [ModeWarn], including those demoted by
+// [WithDryRun], in the [WarningsTrailer] response trailer. Each violation is a
+// separate value, with its field path and constraint ID, as in
+// "user.email [string.email]". Clients and QA tooling can then see what
+// would fail once enforcement is turned on. Messages are left out, since
+// they may quote field values. Trailers reveal no more than errors would:
+// callers that only see field paths (see [WithDetailLevel],
+// [WithPeerDetailLevel], and [WithoutRuleDetails]) get field paths alone,
+// and callers that see nothing, as with [WithoutErrorDetails], get no
+// trailers.
+//
+// Trailers are only added to handlers' responses. If the handler returns a
+// [connect.Error], they're added to its metadata.
+func WithWarningTrailers() Option {
+	return optionFunc(func(i *Interceptor) {
+		i.warnings.warningTrailers = true
+	})
+}
+
+// Warnings returns the warnings about the messages a handler has received so
+// far, converted by the function configured with [WithWarningConverter]. It
+// returns nil if there are no warnings, or if the context doesn't belong to a
+// handler wrapped by an Interceptor with a converter.
+func Warnings(ctx context.Context) []proto.Message {
+	collector, ok := ctx.Value(warningsKey{}).(*warningCollector)
+	if !ok {
+		return nil
+	}
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if len(collector.warnings) == 0 {
+		return nil
+	}
+	return append([]proto.Message(nil), collector.warnings...)
+}
+
+type warningsKey struct{}
+
+type warningCollector struct {
+	mu       sync.Mutex
+	warnings []proto.Message
+	trailers []string
+}
+
+// withWarnings prepares the context of a handler to collect warnings.
+func (i *Interceptor) withWarnings(ctx context.Context, spec connect.Spec) context.Context {
+	if (i.warnings.warningConverter == nil && !i.warnings.warningTrailers) || spec.IsClient {
+		return ctx
+	}
+	return context.WithValue(ctx, warningsKey{}, &warningCollector{})
+}
+
+func (i *Interceptor) addWarning(ctx context.Context, spec connect.Spec, err *protovalidate.ValidationError) {
+	collector, ok := ctx.Value(warningsKey{}).(*warningCollector)
+	if !ok || err == nil {
+		return
+	}
+	var warning proto.Message
+	if i.warnings.warningConverter != nil {
+		warning = i.warnings.warningConverter(err)
+	}
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if warning != nil {
+		collector.warnings = append(collector.warnings, warning)
+	}
+	if i.warnings.warningTrailers {
+		collector.trailers = append(collector.trailers, i.warningTrailerValues(ctx, spec, err)...)
+	}
+}
+
+// warningTrailerValues renders the violations of err for the WarningsTrailer,
+// revealing no more than errors would to the RPC's caller.
+func (i *Interceptor) warningTrailerValues(ctx context.Context, spec connect.Spec, err *protovalidate.ValidationError) []string {
+	switch level := i.detailLevelFor(ctx, spec); {
+	case level == DetailNone:
+		return nil
+	case level == DetailFieldPaths || i.reporting.withoutRules:
+		var paths []string
+		for _, violation := range err.Violations {
+			if path := protovalidate.FieldPathString(violation.Proto.GetField()); path != "" {
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	default:
+		return compactViolations(err)
+	}
+}
+
+// compactViolations renders each violation of err with its field path and
+// constraint ID, as in "user.email [string.email]". Messages are left out,
+// since they may quote field values.
+func compactViolations(err *protovalidate.ValidationError) []string {
+	compact := make([]string, len(err.Violations))
+	for n, violation := range err.Violations {
+		withoutMessage := &Violation{Proto: &validatepb.Violation{
+			Field:        violation.Proto.GetField(),
+			ConstraintId: proto.String(violation.Proto.GetConstraintId()),
+		}}
+		compact[n] = FormatViolations([]*Violation{withoutMessage}, FormatText)
+	}
+	return compact
+}
+
+// writeWarningTrailers adds the violations collected in ctx to trailer.
+func writeWarningTrailers(ctx context.Context, trailer http.Header) {
+	collector, ok := ctx.Value(warningsKey{}).(*warningCollector)
+	if !ok {
+		return
+	}
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	for _, value := range collector.trailers {
+		trailer.Add(WarningsTrailer, value)
+	}
+}